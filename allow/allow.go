@@ -15,6 +15,7 @@ import (
 const (
 	AL_QUERY int = iota + 1
 	AL_VARS
+	AL_FRAG
 )
 
 type Item struct {
@@ -23,6 +24,10 @@ type Item struct {
 	Query   string
 	Vars    json.RawMessage
 	Comment string
+
+	// Hash is the sha256 hash of the document the query was sent in, it's
+	// the hash clients use for automatic persisted queries
+	Hash string
 }
 
 type List struct {
@@ -105,7 +110,7 @@ func (al *List) IsPersist() bool {
 	return al.saveChan != nil
 }
 
-func (al *List) Set(vars []byte, query, comment string) error {
+func (al *List) Set(vars []byte, query, hash, comment string) error {
 	if al.saveChan == nil {
 		return errors.New("allow.list is read-only")
 	}
@@ -132,6 +137,7 @@ func (al *List) Set(vars []byte, query, comment string) error {
 		Comment: comment,
 		Query:   q,
 		Vars:    vars,
+		Hash:    hash,
 	}
 
 	return nil
@@ -151,8 +157,10 @@ func (al *List) Load() ([]Item, error) {
 
 	var comment bytes.Buffer
 	var varBytes []byte
+	var hash string
 
 	itemMap := make(map[string]struct{})
+	frags := make(map[string]string)
 
 	s, e, c := 0, 0, 0
 	ty := 0
@@ -174,16 +182,22 @@ func (al *List) Load() ([]Item, error) {
 			break
 		}
 
-		if matchPrefix(b, e, "query") || matchPrefix(b, e, "mutation") {
-			if c == 0 {
-				s = e
-			}
+		if c == 0 && (matchPrefix(b, e, "query") || matchPrefix(b, e, "mutation") ||
+			matchPrefix(b, e, "subscription")) {
+			s = e
 			ty = AL_QUERY
-		} else if matchPrefix(b, e, "variables") {
-			if c == 0 {
-				s = e + len("variables") + 1
-			}
+		} else if c == 0 && matchPrefix(b, e, "fragment") {
+			s = e
+			ty = AL_FRAG
+		} else if c == 0 && matchPrefix(b, e, "variables") {
+			s = e + len("variables") + 1
 			ty = AL_VARS
+		} else if c == 0 && ty == 0 && matchPrefix(b, e, "hash ") {
+			s = e + len("hash ")
+			for e < len(b) && b[e] != '\n' {
+				e++
+			}
+			hash = strings.TrimSpace(string(b[s:e]))
 		} else if b[e] == '{' {
 			c++
 
@@ -193,6 +207,9 @@ func (al *List) Load() ([]Item, error) {
 			if c == 0 {
 				if ty == AL_QUERY {
 					fq = true
+				} else if ty == AL_FRAG {
+					f := string(b[s:(e + 1)])
+					frags[FragmentName(f)] = f
 				} else if ty == AL_VARS {
 					varBytes = b[s:(e + 1)]
 				}
@@ -212,12 +229,14 @@ func (al *List) Load() ([]Item, error) {
 					Query:   query,
 					Vars:    varBytes,
 					Comment: comment.String(),
+					Hash:    hash,
 				}
 				list = append(list, v)
 				comment.Reset()
 			}
 
 			varBytes = nil
+			hash = ""
 
 		}
 
@@ -227,6 +246,11 @@ func (al *List) Load() ([]Item, error) {
 		}
 	}
 
+	// fragments can be defined anywhere in the file
+	for i := range list {
+		list[i].Query = withFragments(list[i].Query, frags)
+	}
+
 	return list, nil
 }
 
@@ -256,6 +280,9 @@ func (al *List) save(item Item) error {
 		if len(list[index].Comment) != 0 {
 			item.Comment = list[index].Comment
 		}
+		if len(item.Hash) == 0 {
+			item.Hash = list[index].Hash
+		}
 		list[index] = item
 	} else {
 		list = append(list, item)
@@ -298,6 +325,12 @@ func (al *List) save(item Item) error {
 			}
 		}
 
+		if len(v.Hash) != 0 {
+			if _, err := f.WriteString(fmt.Sprintf("hash %s\n\n", v.Hash)); err != nil {
+				return err
+			}
+		}
+
 		if len(v.Vars) != 0 && !bytes.Equal(v.Vars, []byte("{}")) {
 			vj, err := json.MarshalIndent(v.Vars, "", "  ")
 			if err != nil {
@@ -356,6 +389,159 @@ func QueryName(b string) string {
 	return ""
 }
 
+// FindOperation returns the operation named name from a document that can
+// have several operations, when name is empty the document must have only
+// one operation. The definitions of the fragments used by the operation are
+// appended to it and an empty string is returned if it's not found.
+func FindOperation(doc, name string) string {
+	ops, frags := parseDoc(doc)
+
+	if len(name) == 0 && len(ops) > 1 {
+		return ""
+	}
+
+	for _, v := range ops {
+		if len(name) == 0 || QueryName(v) == name {
+			return withFragments(v, frags)
+		}
+	}
+
+	return ""
+}
+
+// OperationCount returns the number of operations in a document
+func OperationCount(doc string) int {
+	ops, _ := parseDoc(doc)
+	return len(ops)
+}
+
+// parseDoc returns the operations and the fragments defined in a document
+func parseDoc(doc string) ([]string, map[string]string) {
+	var ops []string
+	frags := make(map[string]string)
+
+	depth, s := 0, -1
+	fragment := false
+
+	for i := 0; i < len(doc); i++ {
+		c := doc[i]
+
+		switch {
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' {
+				i++
+			}
+
+		case c == '"':
+			for i++; i < len(doc) && doc[i] != '"'; i++ {
+				if doc[i] == '\\' {
+					i++
+				}
+			}
+
+		case c == '{':
+			if depth == 0 && s == -1 {
+				s = i
+			}
+			depth++
+
+		case c == '}':
+			depth--
+
+			if depth != 0 || s == -1 {
+				continue
+			}
+
+			v := doc[s:(i + 1)]
+
+			if fragment {
+				frags[FragmentName(v)] = v
+			} else {
+				ops = append(ops, v)
+			}
+			s, fragment = -1, false
+
+		case depth == 0 && s == -1:
+			switch {
+			case strings.HasPrefix(doc[i:], "query"),
+				strings.HasPrefix(doc[i:], "mutation"),
+				strings.HasPrefix(doc[i:], "subscription"):
+				s = i
+			case strings.HasPrefix(doc[i:], "fragment"):
+				s, fragment = i, true
+			}
+		}
+	}
+
+	return ops, frags
+}
+
+// FragmentName returns the name of a fragment definition
+func FragmentName(b string) string {
+	b = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(b), "fragment"))
+
+	for i := 0; i < len(b); i++ {
+		if !isValidNameChar(b[i]) {
+			return b[:i]
+		}
+	}
+	return b
+}
+
+// withFragments appends the definitions of the fragments spread in the
+// query and the fragments they in turn spread
+func withFragments(query string, frags map[string]string) string {
+	if len(frags) == 0 {
+		return query
+	}
+
+	var sb strings.Builder
+	sb.WriteString(query)
+
+	added := make(map[string]struct{})
+	names := fragmentSpreads(query)
+
+	for i := 0; i < len(names); i++ {
+		f, ok := frags[names[i]]
+		if !ok {
+			continue
+		}
+
+		if _, ok := added[names[i]]; ok {
+			continue
+		}
+		added[names[i]] = struct{}{}
+
+		sb.WriteString("\n\n")
+		sb.WriteString(f)
+
+		names = append(names, fragmentSpreads(f)...)
+	}
+
+	return sb.String()
+}
+
+// fragmentSpreads returns the names of the fragments spread using '...name'
+func fragmentSpreads(b string) []string {
+	var names []string
+
+	for i := strings.Index(b, "..."); i != -1; i = strings.Index(b, "...") {
+		b = strings.TrimLeft(b[(i+3):], " \t\r\n")
+
+		n := 0
+		for n < len(b) && isValidNameChar(b[n]) {
+			n++
+		}
+
+		if n != 0 && b[:n] != "on" {
+			names = append(names, b[:n])
+		}
+		b = b[n:]
+	}
+
+	return names
+}
+
 func isValidNameChar(c byte) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
 }
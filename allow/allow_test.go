@@ -1,6 +1,10 @@
 package allow
 
 import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
 	"testing"
 )
 
@@ -82,3 +86,142 @@ func TestGQLName5(t *testing.T) {
 		t.Fatal("Name should be empty, not ", name)
 	}
 }
+
+func TestFindOperation(t *testing.T) {
+	var q = `
+	# all products
+	query getProducts {
+		products(where: { name: { eq: "{" } }) { id }
+	}
+
+	fragment userFields on users { id email }
+
+	mutation createUser {
+		user(insert: $data) { id }
+	}`
+
+	if op := FindOperation(q, "createUser"); op != "mutation createUser {\n\t\tuser(insert: $data) { id }\n\t}" {
+		t.Fatal("Operation 'createUser' not found, got ", op)
+	}
+
+	if op := FindOperation(q, ""); len(op) != 0 {
+		t.Fatal("An operation name is required with several operations, got ", op)
+	}
+
+	if n := OperationCount(q); n != 2 {
+		t.Fatal("Expected 2 operations, got ", n)
+	}
+
+	if name := QueryName(FindOperation("query getMe { me { id } }", "")); name != "getMe" {
+		t.Fatal("The only operation should be 'getMe', not ", name)
+	}
+
+	if op := FindOperation(q, "userFields"); len(op) != 0 {
+		t.Fatal("Fragments are not operations, got ", op)
+	}
+}
+
+func TestFindOperationWithFragments(t *testing.T) {
+	var q = `
+	fragment userName on users { full_name }
+
+	query getUsers {
+		users { ...userFields }
+	}
+
+	fragment userFields on users { id ...userName }
+	fragment productFields on products { id }`
+
+	op := FindOperation(q, "getUsers")
+
+	exp := "query getUsers {\n\t\tusers { ...userFields }\n\t}\n\n" +
+		"fragment userFields on users { id ...userName }\n\n" +
+		"fragment userName on users { full_name }"
+
+	if op != exp {
+		t.Fatal("Operation should include its fragments, got ", op)
+	}
+}
+
+func TestLoadWithFragments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "allow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(path.Join(dir, "allow.list"), []byte(`# Users page
+
+query getUsers {
+  users { ...userFields }
+}
+
+fragment userFields on users {
+  id
+  email
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	al, err := New(dir, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := al.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Name != "getUsers" {
+		t.Fatal("Expected a single query named 'getUsers', got ", list)
+	}
+
+	if !strings.HasSuffix(list[0].Query, "fragment userFields on users {\n  id\n  email\n}") {
+		t.Fatal("Query should include the fragment 'userFields', got ", list[0].Query)
+	}
+}
+
+func TestLoadWithHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "allow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(path.Join(dir, "allow.list"), []byte(`# Query named hashedUsers
+
+hash 1f2e
+
+query hashedUsers {
+  users { id }
+}
+
+query getProducts {
+  products { id }
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	al, err := New(dir, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := al.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 || list[0].Name != "hashedUsers" || list[0].Hash != "1f2e" {
+		t.Fatal("Expected the query 'hashedUsers' with the hash '1f2e', got ", list)
+	}
+
+	if list[1].Hash != "" {
+		t.Fatal("The hash should only apply to the query after it, got ", list[1].Hash)
+	}
+}
@@ -15,6 +15,7 @@ require (
 	github.com/garyburd/redigo v1.6.0
 	github.com/go-sourcemap/sourcemap v2.1.2+incompatible // indirect
 	github.com/gobuffalo/flect v0.1.6
+	github.com/gorilla/websocket v1.4.1
 	github.com/jackc/pgconn v1.0.1
 	github.com/jackc/pgtype v1.0.1
 	github.com/jackc/pgx/v4 v4.0.1
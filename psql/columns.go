@@ -3,6 +3,7 @@ package psql
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 
@@ -29,12 +30,25 @@ func (c *compilerContext) renderBaseColumns(
 		cn := col.Name
 		colmap[cn] = struct{}{}
 
-		_, isRealCol := ti.ColMap[cn]
+		dc, isRealCol := ti.ColMap[cn]
 
 		if isRealCol {
 			c.renderComma(i)
 			realColsRendered = append(realColsRendered, n)
-			colWithTable(c.w, ti.Name, cn)
+
+			// spatial columns are returned as GeoJSON
+			if len(geoType(dc.Type)) != 0 {
+				io.WriteString(c.w, `ST_AsGeoJSON(`)
+				colOrExpr(c.w, ti, cn)
+				io.WriteString(c.w, `) :: json`)
+				alias(c.w, cn)
+			} else {
+				colOrExpr(c.w, ti, cn)
+
+				if dc.IsComputed() {
+					alias(c.w, cn)
+				}
+			}
 
 		} else {
 			switch {
@@ -53,6 +67,10 @@ func (c *compilerContext) renderBaseColumns(
 					return nil, false, err
 				}
 
+			case sel.Recursive && cn == "__depth":
+				c.renderComma(i)
+				colWithTable(c.w, ti.Name, cn)
+
 			case strings.HasSuffix(cn, "_cursor"):
 				continue
 
@@ -78,12 +96,30 @@ func (c *compilerContext) renderBaseColumns(
 	}
 
 	for _, ob := range sel.OrderBy {
-		if _, ok := colmap[ob.Col]; ok {
+		if _, ok := colmap[ob.Col]; ok || ob.Geo != nil || len(ob.Path) != 0 {
 			continue
 		}
 		colmap[ob.Col] = struct{}{}
+
+		// ordering by an aggregate function that is not selected
+		if funcPrefixLen(ob.Col) != 0 {
+			if !sel.Functions || isColumnBlocked(sel, ob.Col[funcPrefixLen(ob.Col):]) {
+				return nil, false, fmt.Errorf("order_by on '%s' not allowed", ob.Col)
+			}
+			if err := c.renderColumnFunction(sel, ti, qcode.Column{Name: ob.Col}, i); err != nil {
+				return nil, false, err
+			}
+			isAgg = true
+			i++
+			continue
+		}
+
 		c.renderComma(i)
-		colWithTable(c.w, ti.Name, ob.Col)
+		colOrExpr(c.w, ti, ob.Col)
+
+		if dc, ok := ti.ColMap[ob.Col]; ok && dc.IsComputed() {
+			alias(c.w, ob.Col)
+		}
 		i++
 	}
 
@@ -107,21 +143,12 @@ func (c *compilerContext) renderColumnSearchRank(sel *qcode.Select, ti *DBTableI
 	if ti.TSVCol == nil {
 		return errors.New("no ts_vector column found")
 	}
-	cn := ti.TSVCol.Name
 	arg := sel.Args["search"]
 
 	c.renderComma(columnsRendered)
 	//fmt.Fprintf(w, `ts_rank("%s"."%s", websearch_to_tsquery('%s')) AS %s`,
 	//c.sel.Name, cn, arg.Val, col.Name)
-	io.WriteString(c.w, `ts_rank(`)
-	colWithTable(c.w, ti.Name, cn)
-	if c.schema.ver >= 110000 {
-		io.WriteString(c.w, `, websearch_to_tsquery('{{`)
-	} else {
-		io.WriteString(c.w, `, to_tsquery('{{`)
-	}
-	io.WriteString(c.w, arg.Val)
-	io.WriteString(c.w, `}}'))`)
+	c.renderSearchRank(ti, arg.Val)
 	alias(c.w, col.Name)
 
 	return nil
@@ -138,15 +165,7 @@ func (c *compilerContext) renderColumnSearchHeadline(sel *qcode.Select, ti *DBTa
 	c.renderComma(columnsRendered)
 	//fmt.Fprintf(w, `ts_headline("%s"."%s", websearch_to_tsquery('%s')) AS %s`,
 	//c.sel.Name, cn, arg.Val, col.Name)
-	io.WriteString(c.w, `ts_headline(`)
-	colWithTable(c.w, ti.Name, cn)
-	if c.schema.ver >= 110000 {
-		io.WriteString(c.w, `, websearch_to_tsquery('{{`)
-	} else {
-		io.WriteString(c.w, `, to_tsquery('{{`)
-	}
-	io.WriteString(c.w, arg.Val)
-	io.WriteString(c.w, `}}'))`)
+	c.renderSearchHeadline(ti, cn, arg.Val)
 	alias(c.w, col.Name)
 
 	return nil
@@ -193,7 +212,7 @@ func (c *compilerContext) renderColumnFunction(sel *qcode.Select, ti *DBTableInf
 	//fmt.Fprintf(w, `%s("%s"."%s") AS %s`, fn, c.sel.Name, cn, col.Name)
 	io.WriteString(c.w, fn)
 	io.WriteString(c.w, `(`)
-	colWithTable(c.w, ti.Name, cn)
+	colOrExpr(c.w, ti, cn)
 	io.WriteString(c.w, `)`)
 	alias(c.w, col.Name)
 
@@ -0,0 +1,210 @@
+//nolint:errcheck
+package psql
+
+import (
+	"io"
+
+	"github.com/dosco/super-graph/qcode"
+)
+
+// connectionLimit returns the number of edges in a page of the connection
+func connectionLimit(sel *qcode.Select) string {
+	if len(sel.Paging.Limit) != 0 {
+		return sel.Paging.Limit
+	}
+	return "20"
+}
+
+// renderConnectionSelect renders the Relay connection from the edges of
+// the select, one more row than the limit is fetched to tell if there is
+// another page and the rows are numbered in the order of the order_by
+func (c *compilerContext) renderConnectionSelect(sel *qcode.Select, ti *DBTableInfo) error {
+	conn := sel.Connection
+	backward := (sel.Paging.Type == qcode.PtBackward)
+	i := 0
+
+	io.WriteString(c.w, `SELECT json_build_object(`)
+
+	if len(conn.Edges) != 0 {
+		squoted(c.w, conn.Edges)
+		io.WriteString(c.w, `, coalesce(json_agg("__sel_`)
+		int2string(c.w, sel.ID)
+		io.WriteString(c.w, `"."json" ORDER BY "__sel_`)
+		int2string(c.w, sel.ID)
+		io.WriteString(c.w, `"."__rn"`)
+
+		// rows fetched backwards are returned in the forward order
+		if backward {
+			io.WriteString(c.w, ` DESC`)
+		}
+		io.WriteString(c.w, `) FILTER (WHERE "__sel_`)
+		int2string(c.w, sel.ID)
+		io.WriteString(c.w, `"."__rn" <= `)
+		c.renderConnectionLimit(sel)
+		io.WriteString(c.w, `), '[]')`)
+		i++
+	}
+
+	if len(conn.PageInfo) != 0 {
+		c.renderComma(i)
+		squoted(c.w, conn.PageInfo)
+		io.WriteString(c.w, `, json_build_object(`)
+
+		for n, col := range conn.PageInfoCols {
+			c.renderComma(n)
+			squoted(c.w, col.FieldName)
+			io.WriteString(c.w, `, `)
+
+			switch col.Name {
+			case "hasnextpage":
+				c.renderConnectionHasPage(sel, !backward)
+			case "haspreviouspage":
+				c.renderConnectionHasPage(sel, backward)
+			case "startcursor":
+				c.renderConnectionCursor(sel, backward)
+			case "endcursor":
+				c.renderConnectionCursor(sel, !backward)
+			}
+		}
+		io.WriteString(c.w, `)`)
+		i++
+	}
+
+	if len(conn.TotalCount) != 0 {
+		c.renderComma(i)
+		squoted(c.w, conn.TotalCount)
+		io.WriteString(c.w, `, `)
+		if err := c.renderConnectionCount(sel, ti); err != nil {
+			return err
+		}
+	}
+
+	io.WriteString(c.w, `) AS "json" FROM (`)
+	return nil
+}
+
+// renderConnectionHasPage renders if there's a page in the direction the
+// rows are fetched which is when the extra row was found, else if there's
+// a page before the cursor the rows are fetched from
+func (c *compilerContext) renderConnectionHasPage(sel *qcode.Select, inFetchDirection bool) {
+	switch {
+	case inFetchDirection:
+		io.WriteString(c.w, `(count(*) > `)
+		c.renderConnectionLimit(sel)
+		io.WriteString(c.w, `)`)
+
+	case sel.Paging.Cursor:
+		io.WriteString(c.w, `('{{cursor}}' != '')`)
+
+	default:
+		io.WriteString(c.w, `false`)
+	}
+}
+
+// renderConnectionCursor renders the cursor of the last row of the page or
+// of the first row fetched
+func (c *compilerContext) renderConnectionCursor(sel *qcode.Select, last bool) {
+	io.WriteString(c.w, `max("__sel_`)
+	int2string(c.w, sel.ID)
+	io.WriteString(c.w, `"."__cursor") FILTER (WHERE "__sel_`)
+	int2string(c.w, sel.ID)
+	io.WriteString(c.w, `"."__rn" = `)
+
+	if last {
+		io.WriteString(c.w, `least("__sel_`)
+		int2string(c.w, sel.ID)
+		io.WriteString(c.w, `"."__cnt", `)
+		c.renderConnectionLimit(sel)
+		io.WriteString(c.w, `))`)
+	} else {
+		io.WriteString(c.w, `1)`)
+	}
+}
+
+// renderConnectionCount renders the count of all the rows of the connection
+// ignoring the cursor
+func (c *compilerContext) renderConnectionCount(sel *qcode.Select, ti *DBTableInfo) error {
+	var rel *DBRel
+	var err error
+
+	if sel.ParentID != -1 {
+		rel, err = c.schema.GetRel(ti.Name, c.s[sel.ParentID].Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	io.WriteString(c.w, `(SELECT count(*) FROM `)
+
+	if err := c.renderFrom(sel, ti, rel); err != nil {
+		return err
+	}
+
+	if err := c.renderBaseWhere(sel, ti, rel, false); err != nil {
+		return err
+	}
+
+	io.WriteString(c.w, `)`)
+	return nil
+}
+
+// renderConnectionColumns renders the edges of the connection along with
+// the cursor, the number and the count of the rows fetched
+func (c *compilerContext) renderConnectionColumns(sel *qcode.Select, ti *DBTableInfo, skipped uint32) error {
+	conn := sel.Connection
+
+	io.WriteString(c.w, `SELECT json_build_object(`)
+
+	if len(conn.EdgeCursor) != 0 {
+		squoted(c.w, conn.EdgeCursor)
+		io.WriteString(c.w, `, `)
+		if err := c.renderRowCursor(sel, ti); err != nil {
+			return err
+		}
+	}
+
+	if len(conn.Node) != 0 {
+		if len(conn.EdgeCursor) != 0 {
+			io.WriteString(c.w, `, `)
+		}
+		squoted(c.w, conn.Node)
+		io.WriteString(c.w, `, json_build_object(`)
+		if err := c.renderColumns(sel, ti, skipped); err != nil {
+			return err
+		}
+		io.WriteString(c.w, `)`)
+	}
+
+	io.WriteString(c.w, `) AS "json", `)
+	if err := c.renderRowCursor(sel, ti); err != nil {
+		return err
+	}
+	io.WriteString(c.w, ` AS "__cursor", ROW_NUMBER() OVER (`)
+	if err := c.renderConnectionOrderBy(sel, ti); err != nil {
+		return err
+	}
+	io.WriteString(c.w, `) AS "__rn", count(*) OVER() AS "__cnt"`)
+
+	return nil
+}
+
+// renderConnectionOrderBy renders the order_by of the select on the rows
+// fetched so they're numbered in the order of the page
+func (c *compilerContext) renderConnectionOrderBy(sel *qcode.Select, ti *DBTableInfo) error {
+	if len(sel.OrderBy) == 0 {
+		return nil
+	}
+
+	if err := checkCursorOrder(sel, ti); err != nil {
+		return err
+	}
+
+	io.WriteString(c.w, `ORDER BY `)
+	return c.renderOrderByList(sel, ti, sel.ID)
+}
+
+func (c *compilerContext) renderConnectionLimit(sel *qcode.Select) {
+	io.WriteString(c.w, `('`)
+	io.WriteString(c.w, connectionLimit(sel))
+	io.WriteString(c.w, `') :: integer`)
+}
@@ -0,0 +1,256 @@
+//nolint:errcheck
+package psql
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+
+	"github.com/dosco/super-graph/qcode"
+)
+
+// cursorVersion is the version of the format of the cursors, it must be
+// changed along with the format so older cursors fail cleanly
+const cursorVersion = "1"
+
+// CursorPrefix returns the prefix of the cursors of the select, it's made of
+// the version of the cursor format and a fingerprint of the columns the select
+// is ordered by and their types so cursors created before the order or the
+// schema changed can be told apart
+func (co *Compiler) CursorPrefix(sel *qcode.Select) (string, error) {
+	ti, err := co.schema.GetTable(sel.Name)
+	if err != nil {
+		return "", err
+	}
+	return cursorPrefix(sel, ti), nil
+}
+
+func cursorPrefix(sel *qcode.Select, ti *DBTableInfo) string {
+	h := fnv.New32a()
+	io.WriteString(h, ti.Name)
+
+	for _, ob := range sel.OrderBy {
+		var colType string
+
+		if col, ok := ti.ColMap[ob.Col]; ok {
+			colType = col.Type
+		}
+		fmt.Fprintf(h, ",%s %s %d", ob.Col, colType, ob.Order)
+	}
+
+	return fmt.Sprintf("%s:%08x:", cursorVersion, h.Sum32())
+}
+
+// flipOrder returns the order that lists the rows in reverse
+func flipOrder(order qcode.Order) qcode.Order {
+	switch order {
+	case qcode.OrderAsc:
+		return qcode.OrderDesc
+	case qcode.OrderDesc:
+		return qcode.OrderAsc
+	case qcode.OrderAscNullsFirst:
+		return qcode.OrderDescNullsLast
+	case qcode.OrderAscNullsLast:
+		return qcode.OrderDescNullsFirst
+	case qcode.OrderDescNullsFirst:
+		return qcode.OrderAscNullsLast
+	case qcode.OrderDescNullsLast:
+		return qcode.OrderAscNullsFirst
+	}
+	return order
+}
+
+// nullsFirst returns true if nulls are listed before the other values
+// which is the default for descending orders in Postgres
+func nullsFirst(order qcode.Order) bool {
+	switch order {
+	case qcode.OrderDesc, qcode.OrderAscNullsFirst, qcode.OrderDescNullsFirst:
+		return true
+	}
+	return false
+}
+
+func isDesc(order qcode.Order) bool {
+	switch order {
+	case qcode.OrderDesc, qcode.OrderDescNullsFirst, qcode.OrderDescNullsLast:
+		return true
+	}
+	return false
+}
+
+// checkCursorOrder returns an error if the rows of the select cannot be
+// paged using cursors. Cursors hold the values of the columns the select
+// is ordered by and the primary key so distances and values at a JSON path
+// cannot be used.
+func checkCursorOrder(sel *qcode.Select, ti *DBTableInfo) error {
+	if ti.PrimaryCol == nil {
+		return fmt.Errorf("no primary key column on '%s' for cursor pagination", sel.Name)
+	}
+
+	for _, ob := range sel.OrderBy {
+		if ob.Geo != nil || len(ob.Path) != 0 {
+			return fmt.Errorf("order_by with distance_from or path on '%s' cannot be used with cursor pagination", ob.Col)
+		}
+	}
+
+	return nil
+}
+
+// renderCursorCTE renders the values of the cursor as a row with a column
+// for each of the columns the select is ordered by, the values are
+// all null when there's no cursor
+func (c *compilerContext) renderCursorCTE(sel *qcode.Select) {
+	io.WriteString(c.w, `WITH "__cur" AS (SELECT `)
+	for i, ob := range sel.OrderBy {
+		if i != 0 {
+			io.WriteString(c.w, `, `)
+		}
+		io.WriteString(c.w, `a->>`)
+		int2string(c.w, int32(i))
+		io.WriteString(c.w, ` AS `)
+		quoted(c.w, ob.Col)
+	}
+	io.WriteString(c.w, ` FROM (SELECT NULLIF('{{cursor}}', '') :: json AS a) AS "__cur_json") `)
+}
+
+// renderRowCursor renders the cursor of the row, the values of the columns
+// the select is ordered by prefixed with the version and fingerprint
+func (c *compilerContext) renderRowCursor(sel *qcode.Select, ti *DBTableInfo) error {
+	if err := checkCursorOrder(sel, ti); err != nil {
+		return err
+	}
+
+	io.WriteString(c.w, `CONCAT('`)
+	io.WriteString(c.w, cursorPrefix(sel, ti))
+	io.WriteString(c.w, `', json_build_array(`)
+	for i, ob := range sel.OrderBy {
+		if i != 0 {
+			io.WriteString(c.w, `, `)
+		}
+		colWithTableID(c.w, ti.Name, sel.ID, ob.Col)
+	}
+	io.WriteString(c.w, `))`)
+	return nil
+}
+
+// This
+// (A, B, C) > (X, Y, Z)
+//
+// Becomes
+// (A > X)
+//   OR ((A = X) AND (B > Y))
+//   OR ((A = X) AND (B = Y) AND (C > Z))
+//
+// with > and = replaced by their null aware versions for nullable
+// columns, the primary key is always one of the columns and since it's
+// never null there's no cursor when its value is null
+
+func (c *compilerContext) renderSeekPredicate(sel *qcode.Select, ti *DBTableInfo) error {
+	var pk string
+
+	if err := checkCursorOrder(sel, ti); err != nil {
+		return err
+	}
+
+	cols := make([]*DBColumn, len(sel.OrderBy))
+
+	for i, ob := range sel.OrderBy {
+		col, ok := ti.ColMap[ob.Col]
+		if !ok {
+			return fmt.Errorf("no column '%s' found", ob.Col)
+		}
+		if col.Name == ti.PrimaryCol.Name {
+			pk = ob.Col
+		}
+		cols[i] = col
+	}
+
+	io.WriteString(c.w, `((`)
+	colWithTable(c.w, "__cur", pk)
+	io.WriteString(c.w, ` IS NULL)`)
+
+	for i := range sel.OrderBy {
+		io.WriteString(c.w, ` OR (`)
+
+		for n, ob := range sel.OrderBy[:i+1] {
+			if n != 0 {
+				io.WriteString(c.w, ` AND `)
+			}
+
+			if n == i {
+				c.renderSeekAfter(ti, cols[n], ob)
+			} else {
+				c.renderSeekEquals(ti, cols[n], ob)
+			}
+		}
+		io.WriteString(c.w, `)`)
+	}
+
+	io.WriteString(c.w, `)`)
+	return nil
+}
+
+// renderSeekAfter renders if the value of the column is listed after the
+// value in the cursor
+func (c *compilerContext) renderSeekAfter(ti *DBTableInfo, col *DBColumn, ob *qcode.OrderBy) {
+	op := ` > `
+	if isDesc(ob.Order) {
+		op = ` < `
+	}
+
+	switch {
+	case col.NotNull || col.PrimaryKey:
+		io.WriteString(c.w, `(`)
+		colOrExpr(c.w, ti, ob.Col)
+		io.WriteString(c.w, op)
+		c.renderCursorVal(ob.Col, col)
+		io.WriteString(c.w, `)`)
+
+	// nulls are listed first so every value is after a null
+	case nullsFirst(ob.Order):
+		io.WriteString(c.w, `((`)
+		c.renderCursorVal(ob.Col, col)
+		io.WriteString(c.w, ` IS NULL AND `)
+		colOrExpr(c.w, ti, ob.Col)
+		io.WriteString(c.w, ` IS NOT NULL) OR `)
+		colOrExpr(c.w, ti, ob.Col)
+		io.WriteString(c.w, op)
+		c.renderCursorVal(ob.Col, col)
+		io.WriteString(c.w, `)`)
+
+	// nulls are listed last so no value is after a null
+	default:
+		io.WriteString(c.w, `(`)
+		c.renderCursorVal(ob.Col, col)
+		io.WriteString(c.w, ` IS NOT NULL AND (`)
+		colOrExpr(c.w, ti, ob.Col)
+		io.WriteString(c.w, op)
+		c.renderCursorVal(ob.Col, col)
+		io.WriteString(c.w, ` OR `)
+		colOrExpr(c.w, ti, ob.Col)
+		io.WriteString(c.w, ` IS NULL))`)
+	}
+}
+
+// renderSeekEquals renders if the value of the column is the same as the
+// value in the cursor
+func (c *compilerContext) renderSeekEquals(ti *DBTableInfo, col *DBColumn, ob *qcode.OrderBy) {
+	io.WriteString(c.w, `(`)
+	colOrExpr(c.w, ti, ob.Col)
+
+	if col.NotNull || col.PrimaryKey {
+		io.WriteString(c.w, ` = `)
+	} else {
+		io.WriteString(c.w, ` IS NOT DISTINCT FROM `)
+	}
+	c.renderCursorVal(ob.Col, col)
+	io.WriteString(c.w, `)`)
+}
+
+func (c *compilerContext) renderCursorVal(name string, col *DBColumn) {
+	io.WriteString(c.w, `(`)
+	colWithTable(c.w, "__cur", name)
+	io.WriteString(c.w, ` :: `)
+	io.WriteString(c.w, col.Type)
+	io.WriteString(c.w, `)`)
+}
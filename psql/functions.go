@@ -0,0 +1,252 @@
+//nolint:errcheck
+package psql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dosco/super-graph/qcode"
+	"github.com/gobuffalo/flect"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// DBFunction is a set returning function that is exposed as a table
+type DBFunction struct {
+	Name    string
+	Key     string
+	Table   string // table returned by 'SETOF table' functions
+	Params  []DBFuncParam
+	Columns []DBColumn // columns returned by 'TABLE(...)' functions
+}
+
+type DBFuncParam struct {
+	Name string
+	Type string
+}
+
+func GetFunctions(dbc *pgxpool.Conn) ([]DBFunction, error) {
+	sqlStmt := `
+SELECT
+	p.proname as "name",
+	coalesce(rt.relname, '') as "table",
+	coalesce(p.proargnames, '{}') as "arg_names",
+	coalesce(p.proargmodes :: text[], '{}') as "arg_modes",
+	ARRAY(SELECT pg_catalog.format_type(a.t, NULL)
+		FROM unnest(coalesce(p.proallargtypes, p.proargtypes :: oid[])) WITH ORDINALITY AS a(t, n)
+		ORDER BY a.n) as "arg_types"
+FROM pg_catalog.pg_proc p
+	LEFT JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+	LEFT JOIN pg_catalog.pg_type t ON t.oid = p.prorettype
+	LEFT JOIN pg_catalog.pg_class rt ON rt.oid = t.typrelid AND rt.relkind IN ('r','v','m','f')
+WHERE p.proretset
+	AND n.nspname <> ('pg_catalog')
+	AND n.nspname <> ('information_schema')
+	AND pg_catalog.pg_function_is_visible(p.oid)
+ORDER BY p.proname, p.oid;`
+
+	var funcs []DBFunction
+
+	rows, err := dbc.Query(context.Background(), sqlStmt)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching functions: %s", err)
+	}
+	defer rows.Close()
+
+	fmap := make(map[string]struct{})
+
+	for rows.Next() {
+		var names, modes, types []string
+		fn := DBFunction{}
+
+		err = rows.Scan(&fn.Name, &fn.Table, &names, &modes, &types)
+		if err != nil {
+			return nil, err
+		}
+		fn.Key = strings.ToLower(fn.Name)
+
+		// overloaded functions are not supported
+		if _, ok := fmap[fn.Key]; ok {
+			continue
+		}
+
+		if ok := fn.addParams(names, modes, types); ok {
+			funcs = append(funcs, fn)
+			fmap[fn.Key] = struct{}{}
+		}
+	}
+
+	return funcs, nil
+}
+
+// addParams adds the parameters and the returned columns of the function
+// using the names and modes of the arguments, it returns false if the
+// function cannot be used since its arguments don't have names or it
+// returns a set of a scalar type
+func (fn *DBFunction) addParams(names, modes, types []string) bool {
+	if len(names) != len(types) {
+		return false
+	}
+
+	for i := range types {
+		if len(names[i]) == 0 {
+			return false
+		}
+
+		mode := "i"
+
+		if len(modes) != 0 {
+			mode = modes[i]
+		}
+
+		switch mode {
+		case "i", "b":
+			fn.Params = append(fn.Params, DBFuncParam{Name: names[i], Type: types[i]})
+
+		case "t", "o":
+			fn.Columns = append(fn.Columns, DBColumn{
+				ID:   int16(len(fn.Columns)),
+				Name: names[i],
+				Key:  strings.ToLower(names[i]),
+				Type: types[i],
+			})
+
+		default:
+			return false
+		}
+	}
+
+	return len(fn.Table) != 0 || len(fn.Columns) != 0
+}
+
+// addFunction adds the function as a table with the columns of the table
+// it returns so it can be queried like that table. Tables and views take
+// precedence over functions with the same name.
+func (s *DBSchema) addFunction(fn DBFunction) {
+	if _, ok := s.t[fn.Key]; ok {
+		return
+	}
+
+	f := fn
+
+	if len(fn.Table) != 0 {
+		ti, ok := s.t[flect.Pluralize(strings.ToLower(fn.Table))]
+		if !ok {
+			return
+		}
+
+		fti := *ti
+		fti.Singular = false
+		fti.Func = &f
+		s.t[fn.Key] = &fti
+		return
+	}
+
+	colmap := make(map[string]*DBColumn, len(fn.Columns))
+	colidmap := make(map[int16]*DBColumn, len(fn.Columns))
+
+	for i := range f.Columns {
+		c := &f.Columns[i]
+		colmap[c.Key] = c
+		colidmap[c.ID] = c
+	}
+
+	s.t[fn.Key] = &DBTableInfo{
+		Name:     fn.Name,
+		Type:     "function",
+		Columns:  f.Columns,
+		ColMap:   colmap,
+		ColIDMap: colidmap,
+		Func:     &f,
+	}
+}
+
+// GetFunctions returns the functions that can be queried like tables
+func (s *DBSchema) GetFunctions() []*DBTableInfo {
+	var fns []*DBTableInfo
+
+	for k, ti := range s.t {
+		if ti.Func != nil && ti.Func.Key == k {
+			fns = append(fns, ti)
+		}
+	}
+
+	sort.Slice(fns, func(i, j int) bool { return fns[i].Func.Key < fns[j].Func.Key })
+
+	return fns
+}
+
+// renderFunction renders the call to the function using the arguments
+// of the select as named parameters
+func (c *compilerContext) renderFunction(sel *qcode.Select, ti *DBTableInfo) error {
+	fn := ti.Func
+	args := make(map[string]*qcode.Node, len(sel.Args))
+
+	for k, v := range sel.Args {
+		if k == "search" {
+			continue
+		}
+		args[strings.ToLower(k)] = v
+	}
+
+	for k := range args {
+		found := false
+		for _, p := range fn.Params {
+			if strings.ToLower(p.Name) == k {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("function '%s' has no parameter '%s'", fn.Name, k)
+		}
+	}
+
+	io.WriteString(c.w, fn.Name)
+	io.WriteString(c.w, `(`)
+
+	i := 0
+	for _, p := range fn.Params {
+		arg, ok := args[strings.ToLower(p.Name)]
+		if !ok {
+			continue
+		}
+
+		if i != 0 {
+			io.WriteString(c.w, `, `)
+		}
+
+		quoted(c.w, p.Name)
+		io.WriteString(c.w, ` => `)
+
+		if arg.Type == qcode.NodeVar {
+			val, ok := c.vars[arg.Val]
+			switch {
+			case ok && strings.HasPrefix(val, "sql:"):
+				io.WriteString(c.w, `(`)
+				io.WriteString(c.w, val[4:])
+				io.WriteString(c.w, `)`)
+			case ok:
+				squoted(c.w, val)
+			default:
+				io.WriteString(c.w, `'{{`)
+				io.WriteString(c.w, arg.Val)
+				io.WriteString(c.w, `}}'`)
+			}
+		} else {
+			squoted(c.w, arg.Val)
+		}
+
+		io.WriteString(c.w, ` :: `)
+		io.WriteString(c.w, p.Type)
+		i++
+	}
+
+	io.WriteString(c.w, `) AS `)
+	quoted(c.w, ti.Name)
+
+	return nil
+}
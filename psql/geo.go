@@ -0,0 +1,136 @@
+//nolint:errcheck
+package psql
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dosco/super-graph/qcode"
+)
+
+// GeoJSON geometries are always in WGS 84 coordinates
+const geoSRID = `4326`
+
+// geoType returns 'geometry' or 'geography' for PostGIS columns
+// and an empty string for all other types
+func geoType(colType string) string {
+	t := colType
+
+	if i := strings.IndexByte(t, '('); i != -1 {
+		t = t[:i]
+	}
+
+	switch t {
+	case "geometry", "geography":
+		return t
+	}
+	return ""
+}
+
+// renderGeoOp renders the spatial operators, st_contains and st_within
+// are not defined for geography so the column is cast to a geometry while
+// for st_dwithin it's cast to a geography so the distance is in meters
+func (c *compilerContext) renderGeoOp(ex *qcode.Exp, ti *DBTableInfo) error {
+	col, ok := ti.ColMap[ex.Col]
+	if !ok {
+		return fmt.Errorf("no column '%s' found ", ex.Col)
+	}
+
+	gt := geoType(col.Type)
+	if len(gt) == 0 {
+		return fmt.Errorf("column '%s' is not a geometry or geography", ex.Col)
+	}
+
+	switch ex.Op {
+	case qcode.OpStDWithin:
+		io.WriteString(c.w, `(ST_DWithin(`)
+	case qcode.OpStIntersects:
+		io.WriteString(c.w, `(ST_Intersects(`)
+	case qcode.OpStContains:
+		io.WriteString(c.w, `(ST_Contains(`)
+	case qcode.OpStWithin:
+		io.WriteString(c.w, `(ST_Within(`)
+	}
+
+	switch {
+	case ex.Op == qcode.OpStDWithin:
+		gt = "geography"
+	case gt == "geography" && (ex.Op == qcode.OpStContains || ex.Op == qcode.OpStWithin):
+		gt = "geometry"
+	}
+
+	colOrExpr(c.w, ti, ex.Col)
+	if gt != geoType(col.Type) {
+		io.WriteString(c.w, ` :: `)
+		io.WriteString(c.w, gt)
+	}
+	io.WriteString(c.w, `, `)
+	c.renderGeoVal(ex.Geo, gt)
+
+	if ex.Op == qcode.OpStDWithin {
+		io.WriteString(c.w, `, `)
+		if ex.Type == qcode.ValVar {
+			c.renderVar(ex.Val)
+			io.WriteString(c.w, ` :: float8`)
+		} else {
+			io.WriteString(c.w, ex.Val)
+		}
+	}
+
+	io.WriteString(c.w, `))`)
+	return nil
+}
+
+// renderGeoDistance renders the distance of the column from the geometry
+// in the order_by
+func (c *compilerContext) renderGeoDistance(ti *DBTableInfo, ob *qcode.OrderBy) error {
+	col, ok := ti.ColMap[ob.Col]
+	if !ok {
+		return fmt.Errorf("no column '%s' found ", ob.Col)
+	}
+
+	if len(geoType(col.Type)) == 0 {
+		return fmt.Errorf("order_by distance_from on '%s' needs a geometry or geography column", ob.Col)
+	}
+
+	// distances are in meters for geography
+	io.WriteString(c.w, `ST_Distance(`)
+	colOrExpr(c.w, ti, ob.Col)
+	if geoType(col.Type) != "geography" {
+		io.WriteString(c.w, ` :: geography`)
+	}
+	io.WriteString(c.w, `, `)
+	c.renderGeoVal(ob.Geo, "geography")
+	io.WriteString(c.w, `)`)
+	return nil
+}
+
+// renderGeoVal renders the point or GeoJSON value cast to the type of the column
+func (c *compilerContext) renderGeoVal(g *qcode.GeoVal, gt string) {
+	io.WriteString(c.w, `ST_SetSRID(`)
+
+	switch g.Type {
+	case qcode.ValList:
+		io.WriteString(c.w, `ST_MakePoint(`)
+		io.WriteString(c.w, g.Point[0])
+		io.WriteString(c.w, `, `)
+		io.WriteString(c.w, g.Point[1])
+		io.WriteString(c.w, `)`)
+
+	case qcode.ValVar:
+		io.WriteString(c.w, `ST_GeomFromGeoJSON(`)
+		c.renderVar(g.Val)
+		io.WriteString(c.w, `)`)
+
+	default:
+		io.WriteString(c.w, `ST_GeomFromGeoJSON(`)
+		squoted(c.w, g.Val)
+		io.WriteString(c.w, `)`)
+	}
+
+	io.WriteString(c.w, `, `)
+	io.WriteString(c.w, geoSRID)
+	io.WriteString(c.w, `) :: `)
+	io.WriteString(c.w, gt)
+}
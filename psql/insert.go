@@ -80,7 +80,7 @@ func (c *compilerContext) renderInsertStmt(qc *qcode.QCode, w io.Writer, item re
 	io.WriteString(w, ` AS (`)
 
 	io.WriteString(w, `INSERT INTO `)
-	quoted(w, ti.Name)
+	quotedTable(w, ti)
 	io.WriteString(w, ` (`)
 	renderInsertUpdateColumns(w, qc, jt, ti, sk, false)
 	renderNestedInsertRelColumns(w, item.kvitem, false)
@@ -100,7 +100,7 @@ func (c *compilerContext) renderInsertStmt(qc *qcode.QCode, w io.Writer, item re
 	}
 
 	io.WriteString(w, `(NULL::`)
-	io.WriteString(w, ti.Name)
+	rowType(w, ti)
 
 	if len(item.path) == 0 {
 		io.WriteString(w, `, i.j) t RETURNING *)`)
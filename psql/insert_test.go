@@ -49,6 +49,21 @@ func bulkInsert(t *testing.T) {
 	compileGQLToPSQL(t, gql, vars, "anon")
 }
 
+func insertInOtherSchema(t *testing.T) {
+	gql := `mutation {
+		billing_invoice(insert: $data) {
+			id
+			amount
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`{"amount": 25.50, "user_id": 5}`),
+	}
+
+	compileGQLToPSQL(t, gql, vars, "admin")
+}
+
 func simpleInsertWithPresets(t *testing.T) {
 	gql := `mutation {
 		product(insert: $data) {
@@ -261,6 +276,7 @@ func TestCompileInsert(t *testing.T) {
 	t.Run("simpleInsert", simpleInsert)
 	t.Run("singleInsert", singleInsert)
 	t.Run("bulkInsert", bulkInsert)
+	t.Run("insertInOtherSchema", insertInOtherSchema)
 	t.Run("simpleInsertWithPresets", simpleInsertWithPresets)
 	t.Run("nestedInsertManyToMany", nestedInsertManyToMany)
 	t.Run("nestedInsertOneToMany", nestedInsertOneToMany)
@@ -0,0 +1,125 @@
+//nolint:errcheck
+package psql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dosco/super-graph/qcode"
+)
+
+var jsonPathCol = &DBColumn{Name: "path", Type: "jsonpath"}
+
+func isJSONType(colType string) bool {
+	return colType == "json" || colType == "jsonb"
+}
+
+// renderJSONPathOp renders the value at the path in the JSON column of the
+// expression. The value is compared as JSON for the JSON operators else as
+// text cast to the type of the value it's compared with, for a variable
+// this is the type of its value in the request. The returned column has
+// the type the value is cast to.
+func (c *compilerContext) renderJSONPathOp(ex *qcode.Exp, ti *DBTableInfo, col *DBColumn) (*DBColumn, error) {
+	if !isJSONType(col.Type) {
+		return nil, fmt.Errorf("path on column '%s' needs a json or jsonb column", col.Name)
+	}
+
+	switch ex.Op {
+	case qcode.OpContains, qcode.OpContainedIn,
+		qcode.OpHasKey, qcode.OpHasKeyAny, qcode.OpHasKeyAll:
+		c.renderJSONPath(ti, col.Name, ex.Path, false)
+		return col, nil
+	}
+
+	t := "text"
+
+	vt := ex.Type
+	switch vt {
+	case qcode.ValList:
+		vt = ex.ListType
+	case qcode.ValVar:
+		vt = jsonValType(c.qvars[ex.Val])
+	}
+
+	switch {
+	case ex.Op == qcode.OpIsNull:
+		break
+	case vt == qcode.ValInt || vt == qcode.ValFloat:
+		t = "numeric"
+	case vt == qcode.ValBool:
+		t = "boolean"
+	}
+
+	if t != "text" {
+		io.WriteString(c.w, `(`)
+	}
+
+	c.renderJSONPath(ti, col.Name, ex.Path, true)
+
+	if t != "text" {
+		io.WriteString(c.w, `) :: `)
+		io.WriteString(c.w, t)
+	}
+
+	return &DBColumn{Name: col.Name, Type: t}, nil
+}
+
+// jsonValType returns the type of the JSON value, strings and values
+// that are missing are compared as text
+func jsonValType(v json.RawMessage) qcode.ValType {
+	v = bytes.TrimSpace(v)
+
+	switch {
+	case len(v) == 0:
+		return qcode.ValStr
+	case v[0] == '-' || (v[0] >= '0' && v[0] <= '9'):
+		return qcode.ValFloat
+	case bytes.Equal(v, []byte("true")) || bytes.Equal(v, []byte("false")):
+		return qcode.ValBool
+	}
+
+	return qcode.ValStr
+}
+
+// renderJSONPath renders the value at the path in the JSON column either
+// as text or as JSON
+func (c *compilerContext) renderJSONPath(ti *DBTableInfo, col string, path []string, asText bool) {
+	colOrExpr(c.w, ti, col)
+
+	if asText {
+		io.WriteString(c.w, ` #>> `)
+	} else {
+		io.WriteString(c.w, ` #> `)
+	}
+
+	io.WriteString(c.w, `'{`)
+	io.WriteString(c.w, strings.Join(path, ","))
+	io.WriteString(c.w, `}'`)
+}
+
+// renderJSONPathOrder renders the value at the path in the order_by, JSON
+// values are ordered by their type first, then numbers and strings are
+// ordered by their value
+func (c *compilerContext) renderJSONPathOrder(ti *DBTableInfo, ob *qcode.OrderBy) error {
+	col, ok := ti.ColMap[ob.Col]
+	if !ok {
+		return fmt.Errorf("no column '%s' found ", ob.Col)
+	}
+
+	if !isJSONType(col.Type) {
+		return fmt.Errorf("order_by path on '%s' needs a json or jsonb column", ob.Col)
+	}
+
+	io.WriteString(c.w, `(`)
+	c.renderJSONPath(ti, ob.Col, ob.Path, false)
+	io.WriteString(c.w, `)`)
+
+	// json values cannot be ordered so they're cast to jsonb
+	if col.Type == "json" {
+		io.WriteString(c.w, ` :: jsonb`)
+	}
+	return nil
+}
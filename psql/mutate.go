@@ -38,7 +38,7 @@ func (co *Compiler) compileMutation(qc *qcode.QCode, w io.Writer, vars Variables
 		return 0, errors.New("empty query")
 	}
 
-	c := &compilerContext{w, qc.Selects, co}
+	c := &compilerContext{w, qc.Selects, vars, co}
 	root := &qc.Selects[0]
 
 	ti, err := c.schema.GetTable(root.Name)
@@ -154,6 +154,14 @@ func (c *compilerContext) handleKVItem(st *util.Stack, item kvitem) error {
 
 			// Get parent-to-child relationship
 		} else if relPC, err := c.schema.GetRel(item.key, k); err == nil {
+			if len(relPC.Left.Cols) != 0 || len(relCP.Left.Cols) != 0 {
+				return fmt.Errorf("nested mutations on '%s' using a composite foreign key are not supported", k)
+			}
+
+			if relPC.Type == RelPolymorphic || relCP.Type == RelPolymorphic {
+				return fmt.Errorf("nested mutations on '%s' using a polymorphic relationship are not supported", k)
+			}
+
 			ti, err := c.schema.GetTable(k)
 			if err != nil {
 				return err
@@ -269,7 +277,7 @@ func (c *compilerContext) renderUnionStmt(w io.Writer, item renitem) error {
 			quoted(w, item.ti.Name)
 		}
 		io.WriteString(w, ` AS ( UPDATE `)
-		quoted(w, item.ti.Name)
+		quotedTable(w, item.ti)
 		io.WriteString(w, ` SET `)
 		quoted(w, item.relPC.Right.Col)
 		io.WriteString(w, ` = `)
@@ -310,7 +318,7 @@ func (c *compilerContext) renderUnionStmt(w io.Writer, item renitem) error {
 			quoted(w, item.ti.Name)
 		}
 		io.WriteString(w, ` AS ( UPDATE `)
-		quoted(w, item.ti.Name)
+		quotedTable(w, item.ti)
 		io.WriteString(w, ` SET `)
 		quoted(w, item.relPC.Right.Col)
 		io.WriteString(w, ` = `)
@@ -410,7 +418,7 @@ func renderInsertUpdateColumns(w io.Writer,
 	for i := range root.PresetList {
 		cn := root.PresetList[i]
 		col, ok := ti.ColMap[cn]
-		if !ok {
+		if !ok || col.IsComputed() {
 			continue
 		}
 		if _, ok := skipcols[col.Name]; ok {
@@ -546,7 +554,7 @@ func (c *compilerContext) renderConnectStmt(qc *qcode.QCode, w io.Writer,
 	}
 
 	io.WriteString(c.w, ` FROM "_sg_input" i,`)
-	quoted(c.w, item.ti.Name)
+	quotedTable(c.w, item.ti)
 
 	io.WriteString(c.w, ` WHERE `)
 	if err := renderWhereFromJSON(c.w, item.kvitem, "connect", item.kvitem.val); err != nil {
@@ -577,7 +585,7 @@ func (c *compilerContext) renderDisconnectStmt(qc *qcode.QCode, w io.Writer,
 		io.WriteString(c.w, `SELECT `)
 		quoted(w, rel.Right.Col)
 		io.WriteString(c.w, ` FROM "_sg_input" i,`)
-		quoted(c.w, item.ti.Name)
+		quotedTable(c.w, item.ti)
 		io.WriteString(c.w, ` WHERE `)
 		if err := renderWhereFromJSON(c.w, item.kvitem, "connect", item.kvitem.val); err != nil {
 			return err
@@ -605,7 +613,7 @@ func renderWhereFromJSON(w io.Writer, item kvitem, key string, val []byte) error
 	i := 0
 	for k, v := range kv {
 		col, ok := ti.ColMap[k]
-		if !ok {
+		if !ok || col.IsComputed() {
 			continue
 		}
 		if i != 0 {
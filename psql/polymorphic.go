@@ -0,0 +1,208 @@
+//nolint:errcheck
+package psql
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dosco/super-graph/qcode"
+)
+
+// DBPolyType maps a value in the type column of a polymorphic
+// relationship to the table it points to
+type DBPolyType struct {
+	Value string
+	Table string
+}
+
+// AddPolymorphicRel adds a relationship named name from table to the tables
+// in types using the value in typeCol to pick the table and idCol as the
+// foreign key. The tables in types also get a relationship back to table.
+func (s *DBSchema) AddPolymorphicRel(table, name, typeCol, idCol string, types []DBPolyType) error {
+	ti, err := s.GetTable(table)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := s.t[name]; ok {
+		return fmt.Errorf("polymorphic relationship '%s' on table '%s' conflicts with a table", name, table)
+	}
+
+	tc, ok := ti.ColMap[strings.ToLower(typeCol)]
+	if !ok {
+		return fmt.Errorf("invalid type column '%s' for table '%s'", typeCol, table)
+	}
+
+	ic, ok := ti.ColMap[strings.ToLower(idCol)]
+	if !ok {
+		return fmt.Errorf("invalid id column '%s' for table '%s'", idCol, table)
+	}
+
+	if len(types) == 0 {
+		return fmt.Errorf("no types for polymorphic relationship '%s' on table '%s'", name, table)
+	}
+
+	rel := &DBRel{Type: RelPolymorphic, TypeCol: tc.Name, Types: types}
+	rel.Left.col = ic
+	rel.Left.Table = ti.Name
+	rel.Left.Col = ic.Name
+	rel.Right.Table = name
+
+	for _, v := range types {
+		pti, err := s.GetTable(v.Table)
+		if err != nil {
+			return err
+		}
+
+		if pti.PrimaryCol == nil {
+			return fmt.Errorf("no primary key column on table '%s' for polymorphic relationship '%s'",
+				v.Table, name)
+		}
+
+		// The relationship back from the table in the type to this table
+		rel1 := &DBRel{Type: RelPolymorphic, TypeCol: tc.Name, Types: []DBPolyType{v}}
+		rel1.Left.col = ic
+		rel1.Left.Table = ti.Name
+		rel1.Left.Col = ic.Name
+		rel1.Right.col = pti.PrimaryCol
+		rel1.Right.Table = pti.Name
+		rel1.Right.Col = pti.PrimaryCol.Name
+
+		if err := s.SetRel(table, v.Table, rel1); err != nil {
+			return err
+		}
+	}
+
+	s.t[name] = &DBTableInfo{
+		Name:     name,
+		Type:     "polymorphic",
+		Singular: true,
+		ColMap:   make(map[string]*DBColumn),
+		ColIDMap: make(map[int16]*DBColumn),
+	}
+
+	return s.SetRel(name, table, rel)
+}
+
+// renderPolymorphic renders a union of selects on the tables the relationship
+// can point to, only the select on the table named by the value in the type
+// column of the parent returns a row. Each select has the filters and columns
+// of the role for its table, tables the role cannot query are left out.
+func (c *compilerContext) renderPolymorphic(sel *qcode.Select, rel *DBRel) error {
+	if len(sel.Children) != 0 {
+		return fmt.Errorf("polymorphic relationship '%s' cannot have nested tables", sel.FieldName)
+	}
+
+	if sel.Where != nil {
+		return fmt.Errorf("polymorphic relationship '%s' cannot be filtered", sel.FieldName)
+	}
+
+	parent := &c.s[sel.ParentID]
+
+	pti, err := c.schema.GetTable(parent.Name)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+
+	for _, v := range rel.Types {
+		pt := polyType(sel, v.Table)
+		if pt == nil || pt.Skip {
+			continue
+		}
+
+		ti, err := c.schema.GetTable(v.Table)
+		if err != nil {
+			return err
+		}
+
+		if i != 0 {
+			io.WriteString(c.w, ` UNION ALL `)
+		}
+		i++
+
+		io.WriteString(c.w, `SELECT json_build_object(`)
+		c.renderPolymorphicColumns(sel, pt, ti)
+		io.WriteString(c.w, `) AS "json" FROM `)
+		quotedTable(c.w, ti)
+
+		io.WriteString(c.w, ` WHERE (((`)
+		colWithTableID(c.w, pti.Name, parent.ID, rel.TypeCol)
+		io.WriteString(c.w, `) = `)
+		squoted(c.w, v.Value)
+		io.WriteString(c.w, `) AND ((`)
+		colWithTable(c.w, ti.Name, ti.PrimaryCol.Name)
+		io.WriteString(c.w, `) = (`)
+		colWithTableID(c.w, pti.Name, parent.ID, rel.Left.Col)
+		io.WriteString(c.w, `))`)
+
+		if pt.Where != nil && pt.Where.Op != qcode.OpNop {
+			io.WriteString(c.w, ` AND `)
+			if err := c.renderExp(pt.Where, ti, false); err != nil {
+				return err
+			}
+		}
+		io.WriteString(c.w, `)`)
+	}
+
+	if i == 0 {
+		return fmt.Errorf("polymorphic relationship '%s' has no tables that can be queried", sel.FieldName)
+	}
+
+	return nil
+}
+
+// polyType returns the config of the role for the table of the polymorphic
+// relationship or nil if there's none
+func polyType(sel *qcode.Select, table string) *qcode.PolyType {
+	for i := range sel.PolyTypes {
+		if strings.EqualFold(sel.PolyTypes[i].Table, table) {
+			return &sel.PolyTypes[i]
+		}
+	}
+	return nil
+}
+
+// renderPolymorphicColumns renders the columns of the select found on the
+// table and allowed for the role and not from a fragment on another table,
+// '__typename' is set to the name of the table
+func (c *compilerContext) renderPolymorphicColumns(sel *qcode.Select, pt *qcode.PolyType, ti *DBTableInfo) {
+	i := 0
+
+	for _, col := range sel.Cols {
+		if len(pt.Allowed) != 0 && col.Name != "__typename" {
+			if _, ok := pt.Allowed[col.Name]; !ok {
+				continue
+			}
+		}
+
+		// fields from a fragment on another table
+		if len(col.On) != 0 && !c.isType(col.On, ti, nil) {
+			continue
+		}
+
+		_, isRealCol := ti.ColMap[col.Name]
+
+		if !isRealCol && col.Name != "__typename" {
+			continue
+		}
+
+		if i != 0 {
+			io.WriteString(c.w, ", ")
+		}
+
+		squoted(c.w, col.FieldName)
+		io.WriteString(c.w, ", ")
+
+		if isRealCol {
+			colOrExpr(c.w, ti, col.Name)
+		} else {
+			io.WriteString(c.w, `(`)
+			squoted(c.w, ti.Name)
+			io.WriteString(c.w, ` :: text)`)
+		}
+		i++
+	}
+}
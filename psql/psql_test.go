@@ -39,7 +39,7 @@ func TestMain(m *testing.M) {
 
 	err = qcompile.AddRole("user", "product", qcode.TRConfig{
 		Query: qcode.QueryConfig{
-			Columns: []string{"id", "name", "price", "users", "customers"},
+			Columns: []string{"id", "name", "price", "discount_price", "users", "customers"},
 			Filters: []string{
 				"{ price: { gt: 0 } }",
 				"{ price: { lt: 8 } }",
@@ -76,6 +76,15 @@ func TestMain(m *testing.M) {
 		log.Fatal(err)
 	}
 
+	err = qcompile.AddRole("anon", "comments", qcode.TRConfig{
+		Query: qcode.QueryConfig{
+			Columns: []string{"id", "body", "commentable"},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	err = qcompile.AddRole("anon1", "product", qcode.TRConfig{
 		Query: qcode.QueryConfig{
 			Columns:          []string{"id", "name", "price"},
@@ -135,6 +144,46 @@ func TestMain(m *testing.M) {
 
 	schema := getTestSchema()
 
+	err = schema.AddComputedColumn("products", DBColumn{
+		Name: "discount_price",
+		Key:  "discount_price",
+		Type: "numeric(7,2)",
+		Expr: `"products"."price" * 0.9`,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = schema.AddComputedColumn("users", DBColumn{
+		Name: "display_name",
+		Key:  "display_name",
+		Type: "text",
+		Func: "display_name",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = schema.AddComputedColumn("billing_invoices", DBColumn{
+		Name: "amount_due",
+		Key:  "amount_due",
+		Type: "numeric(7,2)",
+		Func: "amount_due",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	qcompile.AddPolymorphic("commentable", []string{"products", "users"})
+
+	for _, ti := range schema.GetFunctions() {
+		if len(ti.Func.Table) != 0 {
+			qcompile.AddFunction(ti.Func.Key, ti.Name)
+		} else {
+			qcompile.AddFunction(ti.Func.Key, "")
+		}
+	}
+
 	vars := NewVariables(map[string]string{
 		"admin_account_id": "5",
 	})
@@ -58,8 +58,9 @@ func (c *Compiler) IDColumn(table string) (*DBColumn, error) {
 }
 
 type compilerContext struct {
-	w io.Writer
-	s []qcode.Select
+	w     io.Writer
+	s     []qcode.Select
+	qvars Variables // variables of the request
 	*Compiler
 }
 
@@ -85,7 +86,7 @@ func (co *Compiler) compileQuery(qc *qcode.QCode, w io.Writer, vars Variables) (
 		return 0, errors.New("empty query")
 	}
 
-	c := &compilerContext{w, qc.Selects, co}
+	c := &compilerContext{w, qc.Selects, vars, co}
 
 	st := NewIntStack()
 	i := 0
@@ -93,7 +94,7 @@ func (co *Compiler) compileQuery(qc *qcode.QCode, w io.Writer, vars Variables) (
 	io.WriteString(c.w, `SELECT json_build_object(`)
 	for _, id := range qc.Roots {
 		root := &qc.Selects[id]
-		if root.SkipRender || len(root.Cols) == 0 {
+		if root.SkipRender || (len(root.Cols) == 0 && root.Connection == nil) {
 			continue
 		}
 
@@ -126,7 +127,7 @@ func (co *Compiler) compileQuery(qc *qcode.QCode, w io.Writer, vars Variables) (
 		if id < closeBlock {
 			sel := &c.s[id]
 
-			if len(sel.Cols) == 0 {
+			if len(sel.Cols) == 0 && sel.Connection == nil {
 				continue
 			}
 
@@ -141,7 +142,7 @@ func (co *Compiler) compileQuery(qc *qcode.QCode, w io.Writer, vars Variables) (
 				c.renderLateralJoin(sel)
 			}
 
-			if !ti.Singular {
+			if !isSingular(sel, ti) {
 				c.renderPluralSelect(sel, ti)
 			}
 
@@ -172,7 +173,7 @@ func (co *Compiler) compileQuery(qc *qcode.QCode, w io.Writer, vars Variables) (
 				return 0, err
 			}
 
-			if !ti.Singular {
+			if !isSingular(sel, ti) {
 				io.WriteString(c.w, `)`)
 				aliasWithID(c.w, "__sel", sel.ID)
 			}
@@ -202,35 +203,16 @@ func (co *Compiler) compileQuery(qc *qcode.QCode, w io.Writer, vars Variables) (
 }
 
 func (c *compilerContext) renderPluralSelect(sel *qcode.Select, ti *DBTableInfo) error {
+	if sel.Connection != nil {
+		return c.renderConnectionSelect(sel, ti)
+	}
+
 	io.WriteString(c.w, `SELECT coalesce(json_agg("__sel_`)
 	int2string(c.w, sel.ID)
 	io.WriteString(c.w, `"."json"), '[]') as "json"`)
 
 	if sel.Paging.Type != qcode.PtOffset {
-		n := 0
-
-		// check if primary key already included in order by
-		// query argument
-		for _, ob := range sel.OrderBy {
-			if ob.Col == ti.PrimaryCol.Key {
-				n = 1
-				break
-			}
-		}
-
-		if n == 1 {
-			n = len(sel.OrderBy)
-		} else {
-			n = len(sel.OrderBy) + 1
-		}
-
-		io.WriteString(c.w, `, CONCAT_WS(','`)
-		for i := 0; i < n; i++ {
-			io.WriteString(c.w, `, max("__cur_`)
-			int2string(c.w, int32(i))
-			io.WriteString(c.w, `")`)
-		}
-		io.WriteString(c.w, `) as "cursor"`)
+		io.WriteString(c.w, `, max("__cursor") as "cursor"`)
 	}
 
 	io.WriteString(c.w, ` FROM (`)
@@ -246,7 +228,7 @@ func (c *compilerContext) renderRootSelect(sel *qcode.Select) error {
 	int2string(c.w, sel.ID)
 	io.WriteString(c.w, `"."json"`)
 
-	if sel.Paging.Type != qcode.PtOffset {
+	if sel.Paging.Type != qcode.PtOffset && sel.Connection == nil {
 		io.WriteString(c.w, `, '`)
 		io.WriteString(c.w, sel.FieldName)
 		io.WriteString(c.w, `_cursor', `)
@@ -274,30 +256,32 @@ func (c *compilerContext) initSelect(sel *qcode.Select, ti *DBTableInfo, vars Va
 	}
 
 	if sel.Paging.Type != qcode.PtOffset {
+		if err := checkCursorOrder(sel, ti); err != nil {
+			return 0, nil, err
+		}
 		colmap[ti.PrimaryCol.Key] = struct{}{}
 		addPrimaryKey := true
 
 		for _, ob := range sel.OrderBy {
 			if ob.Col == ti.PrimaryCol.Key {
 				addPrimaryKey = false
-				break
 			}
 		}
 
+		// the primary key breaks ties between rows with the same values
+		// so every row has a unique cursor
 		if addPrimaryKey {
-			ob := &qcode.OrderBy{Col: ti.PrimaryCol.Name, Order: qcode.OrderAsc}
+			sel.OrderBy = append(sel.OrderBy, &qcode.OrderBy{Col: ti.PrimaryCol.Name, Order: qcode.OrderAsc})
+		}
 
-			if sel.Paging.Type == qcode.PtBackward {
-				ob.Order = qcode.OrderDesc
+		// the last rows are fetched in the reverse order
+		if sel.Paging.Type == qcode.PtBackward {
+			for _, ob := range sel.OrderBy {
+				ob.Order = flipOrder(ob.Order)
 			}
-			sel.OrderBy = append(sel.OrderBy, ob)
 		}
 	}
 
-	if sel.Paging.Cursor {
-		c.addSeekPredicate(sel)
-	}
-
 	for _, id := range sel.Children {
 		child := &c.s[id]
 
@@ -310,9 +294,16 @@ func (c *compilerContext) initSelect(sel *qcode.Select, ti *DBTableInfo, vars Va
 
 		switch rel.Type {
 		case RelOneToOne, RelOneToMany:
-			if _, ok := colmap[rel.Right.Col]; !ok {
-				cols = append(cols, &qcode.Column{Table: ti.Name, Name: rel.Right.Col, FieldName: rel.Right.Col})
-				colmap[rel.Right.Col] = struct{}{}
+			rcols := rel.Right.Cols
+			if len(rcols) == 0 {
+				rcols = []string{rel.Right.Col}
+			}
+
+			for _, col := range rcols {
+				if _, ok := colmap[col]; !ok {
+					cols = append(cols, &qcode.Column{Table: ti.Name, Name: col, FieldName: col})
+					colmap[col] = struct{}{}
+				}
 			}
 
 		case RelOneToManyThrough:
@@ -327,6 +318,21 @@ func (c *compilerContext) initSelect(sel *qcode.Select, ti *DBTableInfo, vars Va
 				colmap[rel.Left.Col] = struct{}{}
 			}
 
+		case RelPolymorphic:
+			// the table with the type and id columns is either
+			// this table or the child table
+			if rel.Left.Table == ti.Name {
+				for _, col := range []string{rel.TypeCol, rel.Left.Col} {
+					if _, ok := colmap[col]; !ok {
+						cols = append(cols, &qcode.Column{Table: ti.Name, Name: col, FieldName: col})
+						colmap[col] = struct{}{}
+					}
+				}
+			} else if _, ok := colmap[rel.Right.Col]; !ok {
+				cols = append(cols, &qcode.Column{Table: ti.Name, Name: rel.Right.Col, FieldName: rel.Right.Col})
+				colmap[rel.Right.Col] = struct{}{}
+			}
+
 		case RelRemote:
 			if _, ok := colmap[rel.Left.Col]; !ok {
 				cols = append(cols, &qcode.Column{Table: ti.Name, Name: rel.Left.Col, FieldName: rel.Right.Col})
@@ -343,70 +349,55 @@ func (c *compilerContext) initSelect(sel *qcode.Select, ti *DBTableInfo, vars Va
 	return skipped, cols, nil
 }
 
-// This
-// (A, B, C) >= (X, Y, Z)
-//
-// Becomes
-// (A > X)
-//   OR ((A = X) AND (B > Y))
-//   OR ((A = X) AND (B = Y) AND (C > Z))
-//   OR ((A = X) AND (B = Y) AND (C = Z))
-
-func (c *compilerContext) addSeekPredicate(sel *qcode.Select) error {
-	var or, and *qcode.Exp
+// checkTypeConds returns an error if a fragment with a type condition is
+// used on a table other than the one it's on, for a polymorphic relationship
+// it can be on any of the tables the relationship points to
+func (c *compilerContext) checkTypeConds(sel *qcode.Select, ti *DBTableInfo, rel *DBRel) error {
+	if len(sel.On) != 0 && sel.ParentID != -1 {
+		parent := &c.s[sel.ParentID]
 
-	obLen := len(sel.OrderBy)
+		pti, err := c.schema.GetTable(parent.Name)
+		if err != nil {
+			return err
+		}
 
-	if obLen > 1 {
-		or = qcode.NewFilter()
-		or.Op = qcode.OpOr
+		if !c.isType(sel.On, pti, nil) {
+			return fmt.Errorf("fragment on '%s' cannot be used on '%s'", sel.On, parent.FieldName)
+		}
 	}
 
-	for i := 0; i < obLen; i++ {
-		if i > 0 {
-			and = qcode.NewFilter()
-			and.Op = qcode.OpAnd
+	for _, col := range sel.Cols {
+		if len(col.On) != 0 && !c.isType(col.On, ti, rel) {
+			return fmt.Errorf("fragment on '%s' cannot be used on '%s'", col.On, sel.FieldName)
 		}
+	}
 
-		for n, ob := range sel.OrderBy {
-			f := qcode.NewFilter()
-			f.Col = ob.Col
-			f.Type = qcode.ValRef
-			f.Table = "__cur"
-			f.Val = ob.Col
-
-			if obLen == 1 {
-				qcode.AddFilter(sel, f)
-				return nil
-			}
+	return nil
+}
 
-			switch {
-			case i > 0 && n != i:
-				f.Op = qcode.OpEquals
-			case ob.Order == qcode.OrderDesc:
-				f.Op = qcode.OpLesserThan
-			default:
-				f.Op = qcode.OpGreaterThan
-			}
+// isType returns true if the type named name is the table or one of the
+// tables the polymorphic relationship points to
+func (c *compilerContext) isType(name string, ti *DBTableInfo, rel *DBRel) bool {
+	t, err := c.schema.GetTable(name)
+	if err != nil {
+		return false
+	}
 
-			if and != nil {
-				and.Children = append(and.Children, f)
-			} else {
-				or.Children = append(or.Children, f)
-			}
+	if ti.Type != "polymorphic" {
+		return t.Name == ti.Name
+	}
 
-			if n == i {
-				break
-			}
-		}
+	if rel == nil {
+		return false
+	}
 
-		if and != nil {
-			or.Children = append(or.Children, and)
+	for _, v := range rel.Types {
+		if pt, err := c.schema.GetTable(v.Table); err == nil && pt.Name == t.Name {
+			return true
 		}
 	}
 
-	qcode.AddFilter(sel, or)
-	return nil
+	return false
 }
 
 func (c *compilerContext) renderSelect(sel *qcode.Select, ti *DBTableInfo, vars Variables) (uint32, error) {
@@ -422,26 +413,41 @@ func (c *compilerContext) renderSelect(sel *qcode.Select, ti *DBTableInfo, vars
 		}
 	}
 
+	if err := c.checkTypeConds(sel, ti, rel); err != nil {
+		return 0, err
+	}
+
+	if ti.Type == "polymorphic" {
+		if rel == nil || rel.Type != RelPolymorphic {
+			return 0, fmt.Errorf("polymorphic relationship '%s' can only be used on its table", sel.FieldName)
+		}
+		return 0, c.renderPolymorphic(sel, rel)
+	}
+
 	skipped, childCols, err := c.initSelect(sel, ti, vars)
 	if err != nil {
 		return 0, err
 	}
 
 	// SELECT
-	io.WriteString(c.w, `SELECT json_build_object(`)
-	if err := c.renderColumns(sel, ti, skipped); err != nil {
-		return 0, err
+	if sel.Connection != nil {
+		if err := c.renderConnectionColumns(sel, ti, skipped); err != nil {
+			return 0, err
+		}
+	} else {
+		io.WriteString(c.w, `SELECT json_build_object(`)
+		if err := c.renderColumns(sel, ti, skipped); err != nil {
+			return 0, err
+		}
+		io.WriteString(c.w, `) AS "json"`)
 	}
-	io.WriteString(c.w, `) AS "json"`)
 
-	if sel.Paging.Type != qcode.PtOffset {
-		for i, ob := range sel.OrderBy {
-			io.WriteString(c.w, `, LAST_VALUE(`)
-			colWithTableID(c.w, ti.Name, sel.ID, ob.Col)
-			io.WriteString(c.w, `) OVER() AS "__cur_`)
-			int2string(c.w, int32(i))
-			io.WriteString(c.w, `"`)
+	if sel.Paging.Type != qcode.PtOffset && sel.Connection == nil {
+		io.WriteString(c.w, `, LAST_VALUE(`)
+		if err := c.renderRowCursor(sel, ti); err != nil {
+			return 0, err
 		}
+		io.WriteString(c.w, `) OVER() AS "__cursor"`)
 	}
 
 	io.WriteString(c.w, ` FROM (`)
@@ -497,9 +503,14 @@ func (c *compilerContext) renderJoinByName(table, parent string, id int32) error
 
 	//fmt.Fprintf(w, ` LEFT OUTER JOIN "%s" ON (("%s"."%s") = ("%s_%d"."%s"))`,
 	//rel.Through, rel.Through, rel.ColT, c.parent.Name, c.parent.ID, rel.Left.Col)
-	io.WriteString(c.w, ` LEFT OUTER JOIN "`)
-	io.WriteString(c.w, rel.Through)
-	io.WriteString(c.w, `" ON ((`)
+	tt, err := c.schema.GetTable(strings.ToLower(rel.Through))
+	if err != nil {
+		return err
+	}
+
+	io.WriteString(c.w, ` LEFT OUTER JOIN `)
+	quotedTable(c.w, tt)
+	io.WriteString(c.w, ` ON ((`)
 	colWithTable(c.w, rel.Through, rel.ColT)
 	io.WriteString(c.w, `) = (`)
 	colWithTableID(c.w, pt.Name, id, rel.Left.Col)
@@ -596,7 +607,7 @@ func (c *compilerContext) renderJoinColumns(sel *qcode.Select, ti *DBTableInfo,
 		int2string(c.w, childSel.ID)
 		io.WriteString(c.w, `"."json"`)
 
-		if childSel.Paging.Type != qcode.PtOffset {
+		if childSel.Paging.Type != qcode.PtOffset && childSel.Connection == nil {
 			io.WriteString(c.w, `, '`)
 			io.WriteString(c.w, childSel.FieldName)
 			io.WriteString(c.w, `_cursor', "__sel_`)
@@ -612,8 +623,6 @@ func (c *compilerContext) renderJoinColumns(sel *qcode.Select, ti *DBTableInfo,
 
 func (c *compilerContext) renderBaseSelect(sel *qcode.Select, ti *DBTableInfo, rel *DBRel,
 	childCols []*qcode.Column, skipped uint32) error {
-	isRoot := (rel == nil)
-	isFil := (sel.Where != nil && sel.Where.Op != qcode.OpNop)
 	hasOrder := len(sel.OrderBy) != 0
 
 	if sel.Paging.Cursor {
@@ -633,41 +642,21 @@ func (c *compilerContext) renderBaseSelect(sel *qcode.Select, ti *DBTableInfo, r
 
 	io.WriteString(c.w, ` FROM `)
 
-	c.renderFrom(sel, ti, rel)
-
-	if isRoot && isFil {
-		io.WriteString(c.w, ` WHERE (`)
-		if err := c.renderWhere(sel, ti); err != nil {
-			return err
-		}
-		io.WriteString(c.w, `)`)
+	if err := c.renderFrom(sel, ti, rel); err != nil {
+		return err
 	}
 
-	if !isRoot {
-		if err := c.renderJoin(sel, ti); err != nil {
-			return err
-		}
-
-		io.WriteString(c.w, ` WHERE (`)
-		if err := c.renderRelationship(sel, ti); err != nil {
-			return err
-		}
-		if isFil {
-			io.WriteString(c.w, ` AND `)
-			if err := c.renderWhere(sel, ti); err != nil {
-				return err
-			}
-		}
-		io.WriteString(c.w, `)`)
+	if sel.Paging.Cursor {
+		io.WriteString(c.w, `, "__cur"`)
 	}
 
-	if isAgg && len(realColsRendered) != 0 {
-		io.WriteString(c.w, ` GROUP BY `)
+	if err := c.renderBaseWhere(sel, ti, rel, sel.Paging.Cursor); err != nil {
+		return err
+	}
 
-		for i, id := range realColsRendered {
-			c.renderComma(i)
-			//fmt.Fprintf(w, `"%s"."%s"`, c.sel.Name, c.sel.Cols[id].Name)
-			colWithTable(c.w, ti.Name, sel.Cols[id].Name)
+	if len(sel.GroupBy) != 0 || (isAgg && len(realColsRendered) != 0) {
+		if err := c.renderGroupBy(sel, ti, realColsRendered); err != nil {
+			return err
 		}
 	}
 
@@ -678,9 +667,18 @@ func (c *compilerContext) renderBaseSelect(sel *qcode.Select, ti *DBTableInfo, r
 	}
 
 	switch {
+	case sel.Aggregate:
+		break
+
 	case ti.Singular:
 		io.WriteString(c.w, ` LIMIT ('1') :: integer`)
 
+	case sel.Connection != nil:
+		// the extra row tells if there's a next page
+		io.WriteString(c.w, ` LIMIT ('`)
+		io.WriteString(c.w, connectionLimit(sel))
+		io.WriteString(c.w, `') :: integer + 1`)
+
 	case len(sel.Paging.Limit) != 0:
 		//fmt.Fprintf(w, ` LIMIT ('%s') :: integer`, c.sel.Paging.Limit)
 		io.WriteString(c.w, ` LIMIT ('`)
@@ -732,32 +730,72 @@ func (c *compilerContext) renderFrom(sel *qcode.Select, ti *DBTableInfo, rel *DB
 		}
 		io.WriteString(c.w, `)`)
 
+	} else if ti.Func != nil {
+		if err := c.renderFunction(sel, ti); err != nil {
+			return err
+		}
+
+	} else if sel.Recursive {
+		if err := c.renderRecursive(sel, ti, rel); err != nil {
+			return err
+		}
+
 	} else {
 		//fmt.Fprintf(w, ` FROM "%s"`, c.sel.Name)
-		io.WriteString(c.w, `"`)
-		io.WriteString(c.w, ti.Name)
-		io.WriteString(c.w, `"`)
-	}
-
-	if sel.Paging.Cursor {
-		io.WriteString(c.w, `, "__cur"`)
+		quotedTable(c.w, ti)
 	}
 
 	return nil
 }
 
-func (c *compilerContext) renderCursorCTE(sel *qcode.Select) error {
-	io.WriteString(c.w, `WITH "__cur" AS (SELECT `)
-	for i, ob := range sel.OrderBy {
-		if i != 0 {
-			io.WriteString(c.w, `, `)
+// renderBaseWhere renders the where clause, the relationship to the
+// parent row of the select and the seek predicate of the cursor
+func (c *compilerContext) renderBaseWhere(sel *qcode.Select, ti *DBTableInfo, rel *DBRel, seek bool) error {
+	// Recursive selects are already limited to the rows under
+	// the parent row
+	isRel := (rel != nil && !sel.Recursive)
+	isFil := (sel.Where != nil && sel.Where.Op != qcode.OpNop)
+	n := 0
+
+	if isRel {
+		if err := c.renderJoin(sel, ti); err != nil {
+			return err
 		}
-		io.WriteString(c.w, `a[`)
-		int2string(c.w, int32(i+1))
-		io.WriteString(c.w, `] as `)
-		quoted(c.w, ob.Col)
 	}
-	io.WriteString(c.w, ` FROM string_to_array('{{cursor}}', ',') as a) `)
+
+	if !isRel && !isFil && !seek {
+		return nil
+	}
+
+	io.WriteString(c.w, ` WHERE (`)
+
+	if isRel {
+		if err := c.renderRelationship(sel, ti); err != nil {
+			return err
+		}
+		n++
+	}
+
+	if isFil {
+		if n != 0 {
+			io.WriteString(c.w, ` AND `)
+		}
+		if err := c.renderWhere(sel, ti); err != nil {
+			return err
+		}
+		n++
+	}
+
+	if seek {
+		if n != 0 {
+			io.WriteString(c.w, ` AND `)
+		}
+		if err := c.renderSeekPredicate(sel, ti); err != nil {
+			return err
+		}
+	}
+
+	io.WriteString(c.w, `)`)
 	return nil
 }
 
@@ -787,6 +825,16 @@ func (c *compilerContext) renderRelationshipByName(table, parent string, id int3
 		//c.sel.Name, rel.Left.Col, c.parent.Name, c.parent.ID, rel.Right.Col)
 
 		switch {
+		case len(rel.Left.Cols) != 0:
+			for i := range rel.Left.Cols {
+				if i != 0 {
+					io.WriteString(c.w, `) AND (`)
+				}
+				colWithTable(c.w, table, rel.Left.Cols[i])
+				io.WriteString(c.w, `) = (`)
+				colWithTableID(c.w, parent, id, rel.Right.Cols[i])
+			}
+
 		case !rel.Left.Array && rel.Right.Array:
 			colWithTable(c.w, table, rel.Left.Col)
 			io.WriteString(c.w, `) = any (`)
@@ -829,6 +877,15 @@ func (c *compilerContext) renderRelationshipByName(table, parent string, id int3
 		colWithTable(c.w, rel.Left.Table, rel.Left.Col)
 		io.WriteString(c.w, `) = (`)
 		colWithTableID(c.w, parent, id, rel.Left.Col)
+
+	case RelPolymorphic:
+		colWithTable(c.w, table, rel.Left.Col)
+		io.WriteString(c.w, `) = (`)
+		colWithTableID(c.w, parent, id, rel.Right.Col)
+		io.WriteString(c.w, `) AND (`)
+		colWithTable(c.w, table, rel.TypeCol)
+		io.WriteString(c.w, `) = (`)
+		squoted(c.w, rel.Types[0].Value)
 	}
 
 	io.WriteString(c.w, `))`)
@@ -935,7 +992,7 @@ func (c *compilerContext) renderNestedWhere(ex *qcode.Exp, ti *DBTableInfo) erro
 		}
 
 		io.WriteString(c.w, `(SELECT 1 FROM `)
-		io.WriteString(c.w, cti.Name)
+		quotedTable(c.w, cti)
 
 		if err := c.renderJoinByName(cti.Name, ti.Name, -1); err != nil {
 			return err
@@ -968,8 +1025,11 @@ func (c *compilerContext) renderOp(ex *qcode.Exp, ti *DBTableInfo) error {
 	var col *DBColumn
 	var ok bool
 
-	if ex.Op == qcode.OpNop {
+	switch ex.Op {
+	case qcode.OpNop:
 		return nil
+	case qcode.OpStDWithin, qcode.OpStIntersects, qcode.OpStContains, qcode.OpStWithin:
+		return c.renderGeoOp(ex, ti)
 	}
 
 	if len(ex.Col) != 0 {
@@ -978,7 +1038,14 @@ func (c *compilerContext) renderOp(ex *qcode.Exp, ti *DBTableInfo) error {
 		}
 
 		io.WriteString(c.w, `((`)
-		colWithTable(c.w, ti.Name, ex.Col)
+		if len(ex.Path) != 0 {
+			var err error
+			if col, err = c.renderJSONPathOp(ex, ti, col); err != nil {
+				return err
+			}
+		} else {
+			colOrExpr(c.w, ti, ex.Col)
+		}
 		io.WriteString(c.w, `) `)
 	}
 
@@ -1025,6 +1092,16 @@ func (c *compilerContext) renderOp(ex *qcode.Exp, ti *DBTableInfo) error {
 		io.WriteString(c.w, `?|`)
 	case qcode.OpHasKeyAll:
 		io.WriteString(c.w, `?&`)
+	case qcode.OpJSONPathExists, qcode.OpJSONPathMatch:
+		if col == nil || col.Type != "jsonb" {
+			return fmt.Errorf("path_exists and path_match need a jsonb column")
+		}
+		if ex.Op == qcode.OpJSONPathExists {
+			io.WriteString(c.w, `@?`)
+		} else {
+			io.WriteString(c.w, `@@`)
+		}
+		col = jsonPathCol
 	case qcode.OpIsNull:
 		if strings.EqualFold(ex.Val, "true") {
 			io.WriteString(c.w, `IS NULL)`)
@@ -1045,19 +1122,15 @@ func (c *compilerContext) renderOp(ex *qcode.Exp, ti *DBTableInfo) error {
 		io.WriteString(c.w, `) =`)
 
 	case qcode.OpTsQuery:
-		if ti.PrimaryCol == nil {
+		if ti.TSVCol == nil {
 			return fmt.Errorf("no tsv column defined for %s", ti.Name)
 		}
 		//fmt.Fprintf(w, `(("%s") @@ websearch_to_tsquery('%s'))`, c.ti.TSVCol, val.Val)
 		io.WriteString(c.w, `((`)
 		colWithTable(c.w, ti.Name, ti.TSVCol.Name)
-		if c.schema.ver >= 110000 {
-			io.WriteString(c.w, `) @@ websearch_to_tsquery('{{`)
-		} else {
-			io.WriteString(c.w, `) @@ to_tsquery('{{`)
-		}
-		io.WriteString(c.w, ex.Val)
-		io.WriteString(c.w, `}}'))`)
+		io.WriteString(c.w, `) @@ `)
+		c.renderTSQuery(ti, ex.Val)
+		io.WriteString(c.w, `)`)
 		return nil
 
 	default:
@@ -1079,40 +1152,123 @@ func (c *compilerContext) renderOp(ex *qcode.Exp, ti *DBTableInfo) error {
 
 func (c *compilerContext) renderOrderBy(sel *qcode.Select, ti *DBTableInfo) error {
 	io.WriteString(c.w, ` ORDER BY `)
+	return c.renderOrderByList(sel, ti, -1)
+}
+
+// renderOrderByList renders the order_by of the select, when the id is not
+// -1 the columns are read from the rows already fetched by the select
+func (c *compilerContext) renderOrderByList(sel *qcode.Select, ti *DBTableInfo, id int32) error {
 	for i := range sel.OrderBy {
 		if i != 0 {
 			io.WriteString(c.w, `, `)
 		}
 		ob := sel.OrderBy[i]
-		colWithTable(c.w, ti.Name, ob.Col)
-
-		switch ob.Order {
-		case qcode.OrderAsc:
-			io.WriteString(c.w, ` ASC`)
-		case qcode.OrderDesc:
-			io.WriteString(c.w, ` DESC`)
-		case qcode.OrderAscNullsFirst:
-			io.WriteString(c.w, ` ASC NULLS FIRST`)
-		case qcode.OrderDescNullsFirst:
-			io.WriteString(c.w, ` DESC NULLLS FIRST`)
-		case qcode.OrderAscNullsLast:
-			io.WriteString(c.w, ` ASC NULLS LAST`)
-		case qcode.OrderDescNullsLast:
-			io.WriteString(c.w, ` DESC NULLS LAST`)
+
+		switch {
+		case ob.Geo != nil:
+			if err := c.renderGeoDistance(ti, ob); err != nil {
+				return err
+			}
+
+		case len(ob.Path) != 0:
+			if err := c.renderJSONPathOrder(ti, ob); err != nil {
+				return err
+			}
+
+		// aggregate functions are ordered by their alias
+		case funcPrefixLen(ob.Col) != 0:
+			quoted(c.w, ob.Col)
+
+		case id != -1:
+			colWithTableID(c.w, ti.Name, id, ob.Col)
+
 		default:
-			return fmt.Errorf("13: unexpected value %v", ob.Order)
+			colOrExpr(c.w, ti, ob.Col)
+		}
+
+		if err := c.renderOrder(ob.Order); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+func (c *compilerContext) renderOrder(order qcode.Order) error {
+	switch order {
+	case qcode.OrderAsc:
+		io.WriteString(c.w, ` ASC`)
+	case qcode.OrderDesc:
+		io.WriteString(c.w, ` DESC`)
+	case qcode.OrderAscNullsFirst:
+		io.WriteString(c.w, ` ASC NULLS FIRST`)
+	case qcode.OrderDescNullsFirst:
+		io.WriteString(c.w, ` DESC NULLS FIRST`)
+	case qcode.OrderAscNullsLast:
+		io.WriteString(c.w, ` ASC NULLS LAST`)
+	case qcode.OrderDescNullsLast:
+		io.WriteString(c.w, ` DESC NULLS LAST`)
+	default:
+		return fmt.Errorf("13: unexpected value %v", order)
+	}
+	return nil
+}
+
+// renderGroupBy renders the group_by columns followed by the selected
+// columns since they are the keys the aggregate functions are grouped by
+func (c *compilerContext) renderGroupBy(sel *qcode.Select, ti *DBTableInfo, realColsRendered []int) error {
+	colmap := make(map[string]struct{}, len(sel.GroupBy)+len(realColsRendered))
+	selected := make(map[string]struct{}, len(realColsRendered))
+
+	for _, id := range realColsRendered {
+		selected[sel.Cols[id].Name] = struct{}{}
+	}
+
+	io.WriteString(c.w, ` GROUP BY `)
+
+	for i, cn := range sel.GroupBy {
+		if _, ok := ti.ColMap[cn]; !ok || isColumnBlocked(sel, cn) {
+			return fmt.Errorf("group_by on '%s' not allowed", cn)
+		}
+
+		// rows of a group would be returned without what they are grouped by
+		if _, ok := selected[cn]; !ok {
+			return fmt.Errorf("group_by on '%s' needs it to be selected", cn)
+		}
+		colmap[cn] = struct{}{}
+
+		c.renderComma(i)
+		colOrExpr(c.w, ti, cn)
+	}
+
+	for _, id := range realColsRendered {
+		cn := sel.Cols[id].Name
+
+		if _, ok := colmap[cn]; ok {
+			continue
+		}
+		colmap[cn] = struct{}{}
+
+		c.renderComma(len(colmap) - 1)
+		//fmt.Fprintf(w, `"%s"."%s"`, c.sel.Name, c.sel.Cols[id].Name)
+		colOrExpr(c.w, ti, cn)
+	}
+
+	for _, ob := range sel.OrderBy {
+		if _, ok := colmap[ob.Col]; !ok && funcPrefixLen(ob.Col) == 0 {
+			return fmt.Errorf("order_by on '%s' needs it to be selected or in group_by", ob.Col)
+		}
+	}
+
+	return nil
+}
+
 func (c *compilerContext) renderDistinctOn(sel *qcode.Select, ti *DBTableInfo) {
 	io.WriteString(c.w, `DISTINCT ON (`)
 	for i := range sel.DistinctOn {
 		if i != 0 {
 			io.WriteString(c.w, `, `)
 		}
-		colWithTable(c.w, ti.Name, sel.DistinctOn[i])
+		colOrExpr(c.w, ti, sel.DistinctOn[i])
 	}
 	io.WriteString(c.w, `) `)
 }
@@ -1140,19 +1296,7 @@ func (c *compilerContext) renderVal(ex *qcode.Exp, vars map[string]string, col *
 
 	switch ex.Type {
 	case qcode.ValVar:
-		val, ok := vars[ex.Val]
-		switch {
-		case ok && strings.HasPrefix(val, "sql:"):
-			io.WriteString(c.w, ` (`)
-			io.WriteString(c.w, val[4:])
-			io.WriteString(c.w, `)`)
-		case ok:
-			squoted(c.w, val)
-		default:
-			io.WriteString(c.w, ` '{{`)
-			io.WriteString(c.w, ex.Val)
-			io.WriteString(c.w, `}}'`)
-		}
+		c.renderVar(ex.Val)
 
 	case qcode.ValRef:
 		colWithTable(c.w, ex.Table, ex.Col)
@@ -1165,6 +1309,24 @@ func (c *compilerContext) renderVal(ex *qcode.Exp, vars map[string]string, col *
 	io.WriteString(c.w, col.Type)
 }
 
+// renderVar renders the value of the variable if it's a config variable
+// else a placeholder for it
+func (c *compilerContext) renderVar(name string) {
+	val, ok := c.vars[name]
+	switch {
+	case ok && strings.HasPrefix(val, "sql:"):
+		io.WriteString(c.w, ` (`)
+		io.WriteString(c.w, val[4:])
+		io.WriteString(c.w, `)`)
+	case ok:
+		squoted(c.w, val)
+	default:
+		io.WriteString(c.w, ` '{{`)
+		io.WriteString(c.w, name)
+		io.WriteString(c.w, `}}'`)
+	}
+}
+
 func funcPrefixLen(fn string) int {
 	switch {
 	case strings.HasPrefix(fn, "avg_"):
@@ -1193,6 +1355,12 @@ func funcPrefixLen(fn string) int {
 	return 0
 }
 
+// isSingular returns true if the select returns a single json object
+// instead of an array. Aggregates over a table always return one row.
+func isSingular(sel *qcode.Select, ti *DBTableInfo) bool {
+	return ti.Singular || sel.Aggregate
+}
+
 func hasBit(n uint32, pos uint32) bool {
 	val := n & (1 << pos)
 	return (val > 0)
@@ -1220,6 +1388,48 @@ func colWithTable(w io.Writer, table, col string) {
 	io.WriteString(w, `"`)
 }
 
+// colOrExpr renders the column or the sql expression of a computed column,
+// computed column functions are called with the row of the table alias
+func colOrExpr(w io.Writer, ti *DBTableInfo, col string) {
+	c, ok := ti.ColMap[col]
+
+	switch {
+	case ok && len(c.Func) != 0:
+		io.WriteString(w, `(`)
+		io.WriteString(w, c.Func)
+		io.WriteString(w, `(`)
+		quoted(w, ti.Name)
+		io.WriteString(w, `))`)
+		return
+
+	case ok && len(c.Expr) != 0:
+		io.WriteString(w, `(`)
+		io.WriteString(w, c.Expr)
+		io.WriteString(w, `)`)
+		return
+	}
+	colWithTable(w, ti.Name, col)
+}
+
+// quotedTable renders the name of the table, tables that are not on the
+// search path are qualified with their schema and aliased to their name
+func quotedTable(w io.Writer, ti *DBTableInfo) {
+	if len(ti.Schema) != 0 {
+		colWithTable(w, ti.Schema, ti.Table)
+		io.WriteString(w, ` AS `)
+	}
+	quoted(w, ti.Name)
+}
+
+// rowType renders the name of the composite type of the table's rows
+func rowType(w io.Writer, ti *DBTableInfo) {
+	if len(ti.Schema) != 0 {
+		colWithTable(w, ti.Schema, ti.Table)
+		return
+	}
+	io.WriteString(w, ti.Name)
+}
+
 func colWithTableID(w io.Writer, table string, id int32, col string) {
 	io.WriteString(w, `"`)
 	io.WriteString(w, table)
@@ -3,7 +3,10 @@ package psql
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/dosco/super-graph/qcode"
 )
 
 func withComplexArgs(t *testing.T) {
@@ -109,6 +112,36 @@ func searchQuery(t *testing.T) {
 	compileGQLToPSQL(t, gql, nil, "admin")
 }
 
+func searchQueryWithConfig(t *testing.T) {
+	gql := `query {
+		products(search: $query) {
+			id
+			name
+			search_rank
+			search_headline_description
+		}
+	}`
+
+	err := pcompile.schema.SetSearch("products", DBSearch{
+		Language:      "english",
+		Parser:        "phrase",
+		Normalization: 32,
+		Weights:       []float64{0.1, 0.2, 0.4, 1.0},
+		Headline:      DBHeadline{MaxWords: 20, StartSel: "<b>", StopSel: "</b>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		for _, ti := range pcompile.schema.t {
+			ti.Search = nil
+		}
+	}()
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
 func oneToMany(t *testing.T) {
 	gql := `query {
 		users {
@@ -231,6 +264,301 @@ func aggFunctionWithFilter(t *testing.T) {
 	compileGQLToPSQL(t, gql, nil, "user")
 }
 
+func aggFunctionWithGroupBy(t *testing.T) {
+	gql := `query {
+		products(group_by: [price], order_by: { count_id: desc }) {
+			price
+			name
+			count_id
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "user")
+}
+
+func aggFunctionOrderByNotSelected(t *testing.T) {
+	gql := `query {
+		products(group_by: price, order_by: { max_price: desc }) {
+			price
+			count_id
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "user")
+}
+
+func aggFunctionOnRelationship(t *testing.T) {
+	gql := `query {
+		users {
+			id
+			products_aggregate(where: { price: { gt: 5 } }) {
+				count_id
+				max_price
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "user")
+}
+
+func computedColumns(t *testing.T) {
+	gql := `query {
+		products(where: { discount_price: { lt: 5 } }, order_by: { discount_price: desc }) {
+			id
+			discount_price
+			user {
+				display_name
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func tableInOtherSchema(t *testing.T) {
+	gql := `query {
+		billing_invoices(where: { amount: { gt: 100 } }) {
+			id
+			amount
+			user {
+				email
+			}
+		}
+		users {
+			id
+			billing_invoices {
+				amount
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func computedColumnInOtherSchema(t *testing.T) {
+	gql := `query {
+		billing_invoices(order_by: { amount_due: desc }) {
+			id
+			amount_due
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func compositeForeignKey(t *testing.T) {
+	gql := `query {
+		orders {
+			id
+			total
+			order_items {
+				quantity
+			}
+		}
+		order_items {
+			id
+			order {
+				total
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func polymorphicRelationship(t *testing.T) {
+	gql := `query {
+		comments {
+			id
+			body
+			commentable {
+				__typename
+				... on products {
+					name
+				}
+				... on users {
+					email
+				}
+			}
+		}
+		products {
+			name
+			comments {
+				body
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func polymorphicRelationshipWithRole(t *testing.T) {
+	gql := `query {
+		comments {
+			id
+			commentable {
+				__typename
+				... on products {
+					name
+					created_at
+				}
+				... on users {
+					email
+					created_at
+				}
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "user")
+}
+
+func polymorphicRelationshipWithAnon(t *testing.T) {
+	gql := `query {
+		comments {
+			id
+			commentable {
+				__typename
+				... on products {
+					name
+					price
+				}
+				... on users {
+					email
+				}
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "anon")
+}
+
+func recursiveRelationship(t *testing.T) {
+	gql := `query {
+		comment(id: $id) {
+			id
+			body
+			replies: comments(recursive: true, max_depth: 5, where: { body: { is_null: false } }) {
+				id
+				body
+				parent_id
+				__depth
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func spatialQuery(t *testing.T) {
+	gql := `query {
+		products(
+			where: {
+				and: [
+					{ location: { st_dwithin: { point: [-122.4194, 37.7749], distance: 2000 } } },
+					{ location: { st_within: $area } }
+				]
+			},
+			order_by: { location: { distance_from: [-122.4194, 37.7749] } }) {
+			id
+			name
+			location
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func jsonPathQuery(t *testing.T) {
+	gql := `query {
+		products(
+			where: {
+				and: [
+					{ metadata: { path: "address.city", eq: "Berlin" } },
+					{ metadata: { path: "rating", gte: 4.5 } },
+					{ metadata: { path_exists: "$.variants[*] ? (@.stock > 0)" } }
+				]
+			},
+			order_by: { metadata: { path: "rating", order: desc } }) {
+			id
+			name
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func jsonPathQueryWithVars(t *testing.T) {
+	gql := `query {
+		products(
+			where: {
+				and: [
+					{ metadata: { path: "price", gt: $min } },
+					{ metadata: { path: "address.city", eq: $city } }
+				]
+			}) {
+			id
+			name
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"min":  json.RawMessage(`10`),
+		"city": json.RawMessage(`"Berlin"`),
+	}
+
+	compileGQLToPSQL(t, gql, vars, "admin")
+}
+
+func setReturningFunction(t *testing.T) {
+	gql := `query {
+		search_products(q: $query, max_price: 20, order_by: { price: desc }, limit: 5) {
+			id
+			name
+			user {
+				email
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func setReturningFunctionWithRole(t *testing.T) {
+	gql := `query {
+		search_products(q: $query, max_price: 20) {
+			id
+			name
+			price
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "user")
+}
+
+func setReturningFunctionWithAnon(t *testing.T) {
+	gql := `query {
+		search_products(q: $query, max_price: 20) {
+			id
+			name
+			price
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "anon")
+}
+
+func tableReturningFunction(t *testing.T) {
+	gql := `query {
+		top_buyers(since: $since, where: { total: { gt: 100 } }) {
+			user_id
+			total
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
 func syntheticTables(t *testing.T) {
 	gql := `query {
 		me {
@@ -310,6 +638,70 @@ func withCursor(t *testing.T) {
 	compileGQLToPSQL(t, gql, vars, "admin")
 }
 
+func withCursorNulls(t *testing.T) {
+	gql := `query {
+		products(
+			last: 5
+			before: $cursor
+			order_by: { price: desc_nulls_last, name: asc_nulls_first }) {
+			name
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
+func relayConnection(t *testing.T) {
+	gql := `query {
+		products_connection(
+			first: 10
+			after: $cursor
+			order_by: { price: desc }) {
+			totalCount
+			pageInfo {
+				hasNextPage
+				hasPreviousPage
+				startCursor
+				endCursor
+			}
+			edges {
+				cursor
+				node {
+					id
+					name
+				}
+			}
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"cursor": json.RawMessage(`"0,1"`),
+	}
+
+	compileGQLToPSQL(t, gql, vars, "admin")
+}
+
+func nestedRelayConnection(t *testing.T) {
+	gql := `query {
+		users {
+			email
+			purchases: products_connection(last: 5) {
+				edges {
+					node {
+						name
+					}
+				}
+				pageInfo {
+					hasPreviousPage
+					startCursor
+				}
+			}
+		}
+	}`
+
+	compileGQLToPSQL(t, gql, nil, "admin")
+}
+
 func jsonColumnAsTable(t *testing.T) {
 	gql := `query {
 		products {
@@ -372,6 +764,7 @@ func TestCompileQuery(t *testing.T) {
 	t.Run("withWhereMultiOr", withWhereMultiOr)
 	t.Run("fetchByID", fetchByID)
 	t.Run("searchQuery", searchQuery)
+	t.Run("searchQueryWithConfig", searchQueryWithConfig)
 	t.Run("oneToMany", oneToMany)
 	t.Run("oneToManyReverse", oneToManyReverse)
 	t.Run("oneToManyArray", oneToManyArray)
@@ -381,17 +774,106 @@ func TestCompileQuery(t *testing.T) {
 	t.Run("aggFunctionBlockedByCol", aggFunctionBlockedByCol)
 	t.Run("aggFunctionDisabled", aggFunctionDisabled)
 	t.Run("aggFunctionWithFilter", aggFunctionWithFilter)
+	t.Run("aggFunctionWithGroupBy", aggFunctionWithGroupBy)
+	t.Run("aggFunctionOrderByNotSelected", aggFunctionOrderByNotSelected)
+	t.Run("aggFunctionOnRelationship", aggFunctionOnRelationship)
+	t.Run("computedColumns", computedColumns)
+	t.Run("tableInOtherSchema", tableInOtherSchema)
+	t.Run("computedColumnInOtherSchema", computedColumnInOtherSchema)
+	t.Run("compositeForeignKey", compositeForeignKey)
+	t.Run("polymorphicRelationship", polymorphicRelationship)
+	t.Run("polymorphicRelationshipWithRole", polymorphicRelationshipWithRole)
+	t.Run("polymorphicRelationshipWithAnon", polymorphicRelationshipWithAnon)
+	t.Run("recursiveRelationship", recursiveRelationship)
+	t.Run("spatialQuery", spatialQuery)
+	t.Run("jsonPathQuery", jsonPathQuery)
+	t.Run("jsonPathQueryWithVars", jsonPathQueryWithVars)
+	t.Run("setReturningFunction", setReturningFunction)
+	t.Run("setReturningFunctionWithRole", setReturningFunctionWithRole)
+	t.Run("setReturningFunctionWithAnon", setReturningFunctionWithAnon)
+	t.Run("tableReturningFunction", tableReturningFunction)
 	t.Run("syntheticTables", syntheticTables)
 	t.Run("queryWithVariables", queryWithVariables)
 	t.Run("withWhereOnRelations", withWhereOnRelations)
 	t.Run("multiRoot", multiRoot)
 	t.Run("jsonColumnAsTable", jsonColumnAsTable)
 	t.Run("withCursor", withCursor)
+	t.Run("withCursorNulls", withCursorNulls)
+	t.Run("relayConnection", relayConnection)
+	t.Run("nestedRelayConnection", nestedRelayConnection)
 	t.Run("nullForAuthRequiredInAnon", nullForAuthRequiredInAnon)
 	t.Run("blockedQuery", blockedQuery)
 	t.Run("blockedFunctions", blockedFunctions)
 }
 
+func TestCursorPrefix(t *testing.T) {
+	prefix := func(gql string) string {
+		qc, err := qcompile.Compile([]byte(gql), "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := pcompile.CompileEx(qc, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := pcompile.CursorPrefix(&qc.Selects[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	p1 := prefix(`query { products(first: 5, after: $cursor, order_by: { price: desc }) { id } }`)
+	p2 := prefix(`query { products(first: 10, after: $cursor, order_by: { price: desc }) { name } }`)
+	p3 := prefix(`query { products(first: 5, after: $cursor, order_by: { price: desc_nulls_last }) { id } }`)
+
+	if !strings.HasPrefix(p1, cursorVersion+":") {
+		t.Fatalf("cursor prefix '%s' is missing the version", p1)
+	}
+
+	if p1 != p2 {
+		t.Fatalf("expected the same cursor prefix for the same order '%s' != '%s'", p1, p2)
+	}
+
+	if p1 == p3 {
+		t.Fatalf("expected a different cursor prefix for a different order '%s'", p1)
+	}
+}
+
+func TestCursorPaginationErrors(t *testing.T) {
+	for _, gql := range []string{
+		`query { products(first: 5, after: $cursor, order_by: { metadata: { path: "rating" } }) { id } }`,
+		`query { products_connection(first: 5, order_by: { location: { distance_from: [1, 2] } }) { edges { cursor node { id } } } }`,
+		`query { top_buyers_connection(first: 5) { edges { node { email } } } }`,
+	} {
+		qc, err := qcompile.Compile([]byte(gql), "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := pcompile.CompileEx(qc, nil); err == nil {
+			t.Fatalf("expected an error for cursor pagination: %s", gql)
+		}
+	}
+
+	ti, err := pcompile.schema.GetTable("top_buyers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &compilerContext{w: &bytes.Buffer{}, Compiler: pcompile}
+	sel := &qcode.Select{Name: "top_buyers", OrderBy: []*qcode.OrderBy{{Col: "email"}}}
+
+	if err := c.renderSeekPredicate(sel, ti); err == nil {
+		t.Fatal("expected an error for a table without a primary key")
+	}
+
+	if err := c.renderRowCursor(sel, ti); err == nil {
+		t.Fatal("expected an error for a table without a primary key")
+	}
+}
+
 var benchGQL = []byte(`query {
 	proDUcts(
 		# returns only 30 items
@@ -457,3 +939,31 @@ func BenchmarkCompileParallel(b *testing.B) {
 		}
 	})
 }
+
+func TestFragmentTypeCondition(t *testing.T) {
+	for _, gql := range []string{
+		`query { products { id ... on users { email } } }`,
+		`fragment userFields on users { email } query { products { id ...userFields } }`,
+		`query { comments { id commentable { ... on comments { body } } } }`,
+	} {
+		qc, err := qcompile.Compile([]byte(gql), "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := pcompile.CompileEx(qc, nil); err == nil {
+			t.Fatalf("expected an error for the fragment on another table: %s", gql)
+		}
+	}
+}
+
+func TestGroupByNotSelected(t *testing.T) {
+	qc, err := qcompile.Compile([]byte(`query { products(group_by: [price]) { name count_id } }`), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := pcompile.CompileEx(qc, nil); err == nil {
+		t.Fatal("expected an error for a group_by column that is not selected")
+	}
+}
@@ -0,0 +1,54 @@
+//nolint:errcheck
+package psql
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dosco/super-graph/qcode"
+)
+
+// renderRecursive renders a recursive query that fetches all the rows
+// under the parent row up to the max depth of the select. The rows get
+// a '__depth' column with their distance from the parent row.
+func (c *compilerContext) renderRecursive(sel *qcode.Select, ti *DBTableInfo, rel *DBRel) error {
+	if rel == nil || !rel.Recursive {
+		return fmt.Errorf("'%s' is not a recursive relationship", sel.FieldName)
+	}
+
+	parent := &c.s[sel.ParentID]
+	cte := "__rcte_" + ti.Name
+
+	io.WriteString(c.w, `(WITH RECURSIVE `)
+	quoted(c.w, cte)
+	io.WriteString(c.w, ` AS (SELECT `)
+	quoted(c.w, ti.Name)
+	io.WriteString(c.w, `.*, 1 AS "__depth" FROM `)
+	quotedTable(c.w, ti)
+	io.WriteString(c.w, ` WHERE ((`)
+	colWithTable(c.w, ti.Name, rel.Left.Col)
+	io.WriteString(c.w, `) = (`)
+	colWithTableID(c.w, ti.Name, parent.ID, rel.Right.Col)
+	io.WriteString(c.w, `)) UNION ALL SELECT `)
+	quoted(c.w, ti.Name)
+	io.WriteString(c.w, `.*, `)
+	colWithTable(c.w, cte, "__depth")
+	io.WriteString(c.w, ` + 1 FROM `)
+	quotedTable(c.w, ti)
+	io.WriteString(c.w, `, `)
+	quoted(c.w, cte)
+	io.WriteString(c.w, ` WHERE ((`)
+	colWithTable(c.w, ti.Name, rel.Left.Col)
+	io.WriteString(c.w, `) = (`)
+	colWithTable(c.w, cte, rel.Right.Col)
+	io.WriteString(c.w, `)) AND ((`)
+	colWithTable(c.w, cte, "__depth")
+	io.WriteString(c.w, `) < `)
+	int2string(c.w, int32(sel.MaxDepth))
+	io.WriteString(c.w, `)) SELECT * FROM `)
+	quoted(c.w, cte)
+	io.WriteString(c.w, `) AS `)
+	quoted(c.w, ti.Name)
+
+	return nil
+}
@@ -2,6 +2,7 @@ package psql
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/gobuffalo/flect"
@@ -16,12 +17,16 @@ type DBSchema struct {
 type DBTableInfo struct {
 	Name       string
 	Type       string
+	Schema     string
+	Table      string
 	Singular   bool
 	Columns    []DBColumn
 	PrimaryCol *DBColumn
 	TSVCol     *DBColumn
+	Search     *DBSearch
 	ColMap     map[string]*DBColumn
 	ColIDMap   map[int16]*DBColumn
+	Func       *DBFunction
 }
 
 type RelType int
@@ -32,22 +37,28 @@ const (
 	RelOneToManyThrough
 	RelEmbedded
 	RelRemote
+	RelPolymorphic
 )
 
 type DBRel struct {
-	Type    RelType
-	Through string
-	ColT    string
-	Left    struct {
+	Type      RelType
+	Through   string
+	ColT      string
+	TypeCol   string       // type column of a polymorphic relationship
+	Types     []DBPolyType // tables a polymorphic relationship points to
+	Recursive bool         // self-referencing relationship that can be queried recursively
+	Left      struct {
 		col   *DBColumn
 		Table string
 		Col   string
+		Cols  []string // all columns of a composite key
 		Array bool
 	}
 	Right struct {
 		col   *DBColumn
 		Table string
 		Col   string
+		Cols  []string
 		Array bool
 	}
 }
@@ -79,9 +90,34 @@ func NewDBSchema(info *DBInfo, aliases map[string][]string) (*DBSchema, error) {
 		}
 	}
 
+	for _, fn := range info.Functions {
+		schema.addFunction(fn)
+	}
+
 	return schema, nil
 }
 
+// AddComputedColumn adds a column that is rendered using the sql
+// expression in col.Expr or the function in col.Func instead of
+// reading it from the table
+func (s *DBSchema) AddComputedColumn(table string, col DBColumn) error {
+	ti, err := s.GetTable(table)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ti.ColMap[col.Key]; ok {
+		return fmt.Errorf("column '%s' already exists on table '%s'", col.Name, table)
+	}
+
+	if !col.IsComputed() {
+		return fmt.Errorf("no sql expression or function for computed column '%s'", col.Name)
+	}
+
+	ti.ColMap[col.Key] = &col
+	return nil
+}
+
 func (s *DBSchema) addTable(
 	t DBTable, cols []DBColumn, aliases map[string][]string) error {
 
@@ -92,6 +128,8 @@ func (s *DBSchema) addTable(
 	s.t[singular] = &DBTableInfo{
 		Name:     t.Name,
 		Type:     t.Type,
+		Schema:   t.Schema,
+		Table:    t.Table,
 		Singular: true,
 		Columns:  cols,
 		ColMap:   colmap,
@@ -102,6 +140,8 @@ func (s *DBSchema) addTable(
 	s.t[plural] = &DBTableInfo{
 		Name:     t.Name,
 		Type:     t.Type,
+		Schema:   t.Schema,
+		Table:    t.Table,
 		Singular: false,
 		Columns:  cols,
 		ColMap:   colmap,
@@ -192,10 +232,22 @@ func (s *DBSchema) firstDegreeRels(t DBTable, cols []DBColumn) error {
 		}
 
 		var rel1, rel2 *DBRel
+		var lcols, rcols []string
+		lc := c.Name
+
+		// Composite foreign keys are joined on all their columns
+		if len(c.FKeyColID) > 1 {
+			var err error
+
+			if lcols, rcols, err = compositeCols(cti, ti, c); err != nil {
+				return err
+			}
+			lc = lcols[0]
+		}
 
 		// One-to-many relation between current table and the
 		// table in the foreign key
-		if fc.UniqueKey {
+		if fc.UniqueKey || len(rcols) != 0 {
 			rel1 = &DBRel{Type: RelOneToOne}
 		} else {
 			rel1 = &DBRel{Type: RelOneToMany}
@@ -203,21 +255,29 @@ func (s *DBSchema) firstDegreeRels(t DBTable, cols []DBColumn) error {
 
 		rel1.Left.col = &c
 		rel1.Left.Table = t.Name
-		rel1.Left.Col = c.Name
+		rel1.Left.Col = lc
+		rel1.Left.Cols = lcols
 		rel1.Left.Array = c.Array
 
 		rel1.Right.col = fc
 		rel1.Right.Table = c.FKeyTable
 		rel1.Right.Col = fc.Name
+		rel1.Right.Cols = rcols
 		rel1.Right.Array = fc.Array
 
+		// Self-referencing foreign keys like 'parent_id' can be queried
+		// recursively to fetch all the rows under a row
+		if ct == ft && len(lcols) == 0 && !c.Array && !fc.Array {
+			rel1.Recursive = true
+		}
+
 		if err := s.SetRel(ct, ft, rel1); err != nil {
 			return err
 		}
 
 		// One-to-many reverse relation between the foreign key table and the
 		// the current table
-		if c.UniqueKey {
+		if c.UniqueKey && len(lcols) == 0 {
 			rel2 = &DBRel{Type: RelOneToOne}
 		} else {
 			rel2 = &DBRel{Type: RelOneToMany}
@@ -226,11 +286,13 @@ func (s *DBSchema) firstDegreeRels(t DBTable, cols []DBColumn) error {
 		rel2.Left.col = fc
 		rel2.Left.Table = c.FKeyTable
 		rel2.Left.Col = fc.Name
+		rel2.Left.Cols = rcols
 		rel2.Left.Array = fc.Array
 
 		rel2.Right.col = &c
 		rel2.Right.Table = t.Name
-		rel2.Right.Col = c.Name
+		rel2.Right.Col = lc
+		rel2.Right.Cols = lcols
 		rel2.Right.Array = c.Array
 
 		if err := s.SetRel(ft, ct, rel2); err != nil {
@@ -241,6 +303,36 @@ func (s *DBSchema) firstDegreeRels(t DBTable, cols []DBColumn) error {
 	return nil
 }
 
+// compositeCols returns the names of the columns on both sides of a
+// composite foreign key
+func compositeCols(cti, ti *DBTableInfo, c DBColumn) ([]string, []string, error) {
+	if len(c.FKeyCols) != len(c.FKeyColID) {
+		return nil, nil, fmt.Errorf("invalid composite foreign key on column '%s' of table '%s'",
+			c.Name, cti.Name)
+	}
+
+	lcols := make([]string, len(c.FKeyCols))
+	rcols := make([]string, len(c.FKeyColID))
+
+	for i := range c.FKeyCols {
+		lc, ok := cti.ColIDMap[c.FKeyCols[i]]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid foreign key column id '%d' for table '%s'",
+				c.FKeyCols[i], cti.Name)
+		}
+
+		rc, ok := ti.ColIDMap[c.FKeyColID[i]]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid foreign key column id '%d' for table '%s'",
+				c.FKeyColID[i], ti.Name)
+		}
+
+		lcols[i], rcols[i] = lc.Name, rc.Name
+	}
+
+	return lcols, rcols, nil
+}
+
 func (s *DBSchema) secondDegreeRels(t DBTable, cols []DBColumn) error {
 	jcols := make([]DBColumn, 0, len(cols))
 	ct := t.Key
@@ -270,7 +362,9 @@ func (s *DBSchema) secondDegreeRels(t DBTable, cols []DBColumn) error {
 			continue
 		}
 
-		if len(c.FKeyColID) == 0 {
+		// Composite foreign keys are not used for
+		// many-to-many relationships
+		if len(c.FKeyColID) != 1 {
 			continue
 		}
 
@@ -372,6 +466,15 @@ func (s *DBSchema) GetTable(table string) (*DBTableInfo, error) {
 	return t, nil
 }
 
+func (s *DBSchema) GetTableNames() []string {
+	names := make([]string, 0, len(s.t))
+	for k := range s.t {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (s *DBSchema) SetRel(child, parent string, rel *DBRel) error {
 	sp := strings.ToLower(flect.Singularize(parent))
 	pp := strings.ToLower(flect.Pluralize(parent))
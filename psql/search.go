@@ -0,0 +1,199 @@
+//nolint:errcheck
+package psql
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var searchLangRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
+
+// DBSearch configures the full-text search on a table
+type DBSearch struct {
+	// Language is the text search configuration like 'english'
+	Language string
+
+	// Parser is how the search text is parsed 'websearch', 'plain',
+	// 'phrase' or 'raw' for the to_tsquery syntax
+	Parser string
+
+	// Normalization is the ts_rank normalization option
+	Normalization int
+
+	// Weights of the D, C, B and A labels used by ts_rank
+	Weights []float64
+
+	// Headline are the options passed to ts_headline
+	Headline DBHeadline
+}
+
+type DBHeadline struct {
+	MaxWords          int
+	MinWords          int
+	ShortWord         int
+	MaxFragments      int
+	StartSel          string
+	StopSel           string
+	FragmentDelimiter string
+	HighlightAll      bool
+}
+
+// SetSearch sets the full-text search config of the table
+func (s *DBSchema) SetSearch(table string, ds DBSearch) error {
+	ti, err := s.GetTable(table)
+	if err != nil {
+		return err
+	}
+
+	if len(ds.Language) != 0 && !searchLangRe.MatchString(ds.Language) {
+		return fmt.Errorf("invalid search language '%s' for table '%s'", ds.Language, table)
+	}
+
+	switch ds.Parser {
+	case "", "websearch", "plain", "phrase", "raw":
+	default:
+		return fmt.Errorf("invalid search parser '%s' for table '%s'", ds.Parser, table)
+	}
+
+	if len(ds.Weights) != 0 && len(ds.Weights) != 4 {
+		return fmt.Errorf("search weights for table '%s' must be a list of 4 values for D, C, B and A",
+			table)
+	}
+
+	// also set on the other names of the table like the singular
+	// or plural name and functions returning the table
+	for _, v := range s.t {
+		if v.Name == ti.Name && v.Type == ti.Type {
+			v.Search = &ds
+		}
+	}
+
+	return nil
+}
+
+// renderTSQuery renders the search text as a tsquery using the parser
+// and language of the table
+func (c *compilerContext) renderTSQuery(ti *DBTableInfo, val string) {
+	var ds DBSearch
+
+	if ti.Search != nil {
+		ds = *ti.Search
+	}
+
+	switch {
+	case ds.Parser == "plain":
+		io.WriteString(c.w, `plainto_tsquery(`)
+	case ds.Parser == "phrase":
+		io.WriteString(c.w, `phraseto_tsquery(`)
+	case ds.Parser == "raw":
+		io.WriteString(c.w, `to_tsquery(`)
+	case c.schema.ver >= 110000:
+		io.WriteString(c.w, `websearch_to_tsquery(`)
+	case ds.Parser == "websearch":
+		// websearch_to_tsquery was added in Postgres 11
+		io.WriteString(c.w, `plainto_tsquery(`)
+	default:
+		io.WriteString(c.w, `to_tsquery(`)
+	}
+
+	renderSearchLang(c.w, ds)
+
+	io.WriteString(c.w, `'{{`)
+	io.WriteString(c.w, val)
+	io.WriteString(c.w, `}}')`)
+}
+
+// renderSearchRank renders the ts_rank of the tsvector column
+func (c *compilerContext) renderSearchRank(ti *DBTableInfo, val string) {
+	io.WriteString(c.w, `ts_rank(`)
+
+	if ti.Search != nil && len(ti.Search.Weights) != 0 {
+		io.WriteString(c.w, `'{`)
+		for i, v := range ti.Search.Weights {
+			if i != 0 {
+				io.WriteString(c.w, `, `)
+			}
+			io.WriteString(c.w, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		io.WriteString(c.w, `}', `)
+	}
+
+	colWithTable(c.w, ti.Name, ti.TSVCol.Name)
+	io.WriteString(c.w, `, `)
+	c.renderTSQuery(ti, val)
+
+	if ti.Search != nil && ti.Search.Normalization != 0 {
+		io.WriteString(c.w, `, `)
+		int2string(c.w, int32(ti.Search.Normalization))
+	}
+
+	io.WriteString(c.w, `)`)
+}
+
+// renderSearchHeadline renders the ts_headline of the column
+func (c *compilerContext) renderSearchHeadline(ti *DBTableInfo, col, val string) {
+	io.WriteString(c.w, `ts_headline(`)
+
+	if ti.Search != nil {
+		renderSearchLang(c.w, *ti.Search)
+	}
+
+	colWithTable(c.w, ti.Name, col)
+	io.WriteString(c.w, `, `)
+	c.renderTSQuery(ti, val)
+
+	if ti.Search != nil {
+		if opts := ti.Search.Headline.options(); len(opts) != 0 {
+			io.WriteString(c.w, `, `)
+			squoted(c.w, strings.Replace(opts, `'`, `''`, -1))
+		}
+	}
+
+	io.WriteString(c.w, `)`)
+}
+
+func renderSearchLang(w io.Writer, ds DBSearch) {
+	if len(ds.Language) != 0 {
+		squoted(w, ds.Language)
+		io.WriteString(w, ` :: regconfig, `)
+	}
+}
+
+// options returns the headline options in the format used by ts_headline
+func (h DBHeadline) options() string {
+	var opts []string
+
+	add := func(k, v string) {
+		opts = append(opts, k+"="+v)
+	}
+
+	if h.MaxWords != 0 {
+		add("MaxWords", strconv.Itoa(h.MaxWords))
+	}
+	if h.MinWords != 0 {
+		add("MinWords", strconv.Itoa(h.MinWords))
+	}
+	if h.ShortWord != 0 {
+		add("ShortWord", strconv.Itoa(h.ShortWord))
+	}
+	if h.MaxFragments != 0 {
+		add("MaxFragments", strconv.Itoa(h.MaxFragments))
+	}
+	if len(h.StartSel) != 0 {
+		add("StartSel", strconv.Quote(h.StartSel))
+	}
+	if len(h.StopSel) != 0 {
+		add("StopSel", strconv.Quote(h.StopSel))
+	}
+	if len(h.FragmentDelimiter) != 0 {
+		add("FragmentDelimiter", strconv.Quote(h.FragmentDelimiter))
+	}
+	if h.HighlightAll {
+		add("HighlightAll", "true")
+	}
+
+	return strings.Join(opts, ", ")
+}
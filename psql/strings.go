@@ -14,6 +14,8 @@ func (rt RelType) String() string {
 		return "remote"
 	case RelEmbedded:
 		return "embedded"
+	case RelPolymorphic:
+		return "polymorphic"
 	}
 	return ""
 }
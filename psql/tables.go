@@ -11,13 +11,22 @@ import (
 )
 
 type DBInfo struct {
-	Version int
-	Tables  []DBTable
-	Columns [][]DBColumn
-	colmap  map[string]map[string]*DBColumn
+	Version   int
+	Tables    []DBTable
+	Columns   [][]DBColumn
+	Functions []DBFunction
+	colmap    map[string]map[string]*DBColumn
 }
 
-func GetDBInfo(db *pgxpool.Pool) (*DBInfo, error) {
+// Namespace is a database schema whose tables are added in addition to
+// the ones on the search path. The names of its tables are prefixed
+// with Prefix which defaults to the schema name followed by an underscore.
+type Namespace struct {
+	Name   string
+	Prefix string
+}
+
+func GetDBInfo(db *pgxpool.Pool, ns ...Namespace) (*DBInfo, error) {
 	di := &DBInfo{}
 
 	dbc, err := db.Acquire(context.Background())
@@ -38,19 +47,34 @@ func GetDBInfo(db *pgxpool.Pool) (*DBInfo, error) {
 		return nil, err
 	}
 
-	di.Tables, err = GetTables(dbc)
+	di.Tables, err = GetTables(dbc, ns...)
 	if err != nil {
 		return nil, err
 	}
 
 	di.colmap = make(map[string]map[string]*DBColumn, len(di.Tables))
 
+	// foreign keys point to the schema and name of the table in the
+	// database which can be different from the name it's added with
+	names := make(map[string]string, len(di.Tables))
+
+	for _, t := range di.Tables {
+		names[t.nspname+"."+t.Table] = t.Name
+	}
+
 	for i, t := range di.Tables {
-		cols, err := GetColumns(dbc, "public", t.Name)
+		cols, err := GetColumns(dbc, t.nspname, t.Table)
 		if err != nil {
 			return nil, err
 		}
 
+		for n := range cols {
+			c := &cols[n]
+			if v, ok := names[c.fKeySchema+"."+c.FKeyTable]; ok {
+				c.FKeyTable = v
+			}
+		}
+
 		di.Columns = append(di.Columns, cols)
 		di.colmap[t.Key] = make(map[string]*DBColumn, len(cols))
 
@@ -59,6 +83,11 @@ func GetDBInfo(db *pgxpool.Pool) (*DBInfo, error) {
 		}
 	}
 
+	di.Functions, err = GetFunctions(dbc)
+	if err != nil {
+		return nil, err
+	}
+
 	return di, nil
 }
 
@@ -82,15 +111,19 @@ func (di *DBInfo) GetColumn(table, column string) (*DBColumn, bool) {
 }
 
 type DBTable struct {
-	ID   int
-	Name string
-	Key  string
-	Type string
+	ID      int
+	Name    string
+	Key     string
+	Type    string
+	Schema  string // set for tables not on the search path
+	Table   string // name of the table in the database
+	nspname string
 }
 
-func GetTables(dbc *pgxpool.Conn) ([]DBTable, error) {
+func GetTables(dbc *pgxpool.Conn, ns ...Namespace) ([]DBTable, error) {
 	sqlStmt := `
 SELECT
+	n.nspname as "schema",
 	c.relname as "name",
 	CASE c.relkind WHEN 'r' THEN 'table'
 		WHEN 'v' THEN 'view'
@@ -103,11 +136,23 @@ WHERE c.relkind IN ('r','v','m','f','')
 	AND n.nspname <> ('pg_catalog')
 	AND n.nspname <> ('information_schema')
 	AND n.nspname !~ ('^pg_toast')
-AND pg_catalog.pg_table_is_visible(c.oid);`
+	AND (pg_catalog.pg_table_is_visible(c.oid) OR n.nspname = ANY($1));`
 
 	var tables []DBTable
 
-	rows, err := dbc.Query(context.Background(), sqlStmt)
+	prefix := make(map[string]string, len(ns))
+	names := make([]string, 0, len(ns))
+
+	for _, v := range ns {
+		if len(v.Prefix) != 0 {
+			prefix[v.Name] = v.Prefix
+		} else {
+			prefix[v.Name] = v.Name + "_"
+		}
+		names = append(names, v.Name)
+	}
+
+	rows, err := dbc.Query(context.Background(), sqlStmt, names)
 	if err != nil {
 		return nil, fmt.Errorf("Error fetching tables: %s", err)
 	}
@@ -115,10 +160,18 @@ AND pg_catalog.pg_table_is_visible(c.oid);`
 
 	for i := 0; rows.Next(); i++ {
 		t := DBTable{ID: i}
-		err = rows.Scan(&t.Name, &t.Type)
+		err = rows.Scan(&t.nspname, &t.Table, &t.Type)
 		if err != nil {
 			return nil, err
 		}
+
+		if p, ok := prefix[t.nspname]; ok {
+			t.Schema = t.nspname
+			t.Name = p + t.Table
+		} else {
+			t.Name = t.Table
+		}
+
 		t.Key = strings.ToLower(t.Name)
 		if t.Key != "schema_migrations" && t.Key != "ar_internal_metadata" {
 			tables = append(tables, t)
@@ -139,7 +192,57 @@ type DBColumn struct {
 	UniqueKey  bool
 	FKeyTable  string
 	FKeyColID  []int16
+	FKeyCols   []int16 // ids of the columns in a composite foreign key
+	Expr       string
+	Func       string // function of a computed column, called with the row of the table
 	fKeyColID  pgtype.Int2Array
+	fKeyCols   pgtype.Int2Array
+	fKeySchema string
+}
+
+// IsComputed returns true for computed columns, they are rendered using
+// an sql expression or a function instead of being read from the table
+func (col *DBColumn) IsComputed() bool {
+	return len(col.Expr) != 0 || len(col.Func) != 0
+}
+
+// mergeColumnRow merges the constraints of another row of the same column
+// into the column, a row is returned for each constraint on the column
+func mergeColumnRow(v, c *DBColumn) error {
+	if c.PrimaryKey {
+		v.PrimaryKey = true
+		v.UniqueKey = true
+	}
+	if c.NotNull {
+		v.NotNull = true
+	}
+	if c.UniqueKey {
+		v.UniqueKey = true
+	}
+	if c.Array {
+		v.Array = true
+	}
+
+	// a column can be part of many foreign keys, single column
+	// ones are kept over composite ones which are also found
+	// using their other columns
+	if len(c.FKeyTable) == 0 {
+		return nil
+	}
+
+	if len(c.fKeyCols.Elements) > 1 && len(v.FKeyCols) == 1 {
+		return nil
+	}
+
+	v.FKeyTable = c.FKeyTable
+	v.fKeySchema = c.fKeySchema
+	v.fKeyColID = c.fKeyColID
+	v.fKeyCols = c.fKeyCols
+
+	if err := v.fKeyColID.AssignTo(&v.FKeyColID); err != nil {
+		return err
+	}
+	return v.fKeyCols.AssignTo(&v.FKeyCols)
 }
 
 func GetColumns(dbc *pgxpool.Conn, schema, table string) ([]DBColumn, error) {
@@ -166,16 +269,25 @@ SELECT
 		WHEN p.contype = ('f'::char) THEN g.relname 
 		ELSE ''::text
 	END AS foreignkey,
+	CASE
+		WHEN p.contype = ('f'::char) THEN gn.nspname
+		ELSE ''::text
+	END AS foreignkey_schema,
 	CASE
 		WHEN p.contype = ('f'::char) THEN p.confkey::int2[]
 		ELSE ARRAY[]::int2[]
-	END AS foreignkey_fieldnum
+	END AS foreignkey_fieldnum,
+	CASE
+		WHEN p.contype = ('f'::char) THEN p.conkey::int2[]
+		ELSE ARRAY[]::int2[]
+	END AS foreignkey_cols
 FROM pg_attribute f
 	JOIN pg_class c ON c.oid = f.attrelid  
 	LEFT JOIN pg_attrdef d ON d.adrelid = c.oid AND d.adnum = f.attnum  
 	LEFT JOIN pg_namespace n ON n.oid = c.relnamespace  
 	LEFT JOIN pg_constraint p ON p.conrelid = c.oid AND f.attnum = ANY (p.conkey)  
 	LEFT JOIN pg_class AS g ON p.confrelid = g.oid  
+	LEFT JOIN pg_namespace AS gn ON gn.oid = g.relnamespace
 WHERE c.relkind IN ('r', 'v', 'm', 'f')
 	AND n.nspname = $1  -- Replace with Schema name  
 	AND c.relname = $2  -- Replace with table name  
@@ -194,34 +306,14 @@ ORDER BY id;`
 	for rows.Next() {
 		c := DBColumn{}
 
-		err = rows.Scan(&c.ID, &c.Name, &c.NotNull, &c.Type, &c.Array, &c.PrimaryKey, &c.UniqueKey, &c.FKeyTable, &c.fKeyColID)
+		err = rows.Scan(&c.ID, &c.Name, &c.NotNull, &c.Type, &c.Array, &c.PrimaryKey, &c.UniqueKey, &c.FKeyTable, &c.fKeySchema, &c.fKeyColID, &c.fKeyCols)
 		if err != nil {
 			return nil, err
 		}
 
 		if v, ok := cmap[c.ID]; ok {
-			if c.PrimaryKey {
-				v.PrimaryKey = true
-				v.UniqueKey = true
-			}
-			if c.NotNull {
-				v.NotNull = true
-			}
-			if c.UniqueKey {
-				v.UniqueKey = true
-			}
-			if c.Array {
-				v.Array = true
-			}
-			if len(c.FKeyTable) != 0 {
-				v.FKeyTable = c.FKeyTable
-			}
-			if c.fKeyColID.Elements != nil {
-				v.fKeyColID = c.fKeyColID
-				err := v.fKeyColID.AssignTo(&v.FKeyColID)
-				if err != nil {
-					return nil, err
-				}
+			if err := mergeColumnRow(&v, &c); err != nil {
+				return nil, err
 			}
 			cmap[c.ID] = v
 		} else {
@@ -229,6 +321,10 @@ ORDER BY id;`
 			if err != nil {
 				return nil, err
 			}
+			err = c.fKeyCols.AssignTo(&c.FKeyCols)
+			if err != nil {
+				return nil, err
+			}
 			c.Key = strings.ToLower(c.Name)
 			if c.PrimaryKey {
 				c.UniqueKey = true
@@ -0,0 +1,42 @@
+package psql
+
+import (
+	"testing"
+)
+
+func fkeyRow(t *testing.T, table string, colIDs, cols []int16) DBColumn {
+	c := DBColumn{ID: 2, Name: "order_id", FKeyTable: table}
+
+	if err := c.fKeyColID.Set(colIDs); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.fKeyCols.Set(cols); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGetColumnsForeignKeys(t *testing.T) {
+	single := fkeyRow(t, "orders", []int16{1}, []int16{2})
+	composite := fkeyRow(t, "order_items", []int16{1, 2}, []int16{2, 3})
+
+	for _, rows := range [][]DBColumn{{single, composite}, {composite, single}} {
+		v := rows[0]
+
+		if err := v.fKeyColID.AssignTo(&v.FKeyColID); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.fKeyCols.AssignTo(&v.FKeyCols); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := mergeColumnRow(&v, &rows[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		if v.FKeyTable != "orders" || len(v.FKeyColID) != 1 || len(v.FKeyCols) != 1 {
+			t.Fatalf("expected the single column foreign key to be kept got '%s' %v",
+				v.FKeyTable, v.FKeyCols)
+		}
+	}
+}
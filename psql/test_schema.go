@@ -13,6 +13,10 @@ func getTestSchema() *DBSchema {
 		DBTable{Name: "purchases", Type: "table"},
 		DBTable{Name: "tags", Type: "table"},
 		DBTable{Name: "tag_count", Type: "json"},
+		DBTable{Name: "billing_invoices", Type: "table", Schema: "billing", Table: "invoices"},
+		DBTable{Name: "orders", Type: "table"},
+		DBTable{Name: "order_items", Type: "table"},
+		DBTable{Name: "comments", Type: "table"},
 	}
 
 	columns := [][]DBColumn{
@@ -49,7 +53,9 @@ func getTestSchema() *DBSchema {
 			DBColumn{ID: 7, Name: "updated_at", Type: "timestamp without time zone", NotNull: true, PrimaryKey: false, UniqueKey: false},
 			DBColumn{ID: 8, Name: "tsv", Type: "tsvector", NotNull: false, PrimaryKey: false, UniqueKey: false},
 			DBColumn{ID: 9, Name: "tags", Type: "text[]", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeyTable: "tags", FKeyColID: []int16{3}, Array: true},
-			DBColumn{ID: 9, Name: "tag_count", Type: "json", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeyTable: "tag_count", FKeyColID: []int16{}}},
+			DBColumn{ID: 9, Name: "tag_count", Type: "json", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeyTable: "tag_count", FKeyColID: []int16{}},
+			DBColumn{ID: 10, Name: "location", Type: "geometry(Point,4326)", NotNull: false, PrimaryKey: false, UniqueKey: false},
+			DBColumn{ID: 11, Name: "metadata", Type: "jsonb", NotNull: false, PrimaryKey: false, UniqueKey: false}},
 		[]DBColumn{
 			DBColumn{ID: 1, Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
 			DBColumn{ID: 2, Name: "customer_id", Type: "bigint", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeyTable: "customers", FKeyColID: []int16{1}},
@@ -65,6 +71,25 @@ func getTestSchema() *DBSchema {
 		[]DBColumn{
 			DBColumn{ID: 1, Name: "tag_id", Type: "bigint", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeyTable: "tags", FKeyColID: []int16{1}},
 			DBColumn{ID: 2, Name: "count", Type: "int", NotNull: false, PrimaryKey: false, UniqueKey: false}},
+		[]DBColumn{
+			DBColumn{ID: 1, Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+			DBColumn{ID: 2, Name: "amount", Type: "numeric(7,2)", NotNull: true, PrimaryKey: false, UniqueKey: false},
+			DBColumn{ID: 3, Name: "user_id", Type: "bigint", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeyTable: "users", FKeyColID: []int16{1}}},
+		[]DBColumn{
+			DBColumn{ID: 1, Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+			DBColumn{ID: 2, Name: "tenant_id", Type: "bigint", NotNull: true, PrimaryKey: false, UniqueKey: false},
+			DBColumn{ID: 3, Name: "total", Type: "numeric(7,2)", NotNull: false, PrimaryKey: false, UniqueKey: false}},
+		[]DBColumn{
+			DBColumn{ID: 1, Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+			DBColumn{ID: 2, Name: "tenant_id", Type: "bigint", NotNull: true, PrimaryKey: false, UniqueKey: false},
+			DBColumn{ID: 3, Name: "order_id", Type: "bigint", NotNull: true, PrimaryKey: false, UniqueKey: false, FKeyTable: "orders", FKeyColID: []int16{2, 1}, FKeyCols: []int16{2, 3}},
+			DBColumn{ID: 4, Name: "quantity", Type: "integer", NotNull: false, PrimaryKey: false, UniqueKey: false}},
+		[]DBColumn{
+			DBColumn{ID: 1, Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+			DBColumn{ID: 2, Name: "body", Type: "text", NotNull: false, PrimaryKey: false, UniqueKey: false},
+			DBColumn{ID: 3, Name: "commentable_type", Type: "character varying", NotNull: false, PrimaryKey: false, UniqueKey: false},
+			DBColumn{ID: 4, Name: "commentable_id", Type: "bigint", NotNull: false, PrimaryKey: false, UniqueKey: false},
+			DBColumn{ID: 5, Name: "parent_id", Type: "bigint", NotNull: false, PrimaryKey: false, UniqueKey: false, FKeyTable: "comments", FKeyColID: []int16{1}}},
 	}
 
 	for i := range tables {
@@ -105,5 +130,28 @@ func getTestSchema() *DBSchema {
 		}
 	}
 
+	err := schema.AddPolymorphicRel("comments", "commentable", "commentable_type", "commentable_id",
+		[]DBPolyType{{Value: "Product", Table: "products"}, {Value: "User", Table: "users"}})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	functions := []DBFunction{
+		DBFunction{Name: "search_products", Key: "search_products", Table: "products",
+			Params: []DBFuncParam{{Name: "q", Type: "text"}, {Name: "max_price", Type: "numeric"}}},
+	}
+
+	topBuyers := DBFunction{Name: "top_buyers", Key: "top_buyers"}
+	topBuyers.addParams(
+		[]string{"since", "user_id", "total"},
+		[]string{"i", "t", "t"},
+		[]string{"timestamp without time zone", "bigint", "numeric"})
+
+	functions = append(functions, topBuyers)
+
+	for _, fn := range functions {
+		schema.addFunction(fn)
+	}
+
 	return schema
 }
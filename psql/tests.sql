@@ -5,6 +5,8 @@ WITH "_sg_input" AS (SELECT '{{data}}' :: json AS j), "users" AS (INSERT INTO "u
 WITH "_sg_input" AS (SELECT '{{insert}}' :: json AS j), "products" AS (INSERT INTO "products" ("name", "description", "price", "user_id") SELECT "t"."name", "t"."description", "t"."price", "t"."user_id" FROM "_sg_input" i, json_populate_record(NULL::products, i.j) t RETURNING *) SELECT json_build_object('product', "__sel_0"."json") as "__root" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name") AS "json" FROM (SELECT "products"."id", "products"."name" FROM "products" LIMIT ('1') :: integer) AS "products_0") AS "__sel_0"
 === RUN   TestCompileInsert/bulkInsert
 WITH "_sg_input" AS (SELECT '{{insert}}' :: json AS j), "products" AS (INSERT INTO "products" ("name", "description") SELECT "t"."name", "t"."description" FROM "_sg_input" i, json_populate_recordset(NULL::products, i.j) t RETURNING *) SELECT json_build_object('product', "__sel_0"."json") as "__root" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name") AS "json" FROM (SELECT "products"."id", "products"."name" FROM "products" LIMIT ('1') :: integer) AS "products_0") AS "__sel_0"
+=== RUN   TestCompileInsert/insertInOtherSchema
+WITH "_sg_input" AS (SELECT '{{data}}' :: json AS j), "billing_invoices" AS (INSERT INTO "billing"."invoices" AS "billing_invoices" ("amount", "user_id") SELECT "t"."amount", "t"."user_id" FROM "_sg_input" i, json_populate_record(NULL::"billing"."invoices", i.j) t RETURNING *) SELECT json_build_object('billing_invoice', "__sel_0"."json") as "__root" FROM (SELECT json_build_object('id', "billing_invoices_0"."id", 'amount', "billing_invoices_0"."amount") AS "json" FROM (SELECT "billing_invoices"."id", "billing_invoices"."amount" FROM "billing"."invoices" AS "billing_invoices" LIMIT ('1') :: integer) AS "billing_invoices_0") AS "__sel_0"
 === RUN   TestCompileInsert/simpleInsertWithPresets
 WITH "_sg_input" AS (SELECT '{{data}}' :: json AS j), "products" AS (INSERT INTO "products" ("name", "price", "created_at", "updated_at", "user_id") SELECT "t"."name", "t"."price", 'now' :: timestamp without time zone, 'now' :: timestamp without time zone, '{{user_id}}' :: bigint FROM "_sg_input" i, json_populate_record(NULL::products, i.j) t RETURNING *) SELECT json_build_object('product', "__sel_0"."json") as "__root" FROM (SELECT json_build_object('id', "products_0"."id") AS "json" FROM (SELECT "products"."id" FROM "products" LIMIT ('1') :: integer) AS "products_0") AS "__sel_0"
 === RUN   TestCompileInsert/nestedInsertManyToMany
@@ -24,6 +26,7 @@ WITH "_sg_input" AS (SELECT '{{data}}' :: json AS j), "_x_users" AS (SELECT "id"
     --- PASS: TestCompileInsert/simpleInsert (0.00s)
     --- PASS: TestCompileInsert/singleInsert (0.00s)
     --- PASS: TestCompileInsert/bulkInsert (0.00s)
+    --- PASS: TestCompileInsert/insertInOtherSchema (0.00s)
     --- PASS: TestCompileInsert/simpleInsertWithPresets (0.00s)
     --- PASS: TestCompileInsert/nestedInsertManyToMany (0.00s)
     --- PASS: TestCompileInsert/nestedInsertOneToMany (0.00s)
@@ -58,6 +61,8 @@ SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT
 SELECT json_build_object('product', "__sel_0"."json") as "__root" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name") AS "json" FROM (SELECT "products"."id", "products"."name" FROM "products" WHERE ((((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2))) AND (("products"."id") =  '{{id}}' :: bigint))) LIMIT ('1') :: integer) AS "products_0") AS "__sel_0"
 === RUN   TestCompileQuery/searchQuery
 SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name", 'search_rank', "products_0"."search_rank", 'search_headline_description', "products_0"."search_headline_description") AS "json" FROM (SELECT "products"."id", "products"."name", ts_rank("products"."tsv", websearch_to_tsquery('{{query}}')) AS "search_rank", ts_headline("products"."description", websearch_to_tsquery('{{query}}')) AS "search_headline_description" FROM "products" WHERE ((("products"."tsv") @@ websearch_to_tsquery('{{query}}'))) LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/searchQueryWithConfig
+SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name", 'search_rank', "products_0"."search_rank", 'search_headline_description', "products_0"."search_headline_description") AS "json" FROM (SELECT "products"."id", "products"."name", ts_rank('{0.1, 0.2, 0.4, 1}', "products"."tsv", phraseto_tsquery('english' :: regconfig, '{{query}}'), 32) AS "search_rank", ts_headline('english' :: regconfig, "products"."description", phraseto_tsquery('english' :: regconfig, '{{query}}'), 'MaxWords=20, StartSel="<b>", StopSel="</b>"') AS "search_headline_description" FROM "products" WHERE ((("products"."tsv") @@ phraseto_tsquery('english' :: regconfig, '{{query}}'))) LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
 === RUN   TestCompileQuery/oneToMany
 SELECT json_build_object('users', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('email', "users_0"."email", 'products', "__sel_1"."json") AS "json" FROM (SELECT "users"."email", "users"."id" FROM "users" LIMIT ('20') :: integer) AS "users_0" LEFT OUTER JOIN LATERAL (SELECT coalesce(json_agg("__sel_1"."json"), '[]') as "json" FROM (SELECT json_build_object('name', "products_1"."name", 'price', "products_1"."price") AS "json" FROM (SELECT "products"."name", "products"."price" FROM "products" WHERE ((("products"."user_id") = ("users_0"."id")) AND ((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2)))) LIMIT ('20') :: integer) AS "products_1") AS "__sel_1")  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
 === RUN   TestCompileQuery/oneToManyReverse
@@ -76,18 +81,60 @@ SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT
 SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('name', "products_0"."name") AS "json" FROM (SELECT "products"."name" FROM "products" GROUP BY "products"."name" LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
 === RUN   TestCompileQuery/aggFunctionWithFilter
 SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'max_price', "products_0"."max_price") AS "json" FROM (SELECT "products"."id", max("products"."price") AS "max_price" FROM "products" WHERE ((((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2))) AND (("products"."id") > '10' :: bigint))) GROUP BY "products"."id" LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/aggFunctionWithGroupBy
+SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('price', "products_0"."price", 'name', "products_0"."name", 'count_id', "products_0"."count_id") AS "json" FROM (SELECT "products"."price", "products"."name", count("products"."id") AS "count_id" FROM "products" WHERE (((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2)))) GROUP BY "products"."price", "products"."name" ORDER BY "count_id" DESC LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/aggFunctionOrderByNotSelected
+SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('price', "products_0"."price", 'count_id', "products_0"."count_id") AS "json" FROM (SELECT "products"."price", count("products"."id") AS "count_id", max("products"."price") AS "max_price" FROM "products" WHERE (((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2)))) GROUP BY "products"."price" ORDER BY "max_price" DESC LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/aggFunctionOnRelationship
+SELECT json_build_object('users', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "users_0"."id", 'products_aggregate', "__sel_1"."json") AS "json" FROM (SELECT "users"."id" FROM "users" LIMIT ('20') :: integer) AS "users_0" LEFT OUTER JOIN LATERAL (SELECT json_build_object('count_id', "products_1"."count_id", 'max_price', "products_1"."max_price") AS "json" FROM (SELECT count("products"."id") AS "count_id", max("products"."price") AS "max_price" FROM "products" WHERE ((("products"."user_id") = ("users_0"."id")) AND (((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2))) AND (("products"."price") > '5' :: numeric(7,2))))) AS "products_1")  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/computedColumns
+SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'discount_price', "products_0"."discount_price", 'user', "__sel_1"."json") AS "json" FROM (SELECT "products"."id", ("products"."price" * 0.9) AS "discount_price", "products"."user_id" FROM "products" WHERE (((("products"."price" * 0.9)) < '5' :: numeric(7,2))) ORDER BY ("products"."price" * 0.9) DESC LIMIT ('20') :: integer) AS "products_0" LEFT OUTER JOIN LATERAL (SELECT json_build_object('display_name', "users_1"."display_name") AS "json" FROM (SELECT (display_name("users")) AS "display_name" FROM "users" WHERE ((("users"."id") = ("products_0"."user_id"))) LIMIT ('1') :: integer) AS "users_1")  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/tableInOtherSchema
+SELECT json_build_object('users', "__sel_0"."json", 'billing_invoices', "__sel_2"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_2"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "billing_invoices_2"."id", 'amount', "billing_invoices_2"."amount", 'user', "__sel_3"."json") AS "json" FROM (SELECT "billing_invoices"."id", "billing_invoices"."amount", "billing_invoices"."user_id" FROM "billing"."invoices" AS "billing_invoices" WHERE ((("billing_invoices"."amount") > '100' :: numeric(7,2))) LIMIT ('20') :: integer) AS "billing_invoices_2" LEFT OUTER JOIN LATERAL (SELECT json_build_object('email', "users_3"."email") AS "json" FROM (SELECT "users"."email" FROM "users" WHERE ((("users"."id") = ("billing_invoices_2"."user_id"))) LIMIT ('1') :: integer) AS "users_3")  AS "__sel_3" ON ('true')) AS "__sel_2") AS "__sel_2", (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "users_0"."id", 'billing_invoices', "__sel_1"."json") AS "json" FROM (SELECT "users"."id" FROM "users" LIMIT ('20') :: integer) AS "users_0" LEFT OUTER JOIN LATERAL (SELECT coalesce(json_agg("__sel_1"."json"), '[]') as "json" FROM (SELECT json_build_object('amount', "billing_invoices_1"."amount") AS "json" FROM (SELECT "billing_invoices"."amount" FROM "billing"."invoices" AS "billing_invoices" WHERE ((("billing_invoices"."user_id") = ("users_0"."id"))) LIMIT ('20') :: integer) AS "billing_invoices_1") AS "__sel_1")  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/computedColumnInOtherSchema
+SELECT json_build_object('billing_invoices', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "billing_invoices_0"."id", 'amount_due', "billing_invoices_0"."amount_due") AS "json" FROM (SELECT "billing_invoices"."id", (amount_due("billing_invoices")) AS "amount_due" FROM "billing"."invoices" AS "billing_invoices" ORDER BY (amount_due("billing_invoices")) DESC LIMIT ('20') :: integer) AS "billing_invoices_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/compositeForeignKey
+SELECT json_build_object('order_items', "__sel_0"."json", 'orders', "__sel_2"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_2"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "orders_2"."id", 'total', "orders_2"."total", 'order_items', "__sel_3"."json") AS "json" FROM (SELECT "orders"."id", "orders"."total", "orders"."tenant_id" FROM "orders" LIMIT ('20') :: integer) AS "orders_2" LEFT OUTER JOIN LATERAL (SELECT coalesce(json_agg("__sel_3"."json"), '[]') as "json" FROM (SELECT json_build_object('quantity', "order_items_3"."quantity") AS "json" FROM (SELECT "order_items"."quantity" FROM "order_items" WHERE ((("order_items"."tenant_id") = ("orders_2"."tenant_id") AND ("order_items"."order_id") = ("orders_2"."id"))) LIMIT ('20') :: integer) AS "order_items_3") AS "__sel_3")  AS "__sel_3" ON ('true')) AS "__sel_2") AS "__sel_2", (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "order_items_0"."id", 'order', "__sel_1"."json") AS "json" FROM (SELECT "order_items"."id", "order_items"."tenant_id", "order_items"."order_id" FROM "order_items" LIMIT ('20') :: integer) AS "order_items_0" LEFT OUTER JOIN LATERAL (SELECT json_build_object('total', "orders_1"."total") AS "json" FROM (SELECT "orders"."total" FROM "orders" WHERE ((("orders"."tenant_id") = ("order_items_0"."tenant_id") AND ("orders"."id") = ("order_items_0"."order_id"))) LIMIT ('1') :: integer) AS "orders_1")  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/polymorphicRelationship
+SELECT json_build_object('products', "__sel_0"."json", 'comments', "__sel_2"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_2"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "comments_2"."id", 'body', "comments_2"."body", 'commentable', "__sel_3"."json") AS "json" FROM (SELECT "comments"."id", "comments"."body", "comments"."commentable_type", "comments"."commentable_id" FROM "comments" LIMIT ('20') :: integer) AS "comments_2" LEFT OUTER JOIN LATERAL (SELECT json_build_object('__typename', ('products' :: text), 'name', "products"."name") AS "json" FROM "products" WHERE ((("comments_2"."commentable_type") = 'Product') AND (("products"."id") = ("comments_2"."commentable_id"))) UNION ALL SELECT json_build_object('__typename', ('users' :: text), 'email', "users"."email") AS "json" FROM "users" WHERE ((("comments_2"."commentable_type") = 'User') AND (("users"."id") = ("comments_2"."commentable_id"))))  AS "__sel_3" ON ('true')) AS "__sel_2") AS "__sel_2", (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('name', "products_0"."name", 'comments', "__sel_1"."json") AS "json" FROM (SELECT "products"."name", "products"."id" FROM "products" LIMIT ('20') :: integer) AS "products_0" LEFT OUTER JOIN LATERAL (SELECT coalesce(json_agg("__sel_1"."json"), '[]') as "json" FROM (SELECT json_build_object('body', "comments_1"."body") AS "json" FROM (SELECT "comments"."body" FROM "comments" WHERE ((("comments"."commentable_id") = ("products_0"."id") AND ("comments"."commentable_type") = ('Product'))) LIMIT ('20') :: integer) AS "comments_1") AS "__sel_1")  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/polymorphicRelationshipWithRole
+SELECT json_build_object('comments', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "comments_0"."id", 'commentable', "__sel_1"."json") AS "json" FROM (SELECT "comments"."id", "comments"."commentable_type", "comments"."commentable_id" FROM "comments" LIMIT ('20') :: integer) AS "comments_0" LEFT OUTER JOIN LATERAL (SELECT json_build_object('__typename', ('products' :: text), 'name', "products"."name") AS "json" FROM "products" WHERE ((("comments_0"."commentable_type") = 'Product') AND (("products"."id") = ("comments_0"."commentable_id")) AND ((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2)))) UNION ALL SELECT json_build_object('__typename', ('users' :: text), 'email', "users"."email") AS "json" FROM "users" WHERE ((("comments_0"."commentable_type") = 'User') AND (("users"."id") = ("comments_0"."commentable_id"))))  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/polymorphicRelationshipWithAnon
+SELECT json_build_object('comments', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "comments_0"."id", 'commentable', "__sel_1"."json") AS "json" FROM (SELECT "comments"."id", "comments"."commentable_type", "comments"."commentable_id" FROM "comments" LIMIT ('20') :: integer) AS "comments_0" LEFT OUTER JOIN LATERAL (SELECT json_build_object('__typename', ('products' :: text), 'name', "products"."name") AS "json" FROM "products" WHERE ((("comments_0"."commentable_type") = 'Product') AND (("products"."id") = ("comments_0"."commentable_id"))))  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/recursiveRelationship
+SELECT json_build_object('comment', "__sel_0"."json") as "__root" FROM (SELECT json_build_object('id', "comments_0"."id", 'body', "comments_0"."body", 'replies', "__sel_1"."json") AS "json" FROM (SELECT "comments"."id", "comments"."body" FROM "comments" WHERE ((("comments"."id") =  '{{id}}' :: bigint)) LIMIT ('1') :: integer) AS "comments_0" LEFT OUTER JOIN LATERAL (SELECT coalesce(json_agg("__sel_1"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "comments_1"."id", 'body', "comments_1"."body", 'parent_id', "comments_1"."parent_id", '__depth', "comments_1"."__depth") AS "json" FROM (SELECT "comments"."id", "comments"."body", "comments"."parent_id", "comments"."__depth" FROM (WITH RECURSIVE "__rcte_comments" AS (SELECT "comments".*, 1 AS "__depth" FROM "comments" WHERE (("comments"."parent_id") = ("comments_0"."id")) UNION ALL SELECT "comments".*, "__rcte_comments"."__depth" + 1 FROM "comments", "__rcte_comments" WHERE (("comments"."parent_id") = ("__rcte_comments"."id")) AND (("__rcte_comments"."__depth") < 5)) SELECT * FROM "__rcte_comments") AS "comments" WHERE ((("comments"."body") IS NOT NULL)) LIMIT ('20') :: integer) AS "comments_1") AS "__sel_1")  AS "__sel_1" ON ('true')) AS "__sel_0"
+=== RUN   TestCompileQuery/spatialQuery
+SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name", 'location', "products_0"."location") AS "json" FROM (SELECT "products"."id", "products"."name", ST_AsGeoJSON("products"."location") :: json AS "location" FROM "products" WHERE (((ST_Within("products"."location", ST_SetSRID(ST_GeomFromGeoJSON( '{{area}}'), 4326) :: geometry)) AND (ST_DWithin("products"."location" :: geography, ST_SetSRID(ST_MakePoint(-122.4194, 37.7749), 4326) :: geography, 2000)))) ORDER BY ST_Distance("products"."location" :: geography, ST_SetSRID(ST_MakePoint(-122.4194, 37.7749), 4326) :: geography) ASC LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/jsonPathQuery
+SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name") AS "json" FROM (SELECT "products"."id", "products"."name" FROM "products" WHERE (((("products"."metadata") @? '$.variants[*] ? (@.stock > 0)' :: jsonpath) AND ((("products"."metadata" #>> '{rating}') :: numeric) >= '4.5' :: numeric) AND (("products"."metadata" #>> '{address,city}') = 'Berlin' :: text))) ORDER BY ("products"."metadata" #> '{rating}') DESC LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/jsonPathQueryWithVars
+SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name") AS "json" FROM (SELECT "products"."id", "products"."name" FROM "products" WHERE (((("products"."metadata" #>> '{address,city}') =  '{{city}}' :: text) AND ((("products"."metadata" #>> '{price}') :: numeric) >  '{{min}}' :: numeric))) LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/setReturningFunction
+SELECT json_build_object('search_products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name", 'user', "__sel_1"."json") AS "json" FROM (SELECT "products"."id", "products"."name", "products"."price", "products"."user_id" FROM search_products("q" => '{{query}}' :: text, "max_price" => '20' :: numeric) AS "products" ORDER BY "products"."price" DESC LIMIT ('5') :: integer) AS "products_0" LEFT OUTER JOIN LATERAL (SELECT json_build_object('email', "users_1"."email") AS "json" FROM (SELECT "users"."email" FROM "users" WHERE ((("users"."id") = ("products_0"."user_id"))) LIMIT ('1') :: integer) AS "users_1")  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/setReturningFunctionWithRole
+SELECT json_build_object('search_products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name", 'price', "products_0"."price") AS "json" FROM (SELECT "products"."id", "products"."name", "products"."price" FROM search_products("q" => '{{query}}' :: text, "max_price" => '20' :: numeric) AS "products" WHERE (((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2)))) LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/setReturningFunctionWithAnon
+SELECT json_build_object('search_products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name") AS "json" FROM (SELECT "products"."id", "products"."name", "products"."price" FROM search_products("q" => '{{query}}' :: text, "max_price" => '20' :: numeric) AS "products" LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/tableReturningFunction
+SELECT json_build_object('top_buyers', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('user_id', "top_buyers_0"."user_id", 'total', "top_buyers_0"."total") AS "json" FROM (SELECT "top_buyers"."user_id", "top_buyers"."total" FROM top_buyers("since" => '{{since}}' :: timestamp without time zone) AS "top_buyers" WHERE ((("top_buyers"."total") > '100' :: numeric)) LIMIT ('20') :: integer) AS "top_buyers_0") AS "__sel_0") AS "__sel_0"
 === RUN   TestCompileQuery/syntheticTables
 SELECT json_build_object('me', "__sel_0"."json") as "__root" FROM (SELECT json_build_object() AS "json" FROM (SELECT "users"."email" FROM "users" WHERE ((("users"."id") =  '{{user_id}}' :: bigint)) LIMIT ('1') :: integer) AS "users_0") AS "__sel_0"
 === RUN   TestCompileQuery/queryWithVariables
 SELECT json_build_object('product', "__sel_0"."json") as "__root" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name") AS "json" FROM (SELECT "products"."id", "products"."name" FROM "products" WHERE (((("products"."price") =  '{{product_price}}' :: numeric(7,2)) AND (("products"."id") =  '{{product_id}}' :: bigint) AND ((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2))))) LIMIT ('1') :: integer) AS "products_0") AS "__sel_0"
 === RUN   TestCompileQuery/withWhereOnRelations
-SELECT json_build_object('users', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "users_0"."id", 'email', "users_0"."email") AS "json" FROM (SELECT "users"."id", "users"."email" FROM "users" WHERE (NOT EXISTS (SELECT 1 FROM products WHERE (("products"."user_id") = ("users"."id")) AND ((("products"."price") > '3' :: numeric(7,2))))) LIMIT ('20') :: integer) AS "users_0") AS "__sel_0") AS "__sel_0"
+SELECT json_build_object('users', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "users_0"."id", 'email', "users_0"."email") AS "json" FROM (SELECT "users"."id", "users"."email" FROM "users" WHERE (NOT EXISTS (SELECT 1 FROM "products" WHERE (("products"."user_id") = ("users"."id")) AND ((("products"."price") > '3' :: numeric(7,2))))) LIMIT ('20') :: integer) AS "users_0") AS "__sel_0") AS "__sel_0"
 === RUN   TestCompileQuery/multiRoot
 SELECT json_build_object('customer', "__sel_0"."json", 'user', "__sel_1"."json", 'product', "__sel_2"."json") as "__root" FROM (SELECT json_build_object('id', "products_2"."id", 'name', "products_2"."name", 'customers', "__sel_3"."json", 'customer', "__sel_4"."json") AS "json" FROM (SELECT "products"."id", "products"."name" FROM "products" WHERE (((("products"."price") > '0' :: numeric(7,2)) AND (("products"."price") < '8' :: numeric(7,2)))) LIMIT ('1') :: integer) AS "products_2" LEFT OUTER JOIN LATERAL (SELECT json_build_object('email', "customers_4"."email") AS "json" FROM (SELECT "customers"."email" FROM "customers" LEFT OUTER JOIN "purchases" ON (("purchases"."product_id") = ("products_2"."id")) WHERE ((("customers"."id") = ("purchases"."customer_id"))) LIMIT ('1') :: integer) AS "customers_4")  AS "__sel_4" ON ('true') LEFT OUTER JOIN LATERAL (SELECT coalesce(json_agg("__sel_3"."json"), '[]') as "json" FROM (SELECT json_build_object('email', "customers_3"."email") AS "json" FROM (SELECT "customers"."email" FROM "customers" LEFT OUTER JOIN "purchases" ON (("purchases"."product_id") = ("products_2"."id")) WHERE ((("customers"."id") = ("purchases"."customer_id"))) LIMIT ('20') :: integer) AS "customers_3") AS "__sel_3")  AS "__sel_3" ON ('true')) AS "__sel_2", (SELECT json_build_object('id', "users_1"."id", 'email', "users_1"."email") AS "json" FROM (SELECT "users"."id", "users"."email" FROM "users" LIMIT ('1') :: integer) AS "users_1") AS "__sel_1", (SELECT json_build_object('id', "customers_0"."id") AS "json" FROM (SELECT "customers"."id" FROM "customers" LIMIT ('1') :: integer) AS "customers_0") AS "__sel_0"
 === RUN   TestCompileQuery/jsonColumnAsTable
 SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name", 'tag_count', "__sel_1"."json") AS "json" FROM (SELECT "products"."id", "products"."name" FROM "products" LIMIT ('20') :: integer) AS "products_0" LEFT OUTER JOIN LATERAL (SELECT json_build_object('count', "tag_count_1"."count", 'tags', "__sel_2"."json") AS "json" FROM (SELECT "tag_count"."count", "tag_count"."tag_id" FROM "products", json_to_recordset("products"."tag_count") AS "tag_count"(tag_id bigint, count int) WHERE ((("products"."id") = ("products_0"."id"))) LIMIT ('1') :: integer) AS "tag_count_1" LEFT OUTER JOIN LATERAL (SELECT coalesce(json_agg("__sel_2"."json"), '[]') as "json" FROM (SELECT json_build_object('name', "tags_2"."name") AS "json" FROM (SELECT "tags"."name" FROM "tags" WHERE ((("tags"."id") = ("tag_count_1"."tag_id"))) LIMIT ('20') :: integer) AS "tags_2") AS "__sel_2")  AS "__sel_2" ON ('true'))  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
 === RUN   TestCompileQuery/withCursor
-SELECT json_build_object('products', "__sel_0"."json", 'products_cursor', "__sel_0"."cursor") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json", CONCAT_WS(',', max("__cur_0"), max("__cur_1")) as "cursor" FROM (SELECT json_build_object('name', "products_0"."name") AS "json", LAST_VALUE("products_0"."price") OVER() AS "__cur_0", LAST_VALUE("products_0"."id") OVER() AS "__cur_1" FROM (WITH "__cur" AS (SELECT a[1] as "price", a[2] as "id" FROM string_to_array('{{cursor}}', ',') as a) SELECT "products"."name", "products"."id", "products"."price" FROM "products", "__cur" WHERE (((("products"."price") < "__cur"."price" :: numeric(7,2)) OR ((("products"."price") = "__cur"."price" :: numeric(7,2)) AND (("products"."id") > "__cur"."id" :: bigint)))) ORDER BY "products"."price" DESC, "products"."id" ASC LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+SELECT json_build_object('products', "__sel_0"."json", 'products_cursor', "__sel_0"."cursor") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json", max("__cursor") as "cursor" FROM (SELECT json_build_object('name', "products_0"."name") AS "json", LAST_VALUE(CONCAT('1:a8da7e5a:', json_build_array("products_0"."price", "products_0"."id"))) OVER() AS "__cursor" FROM (WITH "__cur" AS (SELECT a->>0 AS "price", a->>1 AS "id" FROM (SELECT NULLIF('{{cursor}}', '') :: json AS a) AS "__cur_json") SELECT "products"."name", "products"."id", "products"."price" FROM "products", "__cur" WHERE ((("__cur"."id" IS NULL) OR (((("__cur"."price" :: numeric(7,2)) IS NULL AND "products"."price" IS NOT NULL) OR "products"."price" < ("__cur"."price" :: numeric(7,2)))) OR (("products"."price" IS NOT DISTINCT FROM ("__cur"."price" :: numeric(7,2))) AND ("products"."id" > ("__cur"."id" :: bigint))))) ORDER BY "products"."price" DESC, "products"."id" ASC LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/withCursorNulls
+SELECT json_build_object('products', "__sel_0"."json", 'products_cursor', "__sel_0"."cursor") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json", max("__cursor") as "cursor" FROM (SELECT json_build_object('name', "products_0"."name") AS "json", LAST_VALUE(CONCAT('1:02f48824:', json_build_array("products_0"."name", "products_0"."price", "products_0"."id"))) OVER() AS "__cursor" FROM (WITH "__cur" AS (SELECT a->>0 AS "name", a->>1 AS "price", a->>2 AS "id" FROM (SELECT NULLIF('{{cursor}}', '') :: json AS a) AS "__cur_json") SELECT "products"."name", "products"."id", "products"."price" FROM "products", "__cur" WHERE ((("__cur"."id" IS NULL) OR ((("__cur"."name" :: character varying) IS NOT NULL AND ("products"."name" < ("__cur"."name" :: character varying) OR "products"."name" IS NULL))) OR (("products"."name" IS NOT DISTINCT FROM ("__cur"."name" :: character varying)) AND ((("__cur"."price" :: numeric(7,2)) IS NULL AND "products"."price" IS NOT NULL) OR "products"."price" > ("__cur"."price" :: numeric(7,2)))) OR (("products"."name" IS NOT DISTINCT FROM ("__cur"."name" :: character varying)) AND ("products"."price" IS NOT DISTINCT FROM ("__cur"."price" :: numeric(7,2))) AND ("products"."id" < ("__cur"."id" :: bigint))))) ORDER BY "products"."name" DESC NULLS LAST, "products"."price" ASC NULLS FIRST, "products"."id" DESC LIMIT ('5') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/relayConnection
+SELECT json_build_object('products_connection', "__sel_0"."json") as "__root" FROM (SELECT json_build_object('edges', coalesce(json_agg("__sel_0"."json" ORDER BY "__sel_0"."__rn") FILTER (WHERE "__sel_0"."__rn" <= ('10') :: integer), '[]'), 'pageInfo', json_build_object('hasNextPage', (count(*) > ('10') :: integer), 'hasPreviousPage', ('{{cursor}}' != ''), 'startCursor', max("__sel_0"."__cursor") FILTER (WHERE "__sel_0"."__rn" = 1), 'endCursor', max("__sel_0"."__cursor") FILTER (WHERE "__sel_0"."__rn" = least("__sel_0"."__cnt", ('10') :: integer))), 'totalCount', (SELECT count(*) FROM "products")) AS "json" FROM (SELECT json_build_object('cursor', CONCAT('1:a8da7e5a:', json_build_array("products_0"."price", "products_0"."id")), 'node', json_build_object('id', "products_0"."id", 'name', "products_0"."name")) AS "json", CONCAT('1:a8da7e5a:', json_build_array("products_0"."price", "products_0"."id")) AS "__cursor", ROW_NUMBER() OVER (ORDER BY "products_0"."price" DESC, "products_0"."id" ASC) AS "__rn", count(*) OVER() AS "__cnt" FROM (WITH "__cur" AS (SELECT a->>0 AS "price", a->>1 AS "id" FROM (SELECT NULLIF('{{cursor}}', '') :: json AS a) AS "__cur_json") SELECT "products"."id", "products"."name", "products"."price" FROM "products", "__cur" WHERE ((("__cur"."id" IS NULL) OR (((("__cur"."price" :: numeric(7,2)) IS NULL AND "products"."price" IS NOT NULL) OR "products"."price" < ("__cur"."price" :: numeric(7,2)))) OR (("products"."price" IS NOT DISTINCT FROM ("__cur"."price" :: numeric(7,2))) AND ("products"."id" > ("__cur"."id" :: bigint))))) ORDER BY "products"."price" DESC, "products"."id" ASC LIMIT ('10') :: integer + 1) AS "products_0") AS "__sel_0") AS "__sel_0"
+=== RUN   TestCompileQuery/nestedRelayConnection
+SELECT json_build_object('users', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('email', "users_0"."email", 'purchases', "__sel_1"."json") AS "json" FROM (SELECT "users"."email", "users"."id" FROM "users" LIMIT ('20') :: integer) AS "users_0" LEFT OUTER JOIN LATERAL (SELECT json_build_object('edges', coalesce(json_agg("__sel_1"."json" ORDER BY "__sel_1"."__rn" DESC) FILTER (WHERE "__sel_1"."__rn" <= ('5') :: integer), '[]'), 'pageInfo', json_build_object('hasPreviousPage', (count(*) > ('5') :: integer), 'startCursor', max("__sel_1"."__cursor") FILTER (WHERE "__sel_1"."__rn" = least("__sel_1"."__cnt", ('5') :: integer)))) AS "json" FROM (SELECT json_build_object('node', json_build_object('name', "products_1"."name")) AS "json", CONCAT('1:f043de77:', json_build_array("products_1"."id")) AS "__cursor", ROW_NUMBER() OVER (ORDER BY "products_1"."id" DESC) AS "__rn", count(*) OVER() AS "__cnt" FROM (SELECT "products"."name", "products"."id" FROM "products" WHERE ((("products"."user_id") = ("users_0"."id"))) ORDER BY "products"."id" DESC LIMIT ('5') :: integer + 1) AS "products_1") AS "__sel_1")  AS "__sel_1" ON ('true')) AS "__sel_0") AS "__sel_0"
 === RUN   TestCompileQuery/nullForAuthRequiredInAnon
 SELECT json_build_object('products', "__sel_0"."json") as "__root" FROM (SELECT coalesce(json_agg("__sel_0"."json"), '[]') as "json" FROM (SELECT json_build_object('id', "products_0"."id", 'name', "products_0"."name", 'user', NULL) AS "json" FROM (SELECT "products"."id", "products"."name", "products"."user_id" FROM "products" LIMIT ('20') :: integer) AS "products_0") AS "__sel_0") AS "__sel_0"
 === RUN   TestCompileQuery/blockedQuery
@@ -101,6 +148,7 @@ SELECT json_build_object('users', "__sel_0"."json") as "__root" FROM (SELECT coa
     --- PASS: TestCompileQuery/withWhereMultiOr (0.00s)
     --- PASS: TestCompileQuery/fetchByID (0.00s)
     --- PASS: TestCompileQuery/searchQuery (0.00s)
+    --- PASS: TestCompileQuery/searchQueryWithConfig (0.00s)
     --- PASS: TestCompileQuery/oneToMany (0.00s)
     --- PASS: TestCompileQuery/oneToManyReverse (0.00s)
     --- PASS: TestCompileQuery/oneToManyArray (0.00s)
@@ -110,12 +158,33 @@ SELECT json_build_object('users', "__sel_0"."json") as "__root" FROM (SELECT coa
     --- PASS: TestCompileQuery/aggFunctionBlockedByCol (0.00s)
     --- PASS: TestCompileQuery/aggFunctionDisabled (0.00s)
     --- PASS: TestCompileQuery/aggFunctionWithFilter (0.00s)
+    --- PASS: TestCompileQuery/aggFunctionWithGroupBy (0.00s)
+    --- PASS: TestCompileQuery/aggFunctionOrderByNotSelected (0.00s)
+    --- PASS: TestCompileQuery/aggFunctionOnRelationship (0.00s)
+    --- PASS: TestCompileQuery/computedColumns (0.00s)
+    --- PASS: TestCompileQuery/tableInOtherSchema (0.00s)
+    --- PASS: TestCompileQuery/computedColumnInOtherSchema (0.00s)
+    --- PASS: TestCompileQuery/compositeForeignKey (0.00s)
+    --- PASS: TestCompileQuery/polymorphicRelationship (0.00s)
+    --- PASS: TestCompileQuery/polymorphicRelationshipWithRole (0.00s)
+    --- PASS: TestCompileQuery/polymorphicRelationshipWithAnon (0.00s)
+    --- PASS: TestCompileQuery/recursiveRelationship (0.00s)
+    --- PASS: TestCompileQuery/spatialQuery (0.00s)
+    --- PASS: TestCompileQuery/jsonPathQuery (0.00s)
+    --- PASS: TestCompileQuery/jsonPathQueryWithVars (0.00s)
+    --- PASS: TestCompileQuery/setReturningFunction (0.00s)
+    --- PASS: TestCompileQuery/setReturningFunctionWithRole (0.00s)
+    --- PASS: TestCompileQuery/setReturningFunctionWithAnon (0.00s)
+    --- PASS: TestCompileQuery/tableReturningFunction (0.00s)
     --- PASS: TestCompileQuery/syntheticTables (0.00s)
     --- PASS: TestCompileQuery/queryWithVariables (0.00s)
     --- PASS: TestCompileQuery/withWhereOnRelations (0.00s)
     --- PASS: TestCompileQuery/multiRoot (0.00s)
     --- PASS: TestCompileQuery/jsonColumnAsTable (0.00s)
     --- PASS: TestCompileQuery/withCursor (0.00s)
+    --- PASS: TestCompileQuery/withCursorNulls (0.00s)
+    --- PASS: TestCompileQuery/relayConnection (0.00s)
+    --- PASS: TestCompileQuery/nestedRelayConnection (0.00s)
     --- PASS: TestCompileQuery/nullForAuthRequiredInAnon (0.00s)
     --- PASS: TestCompileQuery/blockedQuery (0.00s)
     --- PASS: TestCompileQuery/blockedFunctions (0.00s)
@@ -82,7 +82,7 @@ func (c *compilerContext) renderUpdateStmt(w io.Writer, qc *qcode.QCode, item re
 	io.WriteString(c.w, ` AS (`)
 
 	io.WriteString(w, `UPDATE `)
-	quoted(w, ti.Name)
+	quotedTable(w, ti)
 	io.WriteString(w, ` SET (`)
 	renderInsertUpdateColumns(w, qc, jt, ti, sk, false)
 	renderNestedUpdateRelColumns(w, item.kvitem, false)
@@ -101,7 +101,7 @@ func (c *compilerContext) renderUpdateStmt(w io.Writer, qc *qcode.QCode, item re
 	}
 
 	io.WriteString(w, `(NULL::`)
-	io.WriteString(w, ti.Name)
+	rowType(w, ti)
 
 	if len(item.path) == 0 {
 		io.WriteString(w, `, i.j) t)`)
@@ -219,7 +219,7 @@ func (c *compilerContext) renderDelete(qc *qcode.QCode, w io.Writer,
 	quoted(c.w, ti.Name)
 
 	io.WriteString(c.w, ` AS (DELETE FROM `)
-	quoted(c.w, ti.Name)
+	quotedTable(c.w, ti)
 	io.WriteString(c.w, ` WHERE `)
 
 	if root.Where == nil {
@@ -0,0 +1,44 @@
+package qcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateAggregate checks that a '<table>_aggregate' selector only
+// selects aggregate functions since it's rendered as a single row
+func validateAggregate(sel *Select, fields []Field, field *Field) error {
+	if !sel.Functions {
+		return fmt.Errorf("aggregate functions are disabled for '%s'", sel.Name)
+	}
+
+	if len(sel.OrderBy) != 0 || len(sel.GroupBy) != 0 || len(sel.DistinctOn) != 0 ||
+		sel.Paging.Type != PtOffset {
+		return fmt.Errorf("'%s' only supports the where argument", sel.FieldName)
+	}
+
+	if len(field.Children) == 0 {
+		return fmt.Errorf("'%s' must select at least one aggregate function", sel.FieldName)
+	}
+
+	for _, cid := range field.Children {
+		f := &fields[cid]
+
+		if len(f.Children) != 0 || !isAggregate(f.Name) {
+			return fmt.Errorf("'%s' can only select aggregate functions like count_id, found '%s'",
+				sel.FieldName, f.Name)
+		}
+	}
+
+	return nil
+}
+
+func isAggregate(col string) bool {
+	for _, v := range []string{"avg_", "count_", "max_", "min_", "sum_",
+		"stddev_", "variance_", "var_pop_", "var_samp_"} {
+		if strings.HasPrefix(col, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package qcode
+
+import (
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	qc, err := qcompile.Compile([]byte(`query { users { id products_aggregate { count_id } } }`), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := qc.Selects[1]
+
+	if !s.Aggregate || s.Name != "products" || s.FieldName != "products_aggregate" {
+		t.Fatalf("unexpected aggregate select: %s / %s", s.Name, s.FieldName)
+	}
+}
+
+func TestInvalidAggregate(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	tests := []string{
+		`query { users { id products_aggregate { id count_id } } }`,
+		`query { users { id products_aggregate(order_by: { id: desc }) { count_id } } }`,
+		`query { users { id products_aggregate { count_id user { id } } } }`,
+	}
+
+	for _, v := range tests {
+		if _, err := qcompile.Compile([]byte(v), "user"); err == nil {
+			t.Fatalf("%s: expecting an error", v)
+		}
+	}
+}
@@ -34,6 +34,20 @@ type DeleteConfig struct {
 	Columns []string
 }
 
+// RoleLimits caps the cost of the queries a role can run, a zero value
+// means no limit
+type RoleLimits struct {
+	// MaxDepth is the maximum nesting of tables
+	MaxDepth int
+
+	// MaxRows is the maximum total of the estimated rows fetched, the
+	// estimate for a table is its limit times that of its parent
+	MaxRows int
+
+	// MaxAggregates is the maximum number of aggregate functions
+	MaxAggregates int
+}
+
 type TRConfig struct {
 	Query  QueryConfig
 	Insert InsertConfig
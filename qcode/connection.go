@@ -0,0 +1,105 @@
+package qcode
+
+import (
+	"fmt"
+)
+
+// Connection is set on '<table>_connection' selectors which return the rows
+// in the Relay connection shape, it holds the names of the selected fields
+// and is empty for the fields that are not selected
+type Connection struct {
+	Edges      string
+	EdgeCursor string
+	Node       string
+	PageInfo   string
+	TotalCount string
+
+	// PageInfoCols are the selected fields of pageInfo like hasNextPage
+	PageInfoCols []Column
+}
+
+// compileConnection sets the fields of the connection selected and returns
+// the fields under 'edges.node' which are the columns and tables selected
+func compileConnection(sel *Select, fields []Field, field *Field) ([]int32, error) {
+	var node []int32
+
+	c := &Connection{}
+
+	if sel.Paging.Type == PtOffset {
+		if len(sel.Paging.Offset) != 0 {
+			return nil, fmt.Errorf("'%s' does not support the offset argument", sel.FieldName)
+		}
+		sel.Paging.Type = PtForward
+	}
+
+	for _, cid := range field.Children {
+		f := &fields[cid]
+
+		switch f.Name {
+		case "edges":
+			c.Edges = connFieldName(f)
+
+			for _, id := range f.Children {
+				ef := &fields[id]
+
+				switch ef.Name {
+				case "cursor":
+					c.EdgeCursor = connFieldName(ef)
+				case "node":
+					c.Node = connFieldName(ef)
+					node = ef.Children
+				default:
+					return nil, fmt.Errorf("unknown field '%s' in edges of '%s'", ef.Name, sel.FieldName)
+				}
+			}
+
+		case "pageinfo":
+			c.PageInfo = connFieldName(f)
+
+			for _, id := range f.Children {
+				pf := &fields[id]
+
+				switch pf.Name {
+				case "hasnextpage", "haspreviouspage", "startcursor", "endcursor":
+					c.PageInfoCols = append(c.PageInfoCols, Column{Name: pf.Name, FieldName: connFieldName(pf)})
+				default:
+					return nil, fmt.Errorf("unknown field '%s' in pageInfo of '%s'", pf.Name, sel.FieldName)
+				}
+			}
+
+		case "totalcount":
+			c.TotalCount = connFieldName(f)
+
+		default:
+			return nil, fmt.Errorf("unknown field '%s' in '%s' expecting edges, pageInfo or totalCount",
+				f.Name, sel.FieldName)
+		}
+	}
+
+	sel.Connection = c
+	return node, nil
+}
+
+// connFieldName returns the alias of the field or the name of the
+// field as defined by Relay since names are lowercased in the query
+func connFieldName(f *Field) string {
+	if len(f.Alias) != 0 {
+		return f.Alias
+	}
+
+	switch f.Name {
+	case "pageinfo":
+		return "pageInfo"
+	case "totalcount":
+		return "totalCount"
+	case "hasnextpage":
+		return "hasNextPage"
+	case "haspreviouspage":
+		return "hasPreviousPage"
+	case "startcursor":
+		return "startCursor"
+	case "endcursor":
+		return "endCursor"
+	}
+	return f.Name
+}
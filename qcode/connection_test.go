@@ -0,0 +1,60 @@
+package qcode
+
+import (
+	"testing"
+)
+
+func TestConnection(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	gql := `query {
+		products_connection(last: 5) {
+			totalCount
+			pageInfo { hasNextPage end: endCursor }
+			edges { cursor node { id name user { id } } }
+		}
+	}`
+
+	qc, err := qcompile.Compile([]byte(gql), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := qc.Selects[0]
+	c := s.Connection
+
+	if s.Name != "products" || s.FieldName != "products_connection" || s.Paging.Type != PtBackward {
+		t.Fatalf("unexpected select: %s / %s", s.Name, s.FieldName)
+	}
+
+	if c == nil || c.Edges != "edges" || c.EdgeCursor != "cursor" || c.Node != "node" ||
+		c.PageInfo != "pageInfo" || c.TotalCount != "totalCount" {
+		t.Fatalf("unexpected connection: %+v", c)
+	}
+
+	if len(c.PageInfoCols) != 2 || c.PageInfoCols[0].FieldName != "hasNextPage" ||
+		c.PageInfoCols[1].FieldName != "end" {
+		t.Fatalf("unexpected page info: %+v", c.PageInfoCols)
+	}
+
+	if len(s.Cols) != 2 || len(s.Children) != 1 || qc.Selects[1].Name != "user" {
+		t.Fatalf("unexpected node: %+v", s.Cols)
+	}
+}
+
+func TestInvalidConnection(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	tests := []string{
+		`query { products_connection { nodes { id } } }`,
+		`query { products_connection { edges { id } } }`,
+		`query { products_connection { pageInfo { count } } }`,
+		`query { products_connection(offset: $offset) { totalCount } }`,
+	}
+
+	for _, v := range tests {
+		if _, err := qcompile.Compile([]byte(v), "user"); err == nil {
+			t.Fatalf("%s: expecting an error", v)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package qcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// skipField evaluates the @skip and @include directives on a field and
+// returns true if the field should not be included in the result
+func skipField(qc *QCode, f *Field) (bool, error) {
+	skipped := false
+
+	for _, d := range f.Directives {
+		var skip bool
+
+		switch d.Name {
+		case "skip":
+			skip = true
+		case "include":
+			skip = false
+		default:
+			return false, fmt.Errorf("unknown directive '@%s' on field '%s'", d.Name, f.Name)
+		}
+
+		if len(d.Args) != 1 || d.Args[0].Name != "if" {
+			return false, fmt.Errorf("directive '@%s' requires a single 'if' argument", d.Name)
+		}
+		v := d.Args[0].Val
+
+		var cond bool
+
+		switch v.Type {
+		case NodeBool:
+			cond = (v.Val == "true")
+		case NodeVar:
+			cond = BoolVar(qc.vars, v.Val)
+		default:
+			return false, fmt.Errorf("the 'if' argument of '@%s' must be a boolean or a variable", d.Name)
+		}
+
+		if cond == skip {
+			skipped = true
+		}
+	}
+
+	return skipped, nil
+}
+
+// directiveVars returns the names of the variables used by the
+// @skip and @include directives in the operation
+func directiveVars(op *Operation) []string {
+	var names []string
+
+	for i := range op.Fields {
+		for _, d := range op.Fields[i].Directives {
+			for _, a := range d.Args {
+				if a.Val == nil || a.Val.Type != NodeVar || hasString(names, a.Val.Val) {
+					continue
+				}
+				names = append(names, a.Val.Val)
+			}
+		}
+	}
+
+	return names
+}
+
+// BoolVar returns true if the variable is set to true, variable names
+// are matched ignoring case as they are lowercased in the query
+func BoolVar(vars map[string]json.RawMessage, name string) bool {
+	v, ok := vars[name]
+
+	if !ok {
+		for k, kv := range vars {
+			if strings.EqualFold(k, name) {
+				v = kv
+				break
+			}
+		}
+	}
+
+	return string(v) == "true"
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DirectiveVars returns the names of the variables used by the @skip and
+// @include directives in the operation named opName
+func DirectiveVars(query []byte, opName string) ([]string, error) {
+	op, err := Parse(query, opName)
+	if err != nil {
+		return nil, err
+	}
+	defer opPool.Put(op)
+
+	return directiveVars(op), nil
+}
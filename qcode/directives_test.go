@@ -0,0 +1,60 @@
+package qcode
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSkipInclude(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+	gql := []byte(`query {
+		users {
+			id
+			email @skip(if: $hideEmail)
+			full_name @include(if: false)
+			products @include(if: $withProducts) {
+				id
+			}
+		}
+	}`)
+
+	vars := map[string]json.RawMessage{
+		"hideEmail":    json.RawMessage(`true`),
+		"withProducts": json.RawMessage(`true`),
+	}
+
+	qc, err := qcompile.CompileOp(gql, "", vars, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(qc.Selects) != 2 {
+		t.Fatalf("expected 2 selects got %d", len(qc.Selects))
+	}
+
+	if cols := qc.Selects[0].Cols; len(cols) != 1 || cols[0].Name != "id" {
+		t.Fatalf("expected only column 'id' got %v", cols)
+	}
+
+	if len(qc.DirectiveVars) != 2 {
+		t.Fatalf("expected 2 directive variables got %v", qc.DirectiveVars)
+	}
+
+	qc, err = qcompile.CompileOp(gql, "", nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(qc.Selects) != 1 || len(qc.Selects[0].Cols) != 2 {
+		t.Fatal("expected columns 'id' and 'email' and no products")
+	}
+}
+
+func TestInvalidDirective(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	_, err := qcompile.Compile([]byte(`query { users { id @cached(ttl: 10) } }`), "user")
+	if err == nil {
+		t.Fatal("expecting an error for the unknown directive")
+	}
+}
@@ -0,0 +1,228 @@
+package qcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	maxItems = 5000
+)
+
+type fragment struct {
+	name  string
+	on    string
+	items []item
+}
+
+// expandFragments removes the fragment definitions from the tokens of the
+// query and replaces both fragment spreads and inline fragments with the
+// fields they select.
+func (p *Parser) expandFragments() error {
+	if !p.hasFragments() {
+		return nil
+	}
+
+	frags := make(map[string]*fragment)
+	items := make([]item, 0, len(p.items))
+
+	for i := 0; i < len(p.items); i++ {
+		it := p.items[i]
+
+		if it._type != itemName || p.val(it) != "fragment" {
+			e := closingIndex(p.items, i)
+			if e == -1 {
+				e = len(p.items) - 1
+			}
+			items = append(items, p.items[i:(e+1)]...)
+			i = e
+			continue
+		}
+
+		f, e, err := p.parseFragment(i)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := frags[f.name]; ok {
+			return newError(p.input, it.pos, fmt.Sprintf("duplicate fragment '%s'", f.name))
+		}
+
+		frags[f.name] = f
+		i = e
+	}
+
+	var err error
+
+	p.items, err = p.expand(make([]item, 0, len(items)), items, frags, nil, "")
+	return err
+}
+
+func (p *Parser) hasFragments() bool {
+	for _, v := range p.items {
+		if v._type == itemSpread || (v._type == itemName && p.val(v) == "fragment") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFragment parses 'fragment name on table { ... }' starting at index i
+// and returns the fragment and the index of it's closing '}'
+func (p *Parser) parseFragment(i int) (*fragment, int, error) {
+	if i+4 >= len(p.items) ||
+		p.items[i+1]._type != itemName ||
+		p.items[i+2]._type != itemName || p.val(p.items[i+2]) != "on" ||
+		p.items[i+3]._type != itemName ||
+		p.items[i+4]._type != itemObjOpen {
+		return nil, 0, newError(p.input, p.items[i].pos,
+			"expecting a fragment definition 'fragment name on table { ... }'")
+	}
+
+	f := &fragment{
+		name: p.val(p.items[i+1]),
+		on:   strings.ToLower(p.val(p.items[i+3])),
+	}
+
+	e := closingIndex(p.items, i+4)
+	if e == -1 {
+		return nil, 0, newError(p.input, p.items[i].pos,
+			fmt.Sprintf("fragment '%s' missing closing '}'", f.name))
+	}
+	f.items = p.items[(i + 5):e]
+
+	return f, e, nil
+}
+
+// expand replaces the fragments in src with the fields they select, the
+// fields selected directly by a fragment with a type condition on are
+// recorded with it so they can be checked against the table they are on
+func (p *Parser) expand(dst, src []item, frags map[string]*fragment, path []string, on string) ([]item, error) {
+	var err error
+	depth := 0
+
+	for i := 0; i < len(src); i++ {
+		it := src[i]
+
+		if len(dst) >= maxItems {
+			return nil, newError(p.input, it.pos,
+				fmt.Sprintf("query too large after expanding fragments (max %d tokens)", maxItems))
+		}
+
+		if it._type != itemSpread {
+			switch {
+			case it._type == itemObjOpen:
+				depth++
+			case it._type == itemObjClose:
+				depth--
+			case it._type == itemName && depth == 0 && len(on) != 0:
+				if p.conds == nil {
+					p.conds = make(map[Pos]string)
+				}
+				p.conds[it.pos] = on
+			}
+			dst = append(dst, it)
+			continue
+		}
+
+		n := i + 1
+		if n >= len(src) {
+			return nil, newError(p.input, it.pos, "expecting a fragment name or inline fragment after '...'")
+		}
+
+		// inline fragment '... on table { ... }' or '... { ... }'
+		inlineOn := on
+
+		if src[n]._type == itemName && p.val(src[n]) == "on" {
+			if n+1 >= len(src) || src[n+1]._type != itemName {
+				return nil, newError(p.input, it.pos, "expecting a type name after '... on'")
+			}
+			inlineOn = strings.ToLower(p.val(src[n+1]))
+			n += 2
+		}
+
+		if (n < len(src) && src[n]._type == itemDirective) ||
+			(n+1 < len(src) && src[n]._type == itemName && src[n+1]._type == itemDirective) {
+			return nil, newError(p.input, it.pos, "directives are not supported on fragments, use them on fields instead")
+		}
+
+		if n < len(src) && src[n]._type == itemObjOpen {
+			e := closingIndex(src, n)
+			if e == -1 {
+				return nil, newError(p.input, it.pos, "inline fragment missing closing '}'")
+			}
+
+			if dst, err = p.expand(dst, src[(n+1):e], frags, path, inlineOn); err != nil {
+				return nil, err
+			}
+			i = e
+			continue
+		}
+
+		// fragment spread '...name'
+		if src[n]._type != itemName {
+			return nil, newError(p.input, it.pos, "expecting a fragment name or inline fragment after '...'")
+		}
+		name := p.val(src[n])
+
+		f, ok := frags[name]
+		if !ok {
+			return nil, newError(p.input, src[n].pos, fmt.Sprintf("unknown fragment '%s'", name))
+		}
+
+		for _, v := range path {
+			if v == name {
+				return nil, newError(p.input, src[n].pos,
+					fmt.Sprintf("fragment '%s' spreads itself", name))
+			}
+		}
+
+		if dst, err = p.expand(dst, f.items, frags, append(path, name), f.on); err != nil {
+			return nil, err
+		}
+		i = n
+	}
+
+	return dst, nil
+}
+
+// mergeColumn returns true if the column is already selected, fields
+// spread from several fragments are merged by their response name. A field
+// selected without a type condition applies to all the types.
+func mergeColumn(cols []Column, col Column) bool {
+	for i := range cols {
+		c := &cols[i]
+
+		if c.FieldName != col.FieldName || c.Name != col.Name {
+			continue
+		}
+
+		if len(c.On) == 0 || c.On == col.On {
+			return true
+		}
+
+		if len(col.On) == 0 {
+			c.On = ""
+			return true
+		}
+	}
+	return false
+}
+
+// closingIndex returns the index of the '}' that closes the first '{' at
+// or after index i or -1 if it's not found
+func closingIndex(items []item, i int) int {
+	depth := 0
+
+	for ; i < len(items); i++ {
+		switch items[i]._type {
+		case itemObjOpen:
+			depth++
+		case itemObjClose:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package qcode
+
+import (
+	"testing"
+)
+
+func TestFragments(t *testing.T) {
+	gql := `
+	fragment userFields on users {
+		id
+		email
+		...userName
+	}
+
+	query getUsers {
+		users {
+			...userFields
+			products {
+				... on products {
+					id
+					name
+				}
+			}
+		}
+	}
+
+	fragment userName on users {
+		full_name
+	}`
+
+	op, err := Parse([]byte(gql), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range op.Fields {
+		names = append(names, f.Name)
+	}
+
+	exp := []string{"users", "id", "email", "full_name", "products", "id", "name"}
+
+	if len(names) != len(exp) {
+		t.Fatalf("expected fields %v got %v", exp, names)
+	}
+
+	for i := range exp {
+		if names[i] != exp[i] {
+			t.Fatalf("expected fields %v got %v", exp, names)
+		}
+	}
+
+	if op.Fields[5].ParentID != 4 {
+		t.Fatal("inline fragment fields should be children of 'products'")
+	}
+}
+
+func TestFragmentCycle(t *testing.T) {
+	gql := `
+	query { users { ...a } }
+	fragment a on users { id ...b }
+	fragment b on users { email ...a }`
+
+	if _, err := Parse([]byte(gql), ""); err == nil {
+		t.Fatal("expecting an error for the fragment cycle")
+	}
+}
+
+func TestFragmentUnknown(t *testing.T) {
+	if _, err := Parse([]byte(`query { users { ...userFields } }`), ""); err == nil {
+		t.Fatal("expecting an error for the unknown fragment")
+	}
+}
+
+func TestFragmentMerge(t *testing.T) {
+	gql := `
+	query {
+		products {
+			id
+			...productFields
+			... on products {
+				name
+			}
+		}
+	}
+
+	fragment productFields on products {
+		id
+		name
+		price
+	}`
+
+	qcompile, _ := NewCompiler(Config{})
+
+	qc, err := qcompile.Compile([]byte(gql), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, c := range qc.Selects[0].Cols {
+		names = append(names, c.Name)
+	}
+
+	if len(names) != 3 || names[0] != "id" || names[1] != "name" || names[2] != "price" {
+		t.Fatalf("expected columns [id name price] got %v", names)
+	}
+
+	if cols := qc.Selects[0].Cols; cols[0].On != "" || cols[1].On != "products" {
+		t.Fatalf("expected the type conditions of the fragments got %v", cols)
+	}
+}
@@ -0,0 +1,62 @@
+package qcode
+
+import (
+	"testing"
+)
+
+func TestFunctionArgs(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+	qcompile.AddFunction("search_products", "products")
+
+	qc, err := qcompile.Compile([]byte(`query { search_products(q: $query) { id } }`), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := qc.Selects[0].Args["q"]; !ok {
+		t.Fatal("expected the function argument 'q' to be kept")
+	}
+
+	if _, err := qcompile.Compile([]byte(`query { search_products(q: { id: 1 }) { id } }`), "user"); err == nil {
+		t.Fatal("expected an error for an object as a function argument")
+	}
+
+	qc, err = qcompile.Compile([]byte(`query { products(q: { id: 1 }) { id } }`), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := qc.Selects[0].Args["q"]; ok {
+		t.Fatal("unknown arguments of tables should be ignored")
+	}
+}
+
+func TestFunctionRole(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+	qcompile.AddFunction("search_products", "products")
+
+	err := qcompile.AddRole("user", "products", TRConfig{
+		Query: QueryConfig{
+			Columns: []string{"id", "name"},
+			Filters: []string{"{ price: { gt: 0 } }"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qc, err := qcompile.Compile([]byte(`query { search_products(q: $query) { id } }`), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := qc.Selects[0]
+
+	if s.Where == nil || s.Where.Col != "price" {
+		t.Fatal("expected the filters of the table returned by the function")
+	}
+
+	if _, ok := s.Allowed["price"]; ok || len(s.Allowed) != 2 {
+		t.Fatalf("expected the columns of the table returned by the function got %v", s.Allowed)
+	}
+}
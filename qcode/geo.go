@@ -0,0 +1,115 @@
+package qcode
+
+import (
+	"fmt"
+)
+
+// GeoVal is the geometry used by the spatial operators and the distance
+// order_by. It's either a point [longitude, latitude] or a GeoJSON geometry.
+type GeoVal struct {
+	// Type is ValList for a point and ValStr or ValVar for GeoJSON
+	Type  ValType
+	Val   string
+	Point [2]string
+}
+
+// setGeoExp sets the op and the geometry of a spatial operator, the value
+// is a point, a GeoJSON string or variable or an object with either of
+// these as 'point' or 'geojson' along with a 'distance' for st_dwithin
+func setGeoExp(ex *Exp, name string, node *Node) error {
+	var err error
+
+	switch name {
+	case "st_dwithin":
+		ex.Op = OpStDWithin
+	case "st_intersects":
+		ex.Op = OpStIntersects
+	case "st_contains":
+		ex.Op = OpStContains
+	case "st_within":
+		ex.Op = OpStWithin
+	}
+
+	if node.Type != NodeObj {
+		if ex.Op == OpStDWithin {
+			return fmt.Errorf("[Where] %s expects an object with a point or geojson and a distance", name)
+		}
+		ex.Geo, err = newGeoVal(name, node)
+		return err
+	}
+
+	for _, n := range node.Children {
+		switch n.Name {
+		case "point", "geojson":
+			if ex.Geo != nil {
+				return fmt.Errorf("[Where] %s expects either a point or geojson", name)
+			}
+			if ex.Geo, err = newGeoVal(name, n); err != nil {
+				return err
+			}
+			if (n.Name == "point") != (ex.Geo.Type == ValList) {
+				return fmt.Errorf("[Where] invalid %s value for %s", n.Name, name)
+			}
+
+		case "distance":
+			if ex.Op != OpStDWithin {
+				return fmt.Errorf("[Where] distance is not valid for %s", name)
+			}
+			switch n.Type {
+			case NodeInt:
+				ex.Type = ValInt
+			case NodeFloat:
+				ex.Type = ValFloat
+			case NodeVar:
+				ex.Type = ValVar
+			default:
+				return fmt.Errorf("[Where] distance for %s must be a number or variable", name)
+			}
+			ex.Val = n.Val
+
+		default:
+			return fmt.Errorf("[Where] unexpected argument '%s' for %s", n.Name, name)
+		}
+	}
+
+	if ex.Geo == nil {
+		return fmt.Errorf("[Where] %s expects a point or geojson", name)
+	}
+
+	if ex.Op == OpStDWithin && len(ex.Val) == 0 {
+		return fmt.Errorf("[Where] %s expects a distance", name)
+	}
+
+	return nil
+}
+
+func newGeoVal(name string, node *Node) (*GeoVal, error) {
+	g := &GeoVal{}
+
+	switch node.Type {
+	case NodeList:
+		if len(node.Children) != 2 {
+			return nil, fmt.Errorf("point for %s must be a list of longitude and latitude", name)
+		}
+		for i, n := range node.Children {
+			if n.Type != NodeInt && n.Type != NodeFloat {
+				return nil, fmt.Errorf("point for %s must be a list of numbers", name)
+			}
+			g.Point[i] = n.Val
+		}
+		g.Type = ValList
+
+	case NodeStr:
+		g.Type = ValStr
+		g.Val = node.Val
+
+	case NodeVar:
+		g.Type = ValVar
+		g.Val = node.Val
+
+	default:
+		return nil, fmt.Errorf("value for %s must be a point or geojson", name)
+	}
+
+	return g, nil
+}
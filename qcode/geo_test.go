@@ -0,0 +1,55 @@
+package qcode
+
+import (
+	"testing"
+)
+
+func TestGeo(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	gql := `query {
+		products(
+			where: { location: { st_dwithin: { geojson: $area, distance: $dist } } },
+			order_by: { location: { distance_from: [-122.4, 37.7], order: desc } }) {
+			id
+		}
+	}`
+
+	qc, err := qcompile.Compile([]byte(gql), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ex := qc.Selects[0].Where
+
+	if ex.Op != OpStDWithin || ex.Col != "location" || ex.Type != ValVar || ex.Val != "dist" ||
+		ex.Geo.Type != ValVar || ex.Geo.Val != "area" {
+		t.Fatalf("unexpected where: %s %s %s", ex.Op, ex.Col, ex.Val)
+	}
+
+	ob := qc.Selects[0].OrderBy[0]
+
+	if ob.Col != "location" || ob.Order != OrderDesc || ob.Geo.Type != ValList ||
+		ob.Geo.Point != [2]string{"-122.4", "37.7"} {
+		t.Fatalf("unexpected order_by: %s %v", ob.Col, ob.Geo.Point)
+	}
+}
+
+func TestInvalidGeo(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	tests := []string{
+		`query { products(where: { location: { st_dwithin: [1, 2] } }) { id } }`,
+		`query { products(where: { location: { st_dwithin: { point: [1, 2] } } }) { id } }`,
+		`query { products(where: { location: { st_within: [1, 2, 3] } }) { id } }`,
+		`query { products(where: { location: { st_within: { point: $area } } }) { id } }`,
+		`query { products(where: { location: { st_intersects: { point: [1, 2], distance: 5 } } }) { id } }`,
+		`query { products(order_by: { location: { order: asc } }) { id } }`,
+	}
+
+	for _, v := range tests {
+		if _, err := qcompile.Compile([]byte(v), "user"); err == nil {
+			t.Fatalf("%s: expecting an error", v)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package qcode
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var jsonPathKeyRe = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// parseJSONPath splits a path like 'address.city' into its keys,
+// array elements are selected using their index eg. 'tags.0'
+func parseJSONPath(path string) ([]string, error) {
+	keys := strings.Split(path, ".")
+
+	for _, k := range keys {
+		if !jsonPathKeyRe.MatchString(k) {
+			return nil, fmt.Errorf("invalid json path '%s'", path)
+		}
+	}
+
+	return keys, nil
+}
+
+// setWherePath sets the path in the JSON column when the expression has
+// a sibling 'path' eg. { metadata: { path: "address.city", eq: "Berlin" } }
+func setWherePath(ex *Exp, node *Node) error {
+	var err error
+
+	if node.Parent == nil || len(ex.Col) == 0 {
+		return nil
+	}
+
+	for _, n := range node.Parent.Children {
+		if n.Name != "path" || n.Type != NodeStr {
+			continue
+		}
+
+		if ex.Op == OpJSONPathExists || ex.Op == OpJSONPathMatch {
+			return fmt.Errorf("[Where] path cannot be used with path_exists or path_match")
+		}
+
+		if ex.Path, err = parseJSONPath(n.Val); err != nil {
+			return err
+		}
+		break
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package qcode
+
+import (
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	gql := `query {
+		products(
+			where: { metadata: { path: "address.city", eq: "Berlin" } },
+			order_by: { metadata: { path: "tags.0" } }) {
+			id
+		}
+	}`
+
+	qc, err := qcompile.Compile([]byte(gql), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ex := qc.Selects[0].Where
+
+	if ex.Op != OpEquals || ex.Col != "metadata" || ex.Val != "Berlin" ||
+		len(ex.Path) != 2 || ex.Path[0] != "address" || ex.Path[1] != "city" {
+		t.Fatalf("unexpected where: %s %s %v", ex.Op, ex.Col, ex.Path)
+	}
+
+	ob := qc.Selects[0].OrderBy[0]
+
+	if ob.Col != "metadata" || ob.Order != OrderAsc ||
+		len(ob.Path) != 2 || ob.Path[0] != "tags" || ob.Path[1] != "0" {
+		t.Fatalf("unexpected order_by: %s %v", ob.Col, ob.Path)
+	}
+}
+
+func TestInvalidJSONPath(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	tests := []string{
+		`query { products(where: { metadata: { path: "address.", eq: "Berlin" } }) { id } }`,
+		`query { products(where: { metadata: { path: "a'b", eq: "Berlin" } }) { id } }`,
+		`query { products(where: { metadata: { path: "a", path_exists: "$.a" } }) { id } }`,
+		`query { products(order_by: { metadata: { path: "a", distance_from: [1, 2] } }) { id } }`,
+	}
+
+	for _, v := range tests {
+		if _, err := qcompile.Compile([]byte(v), "user"); err == nil {
+			t.Fatalf("%s: expecting an error", v)
+		}
+	}
+}
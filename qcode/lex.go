@@ -195,7 +195,7 @@ func (l *lexer) acceptRun(valid []byte) {
 // errorf returns an error token and terminates the scan by passing
 // back a nil pointer that will be the next state, terminating l.nextItem.
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.err = fmt.Errorf(format, args...)
+	l.err = newError(l.input, l.start, fmt.Sprintf(format, args...))
 	l.items = append(l.items, item{itemError, l.start, l.pos, l.line})
 	return nil
 }
@@ -263,11 +263,11 @@ func lexRoot(l *lexer) stateFn {
 		l.backup()
 		return lexString
 	case r == '.':
-		if len(l.input) >= 3 {
-			if equals(l.input, 0, 3, spreadToken) {
-				l.emit(itemSpread)
-				return lexRoot
-			}
+		if int(l.start)+len(spreadToken) <= len(l.input) &&
+			equals(l.input, l.start, l.start+Pos(len(spreadToken)), spreadToken) {
+			l.pos = l.start + Pos(len(spreadToken))
+			l.emit(itemSpread)
+			return lexRoot
 		}
 		fallthrough // '.' can start a number.
 	case r == '+' || r == '-' || ('0' <= r && r <= '9'):
@@ -396,11 +396,12 @@ func isAlphaNumeric(r rune) bool {
 }
 
 func equals(b []byte, s Pos, e Pos, val []byte) bool {
+	if int(e-s) != len(val) {
+		return false
+	}
+
 	n := 0
 	for i := s; i < e; i++ {
-		if n >= len(val) {
-			return true
-		}
 		switch {
 		case b[i] >= 'A' && b[i] <= 'Z' && ('a'+(b[i]-'A')) != val[n]:
 			return false
@@ -451,6 +452,8 @@ func (i item) String() string {
 		v = "punctuator"
 	case itemDirective:
 		v = "directive"
+	case itemSpread:
+		v = "spread"
 	case itemVariable:
 		v = "variable"
 	case itemIntVal:
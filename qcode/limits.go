@@ -0,0 +1,116 @@
+package qcode
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gobuffalo/flect"
+)
+
+const (
+	defaultLimit = 20
+	maxEstimate  = int64(1 << 40)
+)
+
+// SetRoleLimits sets the limits on the cost of the queries the role can run
+func (com *Compiler) SetRoleLimits(role string, lim RoleLimits) {
+	if lim == (RoleLimits{}) {
+		delete(com.lim, role)
+		return
+	}
+	com.lim[role] = lim
+}
+
+// checkLimits returns an error if the query exceeds the depth, estimated
+// rows or aggregate function limits of the role. Selects are always
+// after their parent so a single pass is enough. A recursive select counts
+// as nested max_depth times.
+func checkLimits(sel []Select, lim RoleLimits, role string) error {
+	depth := make([]int, len(sel))
+	rows := make([]int64, len(sel))
+
+	var total int64
+	aggs := 0
+
+	for i := range sel {
+		s := &sel[i]
+
+		depth[i], rows[i] = 1, estimateRows(s)
+
+		// recursive selects fetch the rows of every level up to max_depth
+		if s.Recursive {
+			depth[i] = s.MaxDepth
+			rows[i] *= int64(s.MaxDepth)
+		}
+
+		if s.ParentID != -1 {
+			depth[i] += depth[s.ParentID]
+			rows[i] *= rows[s.ParentID]
+		}
+
+		if rows[i] > maxEstimate {
+			rows[i] = maxEstimate
+		}
+		total += rows[i]
+
+		if lim.MaxDepth != 0 && depth[i] > lim.MaxDepth {
+			return fmt.Errorf("query nested too deep at '%s', max depth for role '%s' is %d",
+				s.FieldName, role, lim.MaxDepth)
+		}
+
+		if lim.MaxRows != 0 && total > int64(lim.MaxRows) {
+			return fmt.Errorf("query fetches too many rows at '%s', max rows for role '%s' is %d (lower the limits)",
+				s.FieldName, role, lim.MaxRows)
+		}
+
+		if s.Functions {
+			for _, col := range s.Cols {
+				if isAggregate(col.Name) {
+					aggs++
+				}
+			}
+		}
+
+		if lim.MaxAggregates != 0 && aggs > lim.MaxAggregates {
+			return fmt.Errorf("query has too many aggregate functions, max for role '%s' is %d",
+				role, lim.MaxAggregates)
+		}
+	}
+
+	return nil
+}
+
+// estimateRows returns the maximum rows the select can return
+func estimateRows(s *Select) int64 {
+	if s.Aggregate || hasEqID(s.Where) {
+		return 1
+	}
+
+	if flect.Singularize(s.Name) == s.Name && flect.Pluralize(s.Name) != s.Name {
+		return 1
+	}
+
+	if n, err := strconv.ParseInt(s.Paging.Limit, 10, 64); err == nil && n > 0 {
+		return n
+	}
+
+	return defaultLimit
+}
+
+func hasEqID(ex *Exp) bool {
+	if ex == nil {
+		return false
+	}
+
+	switch ex.Op {
+	case OpEqID:
+		return true
+	case OpAnd:
+		for _, v := range ex.Children {
+			if hasEqID(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package qcode
+
+import (
+	"testing"
+)
+
+func TestRoleLimits(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+	qcompile.SetRoleLimits("anon", RoleLimits{MaxDepth: 2, MaxRows: 1000, MaxAggregates: 1})
+
+	tests := []struct {
+		gql string
+		ok  bool
+	}{
+		{`query { products(limit: 10) { id user { id } } }`, true},
+		{`query { users { products { customers { id } } } }`, false},
+		{`query { users(limit: 50) { products(limit: 50) { id } } }`, false},
+		{`query { product(id: $id) { customers(limit: 100) { id } } }`, true},
+		{`query { products { count_id max_price } }`, false},
+		{`query { comment(id: $id) { comments(recursive: true, max_depth: 1) { id } } }`, true},
+		{`query { comment(id: $id) { comments(recursive: true, max_depth: 2) { id } } }`, false},
+		{`query { comment(id: $id) { comments(recursive: true) { id } } }`, false},
+	}
+
+	for _, v := range tests {
+		_, err := qcompile.Compile([]byte(v.gql), "anon")
+
+		if v.ok && err != nil {
+			t.Fatalf("%s: %s", v.gql, err)
+		}
+
+		if !v.ok && err == nil {
+			t.Fatalf("%s: expecting an error", v.gql)
+		}
+	}
+
+	if _, err := qcompile.Compile([]byte(`query { users { products { customers { id } } } }`), "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rows of a recursive select are counted for every level
+	qcompile.SetRoleLimits("user", RoleLimits{MaxRows: 1000})
+
+	if _, err := qcompile.Compile([]byte(`query { comment(id: $id) { comments(recursive: true, max_depth: 5, limit: 100) { id } } }`), "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := qcompile.Compile([]byte(`query { comment(id: $id) { comments(recursive: true, max_depth: 5, limit: 300) { id } } }`), "user"); err == nil {
+		t.Fatal("expecting an error for the rows of a recursive select")
+	}
+}
@@ -51,14 +51,21 @@ func (o *Operation) Reset() {
 }
 
 type Field struct {
-	ID        int32
-	ParentID  int32
-	Name      string
-	Alias     string
-	Args      []Arg
-	argsA     [5]Arg
-	Children  []int32
-	childrenA [5]int32
+	ID         int32
+	ParentID   int32
+	Name       string
+	Alias      string
+	Args       []Arg
+	argsA      [5]Arg
+	Directives []Directive
+	Children   []int32
+	childrenA  [5]int32
+	On         string
+}
+
+type Directive struct {
+	Name string
+	Args []Arg
 }
 
 type Arg struct {
@@ -81,11 +88,41 @@ func (n *Node) Reset() {
 	*n = zeroNode
 }
 
+// Error is returned when the query fails to parse, Line and Column
+// point to the location of the failure in the query.
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func newError(input []byte, pos Pos, msg string) *Error {
+	e := &Error{Message: msg, Line: 1, Column: 1}
+
+	for i := 0; i < int(pos) && i < len(input); i++ {
+		if input[i] == '\n' {
+			e.Line++
+			e.Column = 1
+		} else {
+			e.Column++
+		}
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
 type Parser struct {
 	input []byte // the string being scanned
 	pos   int
 	items []item
 	err   error
+
+	// conds holds the type conditions of the fields selected by fragments
+	// using the position of the first token of the field
+	conds map[Pos]string
 }
 
 var nodePool = sync.Pool{
@@ -100,8 +137,10 @@ var lexPool = sync.Pool{
 	New: func() interface{} { return new(lexer) },
 }
 
-func Parse(gql []byte) (*Operation, error) {
-	return parseSelectionSet(gql)
+// Parse parses the GraphQL document and returns the operation named
+// opName. When opName is empty the document must have a single operation.
+func Parse(gql []byte, opName string) (*Operation, error) {
+	return parseSelectionSet(gql, opName)
 }
 
 func ParseArgValue(argVal string) (*Node, error) {
@@ -123,7 +162,7 @@ func ParseArgValue(argVal string) (*Node, error) {
 	return op, err
 }
 
-func parseSelectionSet(gql []byte) (*Operation, error) {
+func parseSelectionSet(gql []byte, opName string) (*Operation, error) {
 	var err error
 
 	if len(gql) == 0 {
@@ -143,33 +182,59 @@ func parseSelectionSet(gql []byte) (*Operation, error) {
 		items: l.items,
 	}
 
-	var op *Operation
-
-	if p.peek(itemObjOpen) {
-		p.ignore()
-		op, err = p.parseQueryOp()
-	} else {
-		op, err = p.parseOp()
-	}
-
-	if err != nil {
+	if err = p.expandFragments(); err != nil {
 		return nil, err
 	}
 
-	if p.peek(itemObjClose) {
-		p.ignore()
-	} else {
-		return nil, fmt.Errorf("operation missing closing '}'")
-	}
+	var selected *Operation
+	count := 0
 
-	if !p.peek(itemEOF) {
-		p.ignore()
-		return nil, fmt.Errorf("invalid '%s' found after closing '}'", p.current())
+	for !p.peek(itemEOF) || count == 0 {
+		var op *Operation
+
+		if count != 0 && !p.peek(itemObjOpen, itemQuery, itemMutation, itemSub) {
+			it := p.next()
+			return nil, newError(p.input, it.pos,
+				fmt.Sprintf("invalid '%s' found after closing '}'", p.val(it)))
+		}
+
+		if p.peek(itemObjOpen) {
+			p.ignore()
+			op, err = p.parseQueryOp()
+		} else {
+			op, err = p.parseOp()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if p.peek(itemObjClose) {
+			p.ignore()
+		} else {
+			return nil, p.errorf("operation missing closing '}'")
+		}
+		count++
+
+		if selected == nil && (len(opName) == 0 || op.Name == opName) {
+			selected = op
+		} else {
+			opPool.Put(op)
+		}
 	}
 
 	lexPool.Put(l)
 
-	return op, err
+	if len(opName) == 0 && count > 1 {
+		opPool.Put(selected)
+		return nil, errors.New("operationName is required when the document has more than one operation")
+	}
+
+	if selected == nil {
+		return nil, fmt.Errorf("operation '%s' not found", opName)
+	}
+
+	return selected, nil
 }
 
 func (p *Parser) next() item {
@@ -191,6 +256,19 @@ func (p *Parser) ignore() {
 	p.pos = n
 }
 
+// errorf returns an Error located at the next token, usually the
+// one the parser failed on.
+func (p *Parser) errorf(format string, args ...interface{}) error {
+	n := p.pos + 1
+	if n >= len(p.items) {
+		n = len(p.items) - 1
+	}
+	if n < 0 {
+		return fmt.Errorf(format, args...)
+	}
+	return newError(p.input, p.items[n].pos, fmt.Sprintf(format, args...))
+}
+
 func (p *Parser) current() string {
 	item := p.items[p.pos]
 	return b2s(p.input[item.pos:item.end])
@@ -214,8 +292,7 @@ func (p *Parser) peek(types ...itemType) bool {
 
 func (p *Parser) parseOp() (*Operation, error) {
 	if !p.peek(itemQuery, itemMutation, itemSub) {
-		err := errors.New("expecting a query, mutation or subscription")
-		return nil, err
+		return nil, p.errorf("expecting a query, mutation or subscription")
 	}
 	item := p.next()
 
@@ -296,7 +373,7 @@ func (p *Parser) parseFields(fields []Field) ([]Field, error) {
 
 	for {
 		if len(fields) >= maxFields {
-			return nil, fmt.Errorf("too many fields (max %d)", maxFields)
+			return nil, p.errorf("too many fields (max %d)", maxFields)
 		}
 
 		if p.peek(itemObjClose) {
@@ -311,7 +388,7 @@ func (p *Parser) parseFields(fields []Field) ([]Field, error) {
 		}
 
 		if !p.peek(itemName) {
-			return nil, errors.New("expecting an alias or field name")
+			return nil, p.errorf("expecting an alias or field name")
 		}
 
 		fields = append(fields, Field{ID: int32(len(fields))})
@@ -320,6 +397,10 @@ func (p *Parser) parseFields(fields []Field) ([]Field, error) {
 		f.Args = f.argsA[:0]
 		f.Children = f.childrenA[:0]
 
+		if p.conds != nil {
+			f.On = p.conds[p.items[p.pos+1].pos]
+		}
+
 		// Parse the inside of the the fields () parentheses
 		// in short parse the args like id, where, etc
 		if err := p.parseField(f); err != nil {
@@ -363,7 +444,7 @@ func (p *Parser) parseField(f *Field) error {
 			f.Alias = p.val(v)
 			f.Name = p.vall(p.next())
 		} else {
-			return errors.New("expecting an aliased field name")
+			return p.errorf("expecting an aliased field name")
 		}
 	} else {
 		f.Name = p.vall(v)
@@ -376,13 +457,25 @@ func (p *Parser) parseField(f *Field) error {
 		}
 	}
 
+	for p.peek(itemDirective) {
+		d := Directive{Name: p.val(p.next())}
+
+		if p.peek(itemArgsOpen) {
+			p.ignore()
+			if d.Args, err = p.parseArgs(nil); err != nil {
+				return err
+			}
+		}
+		f.Directives = append(f.Directives, d)
+	}
+
 	return nil
 }
 
 func (p *Parser) parseOpParams(args []Arg) ([]Arg, error) {
 	for {
 		if len(args) >= maxArgs {
-			return nil, fmt.Errorf("too many args (max %d)", maxArgs)
+			return nil, p.errorf("too many args (max %d)", maxArgs)
 		}
 
 		if p.peek(itemArgsClose) {
@@ -400,7 +493,7 @@ func (p *Parser) parseArgs(args []Arg) ([]Arg, error) {
 
 	for {
 		if len(args) >= maxArgs {
-			return nil, fmt.Errorf("too many args (max %d)", maxArgs)
+			return nil, p.errorf("too many args (max %d)", maxArgs)
 		}
 
 		if p.peek(itemArgsClose) {
@@ -409,13 +502,13 @@ func (p *Parser) parseArgs(args []Arg) ([]Arg, error) {
 		}
 
 		if !p.peek(itemName) {
-			return nil, errors.New("expecting an argument name")
+			return nil, p.errorf("expecting an argument name")
 		}
 		args = append(args, Arg{Name: p.val(p.next())})
 		arg := &args[(len(args) - 1)]
 
 		if !p.peek(itemColon) {
-			return nil, errors.New("missing ':' after argument name")
+			return nil, p.errorf("missing ':' after argument name")
 		}
 		p.ignore()
 
@@ -447,14 +540,14 @@ func (p *Parser) parseList() (*Node, error) {
 			ty = node.Type
 		} else {
 			if ty != node.Type {
-				return nil, errors.New("All values in a list must be of the same type")
+				return nil, p.errorf("All values in a list must be of the same type")
 			}
 		}
 		node.Parent = parent
 		nodes = append(nodes, node)
 	}
 	if len(nodes) == 0 {
-		return nil, errors.New("List cannot be empty")
+		return nil, p.errorf("List cannot be empty")
 	}
 
 	parent.Type = NodeList
@@ -476,12 +569,12 @@ func (p *Parser) parseObj() (*Node, error) {
 		}
 
 		if !p.peek(itemName) {
-			return nil, errors.New("expecting an argument name")
+			return nil, p.errorf("expecting an argument name")
 		}
 		nodeName := p.val(p.next())
 
 		if !p.peek(itemColon) {
-			return nil, errors.New("missing ':' after Field argument name")
+			return nil, p.errorf("missing ':' after Field argument name")
 		}
 		p.ignore()
 
@@ -529,7 +622,7 @@ func (p *Parser) parseValue() (*Node, error) {
 	case itemVariable:
 		node.Type = NodeVar
 	default:
-		return nil, fmt.Errorf("expecting a number, string, object, list or variable as an argument value (not %s)", p.val(p.next()))
+		return nil, newError(p.input, item.pos, fmt.Sprintf("expecting a number, string, object, list or variable as an argument value (not %s)", p.val(item)))
 	}
 	node.Val = p.val(item)
 
@@ -181,3 +181,75 @@ func BenchmarkQCompileP(b *testing.B) {
 		}
 	})
 }
+
+func TestInvalidCompileLocation(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+	_, err := qcompile.Compile([]byte(`query {
+	products(id: 1) {
+		id
+		name(
+	}
+}`), "user")
+
+	qerr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expecting a parse error got '%v'", err)
+	}
+
+	if qerr.Line != 5 || qerr.Column != 2 {
+		t.Fatalf("expecting error at line 5, column 2 got line %d, column %d",
+			qerr.Line, qerr.Column)
+	}
+}
+
+func TestCompileMultipleOps(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+	gql := []byte(`
+	query getProducts { products { id } }
+	query getUsers { users { id email } }`)
+
+	qc, err := qcompile.CompileOp(gql, "getUsers", nil, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if qc.Selects[0].Name != "users" {
+		t.Fatalf("expected operation 'getUsers' got root '%s'", qc.Selects[0].Name)
+	}
+
+	if _, err := qcompile.CompileOp(gql, "", nil, "user"); err == nil {
+		t.Fatal("expecting an error as operationName is missing")
+	}
+
+	if _, err := qcompile.CompileOp(gql, "getCustomers", nil, "user"); err == nil {
+		t.Fatal("expecting an error as operation 'getCustomers' does not exist")
+	}
+}
+
+func TestIsSubscription(t *testing.T) {
+	if !IsSubscription(`subscription getUsers { users { id } }`) {
+		t.Fatal("expected a subscription")
+	}
+
+	if IsSubscription(`query { users { id } }`) || IsSubscription(`{ users { id } }`) {
+		t.Fatal("not expecting a subscription")
+	}
+
+	if GetQType(`subscription { users { id } }`) != QTQuery {
+		t.Fatal("subscriptions must compile as queries")
+	}
+}
+
+func TestNamesStartingWithKeywords(t *testing.T) {
+	gql := []byte(`query { search(q: $q, t: true, queryable: $f) { id } }`)
+
+	op, err := Parse(gql, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := op.Fields[0].Args
+	if len(args) != 3 || args[0].Name != "q" || args[2].Name != "queryable" || args[1].Val.Type != NodeBool {
+		t.Fatal("names starting with keywords should be parsed as names")
+	}
+}
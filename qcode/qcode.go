@@ -1,6 +1,7 @@
 package qcode
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
@@ -15,7 +16,9 @@ type QType int
 type Action int
 
 const (
-	maxSelectors = 30
+	maxSelectors     = 30
+	aggregateSuffix  = "_aggregate"
+	connectionSuffix = "_connection"
 )
 
 const (
@@ -28,11 +31,13 @@ const (
 )
 
 type QCode struct {
-	Type      QType
-	ActionVar string
-	Selects   []Select
-	Roots     []int32
-	rootsA    [5]int32
+	Type          QType
+	ActionVar     string
+	Selects       []Select
+	Roots         []int32
+	rootsA        [5]int32
+	DirectiveVars []string
+	vars          map[string]json.RawMessage
 }
 
 type Select struct {
@@ -45,19 +50,36 @@ type Select struct {
 	Where      *Exp
 	OrderBy    []*OrderBy
 	DistinctOn []string
+	GroupBy    []string
 	Paging     Paging
 	Children   []int32
 	Functions  bool
+	Aggregate  bool
+	Recursive  bool
+	MaxDepth   int
+	Connection *Connection
 	Allowed    map[string]struct{}
 	PresetMap  map[string]string
 	PresetList []string
 	SkipRender bool
+	On         string
+	PolyTypes  []PolyType
+}
+
+// PolyType is a table a polymorphic relationship points to along with the
+// filters and columns of the role for it
+type PolyType struct {
+	Table   string
+	Where   *Exp
+	Allowed map[string]struct{}
+	Skip    bool
 }
 
 type Column struct {
 	Table     string
 	Name      string
 	FieldName string
+	On        string
 }
 
 type Exp struct {
@@ -69,6 +91,8 @@ type Exp struct {
 	Val        string
 	ListType   ValType
 	ListVal    []string
+	Path       []string
+	Geo        *GeoVal
 	Children   []*Exp
 	childrenA  [5]*Exp
 	doFree     bool
@@ -83,6 +107,8 @@ func (ex *Exp) Reset() {
 type OrderBy struct {
 	Col   string
 	Order Order
+	Path  []string
+	Geo   *GeoVal
 }
 
 type PagingType int
@@ -133,6 +159,12 @@ const (
 	OpFalse
 	OpNotDistinct
 	OpDistinct
+	OpStDWithin
+	OpStIntersects
+	OpStContains
+	OpStWithin
+	OpJSONPathExists
+	OpJSONPathMatch
 )
 
 type ValType int
@@ -170,8 +202,11 @@ const (
 )
 
 type Compiler struct {
-	tr map[string]map[string]*trval
-	bl map[string]struct{}
+	tr   map[string]map[string]*trval
+	bl   map[string]struct{}
+	lim  map[string]RoleLimits
+	fn   map[string]string
+	poly map[string][]string
 }
 
 var expPool = sync.Pool{
@@ -181,6 +216,9 @@ var expPool = sync.Pool{
 func NewCompiler(c Config) (*Compiler, error) {
 	co := &Compiler{}
 	co.tr = make(map[string]map[string]*trval)
+	co.lim = make(map[string]RoleLimits)
+	co.fn = make(map[string]string)
+	co.poly = make(map[string][]string)
 	co.bl = make(map[string]struct{}, len(c.Blocklist))
 
 	for i := range c.Blocklist {
@@ -256,17 +294,45 @@ func (com *Compiler) AddRole(role, table string, trc TRConfig) error {
 	return nil
 }
 
+// AddPolymorphic adds a polymorphic relationship named name that points to
+// the tables, the config of the role for each table applies to it
+func (com *Compiler) AddPolymorphic(name string, tables []string) {
+	t := make([]string, len(tables))
+
+	for i := range tables {
+		t[i] = strings.ToLower(tables[i])
+	}
+	com.poly[strings.ToLower(name)] = t
+}
+
+// AddFunction adds a function that can be queried like a table, table is
+// the table it returns or empty when it returns its own columns. Only
+// functions take arguments other than the standard ones.
+func (com *Compiler) AddFunction(name, table string) {
+	com.fn[strings.ToLower(name)] = strings.ToLower(table)
+}
+
 func (com *Compiler) Compile(query []byte, role string) (*QCode, error) {
+	return com.CompileOp(query, "", nil, role)
+}
+
+// CompileOp compiles the operation named opName from a GraphQL document
+// with one or more operations. The variables are used to evaluate the
+// @skip and @include directives.
+func (com *Compiler) CompileOp(query []byte, opName string,
+	vars map[string]json.RawMessage, role string) (*QCode, error) {
 	var err error
 
-	qc := QCode{Type: QTQuery}
+	qc := QCode{Type: QTQuery, vars: vars}
 	qc.Roots = qc.rootsA[:0]
 
-	op, err := Parse(query)
+	op, err := Parse(query, opName)
 	if err != nil {
 		return nil, err
 	}
 
+	qc.DirectiveVars = directiveVars(op)
+
 	if err = com.compileQuery(&qc, op, role); err != nil {
 		return nil, err
 	}
@@ -298,7 +364,16 @@ func (com *Compiler) compileQuery(qc *QCode, op *Operation, role string) error {
 	}
 
 	for i := range op.Fields {
-		if op.Fields[i].ParentID == -1 {
+		if op.Fields[i].ParentID != -1 {
+			continue
+		}
+
+		skip, err := skipField(qc, &op.Fields[i])
+		if err != nil {
+			return err
+		}
+
+		if !skip {
 			val := op.Fields[i].ID | (-1 << 16)
 			st.Push(val)
 		}
@@ -327,15 +402,32 @@ func (com *Compiler) compileQuery(qc *QCode, op *Operation, role string) error {
 			parentID = -1
 		}
 
-		trv := com.getRole(role, field.Name)
+		// 'posts_aggregate' selects aggregate functions over the rows of 'posts'
+		name := field.Name
+		aggregate := action == QTQuery && strings.HasSuffix(name, aggregateSuffix)
+
+		if aggregate {
+			name = name[:(len(name) - len(aggregateSuffix))]
+		}
+
+		// 'posts_connection' selects the rows of 'posts' as a Relay connection
+		connection := action == QTQuery && strings.HasSuffix(name, connectionSuffix)
+
+		if connection {
+			name = name[:(len(name) - len(connectionSuffix))]
+		}
+
+		trv := com.getRole(role, name)
 
 		selects = append(selects, Select{
 			ID:        id,
 			ParentID:  parentID,
-			Name:      field.Name,
+			Name:      name,
 			Children:  make([]int32, 0, 5),
 			Allowed:   trv.allowedColumns(action),
 			Functions: true,
+			Aggregate: aggregate,
+			On:        field.On,
 		})
 		s := &selects[(len(selects) - 1)]
 
@@ -356,7 +448,7 @@ func (com *Compiler) compileQuery(qc *QCode, op *Operation, role string) error {
 		if len(field.Alias) != 0 {
 			s.FieldName = field.Alias
 		} else {
-			s.FieldName = s.Name
+			s.FieldName = field.Name
 		}
 
 		err := com.compileArgs(qc, s, field.Args, role)
@@ -364,9 +456,31 @@ func (com *Compiler) compileQuery(qc *QCode, op *Operation, role string) error {
 			return err
 		}
 
+		if s.Aggregate {
+			if err := validateAggregate(s, op.Fields, field); err != nil {
+				return err
+			}
+		}
+
+		if err := validateRecursive(s, selects); err != nil {
+			return err
+		}
+
+		children := field.Children
+
+		if connection {
+			if children, err = compileConnection(s, op.Fields, field); err != nil {
+				return err
+			}
+		}
+
 		// Order is important AddFilters must come after compileArgs
 		com.AddFilters(qc, s, role)
 
+		if tables, ok := com.poly[s.Name]; ok {
+			com.addPolyTypes(s, tables, role)
+		}
+
 		if s.ParentID == -1 {
 			qc.Roots = append(qc.Roots, s.ID)
 		} else {
@@ -377,27 +491,39 @@ func (com *Compiler) compileQuery(qc *QCode, op *Operation, role string) error {
 		s.Cols = make([]Column, 0, len(field.Children))
 		action = QTQuery
 
-		for _, cid := range field.Children {
+		for _, cid := range children {
 			f := op.Fields[cid]
 
 			if _, ok := com.bl[f.Name]; ok {
 				continue
 			}
 
+			skip, err := skipField(qc, &f)
+			if err != nil {
+				return err
+			}
+
+			if skip {
+				continue
+			}
+
 			if len(f.Children) != 0 {
 				val := f.ID | (s.ID << 16)
 				st.Push(val)
 				continue
 			}
 
-			col := Column{Name: f.Name}
+			col := Column{Name: f.Name, On: f.On}
 
 			if len(f.Alias) != 0 {
 				col.FieldName = f.Alias
 			} else {
 				col.FieldName = f.Name
 			}
-			s.Cols = append(s.Cols, col)
+
+			if !mergeColumn(s.Cols, col) {
+				s.Cols = append(s.Cols, col)
+			}
 		}
 
 		id++
@@ -408,14 +534,49 @@ func (com *Compiler) compileQuery(qc *QCode, op *Operation, role string) error {
 	}
 
 	qc.Selects = selects[:id]
+
+	if lim, ok := com.lim[role]; ok {
+		return checkLimits(qc.Selects, lim, role)
+	}
+
 	return nil
 }
 
+// addPolyTypes adds the config of the role for each of the tables of the
+// polymorphic relationship, just like with tables the anon role can only
+// query the ones it has a config for
+func (com *Compiler) addPolyTypes(sel *Select, tables []string, role string) {
+	sel.PolyTypes = make([]PolyType, 0, len(tables))
+	skipped := 0
+
+	for _, t := range tables {
+		pt := PolyType{Table: t}
+
+		if trv, ok := com.roleTable(role, t); ok {
+			var nu bool
+
+			pt.Allowed = trv.allowedColumns(QTQuery)
+			pt.Where, nu = trv.filter(QTQuery)
+			pt.Skip = nu && role == "anon"
+
+		} else {
+			pt.Skip = (role == "anon")
+		}
+
+		if pt.Skip {
+			skipped++
+		}
+		sel.PolyTypes = append(sel.PolyTypes, pt)
+	}
+
+	sel.SkipRender = (skipped == len(tables))
+}
+
 func (com *Compiler) AddFilters(qc *QCode, sel *Select, role string) {
 	var fil *Exp
 	var nu bool
 
-	if trv, ok := com.tr[role][sel.Name]; ok {
+	if trv, ok := com.roleTable(role, sel.Name); ok {
 		fil, nu = trv.filter(qc.Type)
 
 	} else if role == "anon" {
@@ -466,9 +627,18 @@ func (com *Compiler) compileArgs(qc *QCode, sel *Select, args []Arg, role string
 		case "distinct_on", "distinct":
 			err, df = com.compileArgDistinctOn(sel, arg)
 
+		case "group_by", "groupby":
+			err, df = com.compileArgGroupBy(sel, arg)
+
 		case "limit":
 			err, df = com.compileArgLimit(sel, arg)
 
+		case "recursive":
+			err, df = com.compileArgRecursive(sel, arg)
+
+		case "max_depth":
+			err, df = com.compileArgMaxDepth(sel, arg)
+
 		case "offset":
 			err, df = com.compileArgOffset(sel, arg)
 
@@ -483,6 +653,9 @@ func (com *Compiler) compileArgs(qc *QCode, sel *Select, args []Arg, role string
 
 		case "before":
 			err, df = com.compileArgAfterBefore(sel, arg, PtBackward)
+
+		default:
+			err, df = com.compileArgFunc(qc, sel, arg)
 		}
 
 		if !df {
@@ -665,6 +838,32 @@ func (com *Compiler) compileArgSearch(sel *Select, arg *Arg) (error, bool) {
 	return nil, true
 }
 
+// compileArgFunc keeps the other arguments of root fields that are set
+// returning functions since they are passed to the function as parameters
+func (com *Compiler) compileArgFunc(qc *QCode, sel *Select, arg *Arg) (error, bool) {
+	if qc.Type != QTQuery || sel.ParentID != -1 {
+		return nil, false
+	}
+
+	if _, ok := com.fn[sel.Name]; !ok {
+		return nil, false
+	}
+
+	switch arg.Val.Type {
+	case NodeVar, NodeStr, NodeInt, NodeFloat, NodeBool:
+	default:
+		return fmt.Errorf("argument '%s' must be a variable or a value", arg.Name), false
+	}
+
+	if sel.Args == nil {
+		sel.Args = make(map[string]*Node)
+	}
+
+	sel.Args[arg.Name] = arg.Val
+
+	return nil, true
+}
+
 func (com *Compiler) compileArgWhere(sel *Select, arg *Arg, role string) (error, bool) {
 	st := util.NewStack()
 	var err error
@@ -710,6 +909,15 @@ func (com *Compiler) compileArgOrderBy(sel *Select, arg *Arg) (error, bool) {
 			continue
 		}
 
+		if node.Type == NodeObj {
+			ob, err := newOrderByObj(node)
+			if err != nil {
+				return err, false
+			}
+			sel.OrderBy = append(sel.OrderBy, ob)
+			continue
+		}
+
 		if node.Type != NodeStr && node.Type != NodeVar {
 			return fmt.Errorf("expecting a string or variable"), false
 		}
@@ -740,6 +948,52 @@ func (com *Compiler) compileArgOrderBy(sel *Select, arg *Arg) (error, bool) {
 	return nil, false
 }
 
+// newOrderByObj compiles an order_by on the distance of a column from a
+// point or GeoJSON geometry eg. { location: { distance_from: [lng, lat] } }
+// or on a path in a JSON column eg. { metadata: { path: "address.city" } }
+func newOrderByObj(node *Node) (*OrderBy, error) {
+	var err error
+
+	ob := &OrderBy{Order: OrderAsc}
+
+	for _, n := range node.Children {
+		switch n.Name {
+		case "distance_from":
+			if ob.Geo, err = newGeoVal(n.Name, n); err != nil {
+				return nil, err
+			}
+
+		case "path":
+			if n.Type != NodeStr {
+				return nil, fmt.Errorf("path in order_by on '%s' must be a string", node.Name)
+			}
+			if ob.Path, err = parseJSONPath(n.Val); err != nil {
+				return nil, err
+			}
+
+		case "order":
+			switch n.Val {
+			case "asc":
+				ob.Order = OrderAsc
+			case "desc":
+				ob.Order = OrderDesc
+			default:
+				return nil, fmt.Errorf("valid values for order include asc and desc")
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected argument '%s' in order_by on '%s'", n.Name, node.Name)
+		}
+	}
+
+	if (ob.Geo == nil) == (len(ob.Path) == 0) {
+		return nil, fmt.Errorf("order_by on '%s' expects a string, distance_from or path", node.Name)
+	}
+
+	setOrderByColName(ob, node)
+	return ob, nil
+}
+
 func (com *Compiler) compileArgDistinctOn(sel *Select, arg *Arg) (error, bool) {
 	node := arg.Val
 
@@ -763,6 +1017,29 @@ func (com *Compiler) compileArgDistinctOn(sel *Select, arg *Arg) (error, bool) {
 	return nil, false
 }
 
+func (com *Compiler) compileArgGroupBy(sel *Select, arg *Arg) (error, bool) {
+	node := arg.Val
+
+	if node.Type != NodeList && node.Type != NodeStr {
+		return fmt.Errorf("expecting a list of strings or just a string"), false
+	}
+
+	if node.Type == NodeStr {
+		if _, ok := com.bl[node.Val]; !ok {
+			sel.GroupBy = append(sel.GroupBy, node.Val)
+		}
+	}
+
+	for i := range node.Children {
+		if _, ok := com.bl[node.Children[i].Val]; !ok {
+			sel.GroupBy = append(sel.GroupBy, node.Children[i].Val)
+		}
+		FreeNode(node.Children[i], 5)
+	}
+
+	return nil, false
+}
+
 func (com *Compiler) compileArgLimit(sel *Select, arg *Arg) (error, bool) {
 	node := arg.Val
 
@@ -814,13 +1091,28 @@ func (com *Compiler) compileArgAfterBefore(sel *Select, arg *Arg, pt PagingType)
 var zeroTrv = &trval{}
 
 func (com *Compiler) getRole(role, field string) *trval {
-	if trv, ok := com.tr[role][field]; ok {
+	if trv, ok := com.roleTable(role, field); ok {
 		return trv
 	} else {
 		return zeroTrv
 	}
 }
 
+// roleTable returns the config of the role for the table, functions that
+// return a table and have no config of their own use the config of that table
+func (com *Compiler) roleTable(role, name string) (*trval, bool) {
+	if trv, ok := com.tr[role][name]; ok {
+		return trv, true
+	}
+
+	if t := com.fn[name]; len(t) != 0 {
+		trv, ok := com.tr[role][t]
+		return trv, ok
+	}
+
+	return nil, false
+}
+
 func AddFilter(sel *Select, fil *Exp) {
 	if sel.Where != nil {
 		ow := sel.Where
@@ -944,6 +1236,18 @@ func newExp(st *util.Stack, node *Node, usePool bool) (*Exp, error) {
 	case "null_neq", "dis", "distinct":
 		ex.Op = OpDistinct
 		ex.Val = node.Val
+	case "path_exists":
+		ex.Op = OpJSONPathExists
+		ex.Val = node.Val
+	case "path_match":
+		ex.Op = OpJSONPathMatch
+		ex.Val = node.Val
+	case "st_dwithin", "st_intersects", "st_contains", "st_within":
+		if err := setGeoExp(ex, name, node); err != nil {
+			return nil, err
+		}
+		setWhereColName(ex, node)
+		return ex, nil
 	default:
 		pushChildren(st, node.exp, node)
 		return nil, nil // skip node
@@ -967,6 +1271,10 @@ func newExp(st *util.Stack, node *Node, usePool bool) (*Exp, error) {
 			return nil, fmt.Errorf("[Where] valid values include string, int, float, boolean and list: %s", node.Type)
 		}
 		setWhereColName(ex, node)
+
+		if err := setWherePath(ex, node); err != nil {
+			return nil, err
+		}
 	}
 
 	return ex, nil
@@ -1162,6 +1470,18 @@ func (t ExpOp) String() string {
 		v = "op-eq-id"
 	case OpTsQuery:
 		v = "op-ts-query"
+	case OpStDWithin:
+		v = "op-st-dwithin"
+	case OpStIntersects:
+		v = "op-st-intersects"
+	case OpStContains:
+		v = "op-st-contains"
+	case OpStWithin:
+		v = "op-st-within"
+	case OpJSONPathExists:
+		v = "op-json-path-exists"
+	case OpJSONPathMatch:
+		v = "op-json-path-match"
 	}
 	return fmt.Sprintf("<%s>", v)
 }
@@ -0,0 +1,66 @@
+package qcode
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gobuffalo/flect"
+)
+
+const (
+	defaultRecursiveDepth = 10
+	maxRecursiveDepth     = 100
+)
+
+func (com *Compiler) compileArgRecursive(sel *Select, arg *Arg) (error, bool) {
+	node := arg.Val
+
+	if node.Type != NodeBool {
+		return argErr("recursive", "boolean"), false
+	}
+
+	sel.Recursive = (node.Val == "true")
+	return nil, false
+}
+
+func (com *Compiler) compileArgMaxDepth(sel *Select, arg *Arg) (error, bool) {
+	node := arg.Val
+
+	if node.Type != NodeInt {
+		return argErr("max_depth", "number"), false
+	}
+
+	n, err := strconv.Atoi(node.Val)
+	if err != nil || n < 1 || n > maxRecursiveDepth {
+		return fmt.Errorf("max_depth must be between 1 and %d", maxRecursiveDepth), false
+	}
+
+	sel.MaxDepth = n
+	return nil, false
+}
+
+// validateRecursive checks that a recursive select is nested in a select
+// on the same table since it returns the rows under the parent row
+func validateRecursive(sel *Select, selects []Select) error {
+	if !sel.Recursive {
+		if sel.MaxDepth != 0 {
+			return fmt.Errorf("max_depth on '%s' requires recursive: true", sel.FieldName)
+		}
+		return nil
+	}
+
+	if sel.ParentID == -1 ||
+		flect.Pluralize(selects[sel.ParentID].Name) != flect.Pluralize(sel.Name) {
+		return fmt.Errorf("recursive '%s' must be nested in a '%s' selector", sel.FieldName, sel.Name)
+	}
+
+	if sel.Aggregate {
+		return fmt.Errorf("recursive is not supported on aggregate '%s'", sel.FieldName)
+	}
+
+	if sel.MaxDepth == 0 {
+		sel.MaxDepth = defaultRecursiveDepth
+	}
+
+	return nil
+}
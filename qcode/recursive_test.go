@@ -0,0 +1,37 @@
+package qcode
+
+import (
+	"testing"
+)
+
+func TestRecursive(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	qc, err := qcompile.Compile([]byte(`query { comment { id replies: comments(recursive: true) { id } } }`), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := qc.Selects[1]
+
+	if !s.Recursive || s.MaxDepth != defaultRecursiveDepth || s.FieldName != "replies" {
+		t.Fatalf("unexpected recursive select: %s / %d", s.FieldName, s.MaxDepth)
+	}
+}
+
+func TestInvalidRecursive(t *testing.T) {
+	qcompile, _ := NewCompiler(Config{})
+
+	tests := []string{
+		`query { comments(recursive: true) { id } }`,
+		`query { users { id comments(recursive: true) { id } } }`,
+		`query { comment { id comments(max_depth: 5) { id } } }`,
+		`query { comment { id comments(recursive: true, max_depth: 500) { id } } }`,
+	}
+
+	for _, v := range tests {
+		if _, err := qcompile.Compile([]byte(v), "user"); err == nil {
+			t.Fatalf("%s: expecting an error", v)
+		}
+	}
+}
@@ -10,7 +10,7 @@ func GetQType(gql string) QType {
 			switch b {
 			case 'm', 'M':
 				return QTMutation
-			case 'q', 'Q':
+			case 'q', 'Q', 's', 'S':
 				return QTQuery
 			}
 		}
@@ -18,6 +18,22 @@ func GetQType(gql string) QType {
 	return -1
 }
 
+// IsSubscription returns true if the operation in gql is a subscription.
+// Subscriptions are compiled just like queries and GetQType returns QTQuery
+// for them.
+func IsSubscription(gql string) bool {
+	for i := range gql {
+		b := gql[i]
+		if b == '{' {
+			return false
+		}
+		if al(b) {
+			return b == 's' || b == 'S'
+		}
+	}
+	return false
+}
+
 func al(b byte) bool {
 	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
 }
@@ -0,0 +1,167 @@
+package serv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	errCodeAPQNotFound = "PERSISTED_QUERY_NOT_FOUND"
+
+	// apqMaxEntries is the max queries registered by clients in development
+	apqMaxEntries = 10000
+)
+
+var (
+	// the apollo client looks for this exact message to retry with the query
+	errAPQNotFound = &queryErr{
+		code: errCodeAPQNotFound,
+		err:  errors.New("PersistedQueryNotFound"),
+	}
+
+	errAPQHashMismatch = badInputErr(errors.New("provided sha does not match query"))
+	errGetMutation     = badInputErr(errors.New("mutations are not allowed over GET"))
+)
+
+// apqList maps the sha256 hash of a query document to the document, these
+// hashes are sent by clients using automatic persisted queries (APQ). The
+// operations of the allow.list are also added under the hash followed by
+// the operation name since each one is saved separately.
+// https://www.apollographql.com/docs/apollo-server/performance/apq/
+var apqList = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+type gqlReqExtensions struct {
+	PersistedQuery *persistedQuery `json:"persistedQuery"`
+}
+
+type persistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// initPersistedQueries registers the queries in the allow.list under the
+// hash of the document they were sent in
+func initPersistedQueries() {
+	list, err := allowList.Load()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			errlog.Error().Err(err).Msg("failed to load persisted queries")
+		}
+		return
+	}
+
+	apqList.Lock()
+	for _, v := range list {
+		if len(v.Query) != 0 && len(v.Hash) != 0 {
+			apqList.m[v.Hash] = v.Query
+			apqList.m[apqKey(v.Hash, v.Name)] = v.Query
+		}
+	}
+	apqList.Unlock()
+
+	logger.Info().Msgf("Registered %d queries from allow.list as persisted queries", len(list))
+}
+
+func apqHash(query string) string {
+	h := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(h[:])
+}
+
+func apqKey(hash, name string) string {
+	return hash + ":" + strings.ToLower(name)
+}
+
+// getPersistedQuery returns the operation named name saved under the hash
+// or else the document with the hash
+func getPersistedQuery(hash, name string) (string, bool) {
+	apqList.RLock()
+	defer apqList.RUnlock()
+
+	if len(name) != 0 {
+		if q, ok := apqList.m[apqKey(hash, name)]; ok {
+			return q, ok
+		}
+	}
+
+	q, ok := apqList.m[hash]
+	return q, ok
+}
+
+// setPersistedQuery registers a query sent by a client, when the list is
+// full any one of the queries is removed
+func setPersistedQuery(hash, query string) {
+	apqList.Lock()
+	defer apqList.Unlock()
+
+	if _, ok := apqList.m[hash]; !ok && len(apqList.m) >= apqMaxEntries {
+		for k := range apqList.m {
+			delete(apqList.m, k)
+			break
+		}
+	}
+	apqList.m[hash] = query
+}
+
+// resolvePersistedQuery sets the query for requests that only have the hash
+// of a persisted query. In development new queries are registered while in
+// production only queries from the allow.list are allowed.
+func (c *coreContext) resolvePersistedQuery() error {
+	if c.req.Extensions == nil || c.req.Extensions.PersistedQuery == nil {
+		return nil
+	}
+	hash := c.req.Extensions.PersistedQuery.Sha256Hash
+
+	if len(c.req.Query) == 0 {
+		q, ok := getPersistedQuery(hash, c.req.OpName)
+		if !ok {
+			return errAPQNotFound
+		}
+		c.req.Query = q
+		return nil
+	}
+
+	if apqHash(c.req.Query) != hash {
+		return errAPQHashMismatch
+	}
+
+	if conf.Production {
+		if _, ok := getPersistedQuery(hash, c.req.OpName); !ok {
+			return errUnauthorized
+		}
+		return nil
+	}
+
+	setPersistedQuery(hash, c.req.Query)
+
+	return nil
+}
+
+// parseGetReq reads the request from the url query parameters of a GET
+// request, variables and extensions are json encoded
+func parseGetReq(r *http.Request, req *gqlReq) error {
+	q := r.URL.Query()
+
+	req.Query = q.Get("query")
+	req.OpName = q.Get("operationName")
+
+	if v := q.Get("variables"); len(v) != 0 {
+		req.Vars = json.RawMessage(v)
+	}
+
+	if v := q.Get("extensions"); len(v) != 0 {
+		if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package serv
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/dosco/super-graph/allow"
+)
+
+func TestPersistedQuery(t *testing.T) {
+	conf = &config{}
+
+	query := "query getMe { me { id } }"
+	hash := apqHash(query)
+
+	c := &coreContext{req: gqlReq{
+		Extensions: &gqlReqExtensions{&persistedQuery{Version: 1, Sha256Hash: hash}},
+	}}
+
+	if err := c.resolvePersistedQuery(); err != errAPQNotFound {
+		t.Fatalf("expected '%v' got '%v'", errAPQNotFound, err)
+	}
+
+	c.req.Query = "query getMe { me { email } }"
+
+	if err := c.resolvePersistedQuery(); err != errAPQHashMismatch {
+		t.Fatalf("expected '%v' got '%v'", errAPQHashMismatch, err)
+	}
+
+	c.req.Query = query
+
+	if err := c.resolvePersistedQuery(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.req.Query = ""
+
+	if err := c.resolvePersistedQuery(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.req.Query != query {
+		t.Fatalf("expected query '%s' got '%s'", query, c.req.Query)
+	}
+}
+
+func TestPersistedQueryProduction(t *testing.T) {
+	conf = &config{Production: true}
+	defer func() { conf = &config{} }()
+
+	query := "query getProducts { products { id } }"
+
+	c := &coreContext{req: gqlReq{
+		Query:      query,
+		Extensions: &gqlReqExtensions{&persistedQuery{Version: 1, Sha256Hash: apqHash(query)}},
+	}}
+
+	if err := c.resolvePersistedQuery(); !errors.Is(err, errUnauthorized) {
+		t.Fatalf("expected '%v' got '%v'", errUnauthorized, err)
+	}
+
+	if _, ok := getPersistedQuery(apqHash(query), ""); ok {
+		t.Fatal("query should not be registered in production")
+	}
+}
+
+func TestParseGetReq(t *testing.T) {
+	v := url.Values{}
+	v.Set("operationName", "getMe")
+	v.Set("variables", `{"id":1}`)
+	v.Set("extensions", `{"persistedQuery":{"version":1,"sha256Hash":"abc"}}`)
+
+	var req gqlReq
+	r := httptest.NewRequest("GET", "/api/v1/graphql?"+v.Encode(), nil)
+
+	if err := parseGetReq(r, &req); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.OpName != "getMe" || string(req.Vars) != `{"id":1}` {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	if req.Extensions == nil || req.Extensions.PersistedQuery.Sha256Hash != "abc" {
+		t.Fatal("expected persisted query hash 'abc'")
+	}
+}
+
+func TestPersistedQueryAllowList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the document sent by the client has several operations and is
+	// saved to the allow.list as separate queries
+	doc := "query getMe { me { id } }\nquery getProducts { products { id } }"
+	hash := apqHash(doc)
+
+	list := "hash " + hash + "\n\nquery getMe { me { id } }\n\n" +
+		"hash " + hash + "\n\nquery getProducts { products { id } }\n"
+
+	if err := ioutil.WriteFile(path.Join(dir, "allow.list"), []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if allowList, err = allow.New(dir, allow.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { allowList = nil }()
+
+	conf = &config{Production: true}
+	defer func() { conf = &config{} }()
+
+	initPersistedQueries()
+
+	c := &coreContext{req: gqlReq{
+		OpName:     "getProducts",
+		Extensions: &gqlReqExtensions{&persistedQuery{Version: 1, Sha256Hash: hash}},
+	}}
+
+	if err := c.resolvePersistedQuery(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.req.Query != "query getProducts { products { id } }" {
+		t.Fatalf("unexpected query '%s'", c.req.Query)
+	}
+}
+
+func TestPersistedQueryMaxEntries(t *testing.T) {
+	defer func() { apqList.m = make(map[string]string) }()
+
+	for i := 0; i <= apqMaxEntries; i++ {
+		setPersistedQuery(strconv.Itoa(i), "{ me { id } }")
+	}
+
+	if len(apqList.m) > apqMaxEntries {
+		t.Fatalf("expected at most %d queries got %d", apqMaxEntries, len(apqList.m))
+	}
+
+	if _, ok := getPersistedQuery(strconv.Itoa(apqMaxEntries), ""); !ok {
+		t.Fatal("expected the last query to be registered")
+	}
+}
@@ -8,9 +8,10 @@ import (
 	"io"
 
 	"github.com/dosco/super-graph/jsn"
+	"github.com/dosco/super-graph/qcode"
 )
 
-func argMap(ctx context.Context, vars []byte) func(w io.Writer, tag string) (int, error) {
+func argMap(ctx context.Context, vars []byte, qc *qcode.QCode) func(w io.Writer, tag string) (int, error) {
 	return func(w io.Writer, tag string) (int, error) {
 		switch tag {
 		case "user_id_provider":
@@ -49,19 +50,19 @@ func argMap(ctx context.Context, vars []byte) func(w io.Writer, tag string) (int
 			if bytes.EqualFold(v, []byte("null")) {
 				return io.WriteString(w, ``)
 			}
-			v1, err := decrypt(string(fields[0].Value))
+			v1, err := decryptCursor(qc, string(fields[0].Value))
 			if err != nil {
 				return 0, err
 			}
 
-			return w.Write(v1)
+			return w.Write(escQuote(v1))
 		}
 
 		return w.Write(escQuote(fields[0].Value))
 	}
 }
 
-func argList(ctx *coreContext, args [][]byte) ([]interface{}, error) {
+func argList(ctx *coreContext, args [][]byte, qc *qcode.QCode) ([]interface{}, error) {
 	vars := make([]interface{}, len(args))
 
 	var fields map[string]json.RawMessage
@@ -101,11 +102,13 @@ func argList(ctx *coreContext, args [][]byte) ([]interface{}, error) {
 
 		case bytes.Equal(av, []byte("cursor")):
 			if v, ok := fields["cursor"]; ok && v[0] == '"' {
-				v1, err := decrypt(string(v[1 : len(v)-1]))
+				v1, err := decryptCursor(qc, string(v[1:len(v)-1]))
 				if err != nil {
 					return nil, err
 				}
-				vars[i] = v1
+				vars[i] = string(v1)
+			} else if ok && bytes.Equal(v, []byte("null")) {
+				vars[i] = ""
 			} else {
 				return nil, argErr("cursor")
 			}
@@ -155,12 +158,12 @@ func escQuote(b []byte) []byte {
 		}
 	}
 	l := len(b)
-	if s < (l - 1) {
+	if s < l {
 		buf.Write(b[s:l])
 	}
 	return buf.Bytes()
 }
 
 func argErr(name string) error {
-	return fmt.Errorf("query requires variable '%s' to be set", name)
+	return badInputErr(fmt.Errorf("query requires variable '%s' to be set", name))
 }
@@ -0,0 +1,149 @@
+package serv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dosco/super-graph/allow"
+	"github.com/jackc/pgx/v4"
+)
+
+const maxBatchSize = 20
+
+var errBatchRollback = &queryErr{
+	code: errCodeRolledBack,
+	err:  errors.New("batch rolled back since another operation in it failed"),
+}
+
+// isBatch returns true if the request body is a json array of
+// operations as sent by the Apollo and Relay clients when batching
+func isBatch(b []byte) bool {
+	for i := range b {
+		switch b[i] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '[':
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+func apiV1Batch(w http.ResponseWriter, r *http.Request, b []byte) {
+	var reqs []gqlReq
+
+	if err := json.Unmarshal(b, &reqs); err != nil {
+		errlog.Error().Err(err).Msg("failed to decode json request body")
+		errorResp(w, badInputErr(err))
+		return
+	}
+
+	if len(reqs) == 0 || len(reqs) > maxBatchSize {
+		err := fmt.Errorf("batch must have between 1 and %d operations", maxBatchSize)
+		errorResp(w, badInputErr(err))
+		return
+	}
+
+	for i := range reqs {
+		// persisted queries are resolved first so they're limited by
+		// their name, a failure is returned along with the response
+		c := &coreContext{Context: r.Context(), req: reqs[i]}
+		if err := c.resolvePersistedQuery(); err == nil {
+			reqs[i] = c.req
+		}
+
+		if err := checkRateLimit(r, allow.QueryName(reqs[i].operation())); err != nil {
+			rateLimitResp(w, err)
+			return
+		}
+	}
+
+	res, err := execBatch(r, reqs)
+	if err != nil {
+		errlog.Error().Err(err).Msg("batch failed")
+		errorResp(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(res) //nolint: errcheck
+}
+
+// execBatch runs the operations in order using the same auth context
+// and returns their responses in the same order. With batch_transaction
+// enabled all operations run in a single transaction that is rolled back
+// if any of them fail.
+func execBatch(r *http.Request, reqs []gqlReq) ([]gqlResp, error) {
+	var tx pgx.Tx
+	var err error
+
+	res := make([]gqlResp, len(reqs))
+	failed := -1
+
+	if conf.BatchTx {
+		if tx, err = db.Begin(r.Context()); err != nil {
+			return nil, err
+		}
+		defer tx.Rollback(r.Context()) //nolint: errcheck
+	}
+
+	for i := range reqs {
+		if failed != -1 {
+			break
+		}
+
+		c := &coreContext{Context: r.Context(), req: reqs[i], tx: tx}
+		c.initReq(r)
+
+		if err := c.resolvePersistedQuery(); err != nil {
+			res[i] = gqlResp{Errors: gqlErrors(err)}
+			continue
+		}
+
+		if strings.EqualFold(c.req.OpName, introspectionQuery) {
+			var ir introResp
+			ir.Data.Schema = buildIntrospection(c.introspectionRole())
+
+			if res[i].Data, err = json.Marshal(ir.Data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		data, err := c.execQuery()
+		if err != nil {
+			errlog.Error().Err(err).Msg(c.req.Query)
+			res[i] = gqlResp{Errors: gqlErrors(err)}
+
+			if tx != nil {
+				failed = i
+			}
+			continue
+		}
+
+		c.res.Data = json.RawMessage(data)
+		res[i] = c.res
+	}
+
+	if tx == nil {
+		return res, nil
+	}
+
+	if failed != -1 {
+		for i := range res {
+			if i != failed {
+				res[i] = gqlResp{Errors: gqlErrors(errBatchRollback)}
+			}
+		}
+		return res, nil
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,87 @@
+package serv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsBatch(t *testing.T) {
+	if !isBatch([]byte(" \n[{\"query\": \"{ me { id } }\"}]")) {
+		t.Fatal("expected a batch")
+	}
+
+	if isBatch([]byte("{\"query\": \"{ me { id } }\"}")) {
+		t.Fatal("expected a single operation")
+	}
+}
+
+func TestBatchResponseOrder(t *testing.T) {
+	initIntrospectionTest(t)
+
+	b := []byte(`[
+		{"operationName": "IntrospectionQuery", "query": "query IntrospectionQuery { __schema { types { name } } }"},
+		{"operationName": "IntrospectionQuery", "query": "query IntrospectionQuery { __schema { types { name } } }"}
+	]`)
+
+	w := httptest.NewRecorder()
+	apiV1Batch(w, httptest.NewRequest("POST", "/api/v1/graphql", nil), b)
+
+	var res []gqlResp
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 2 {
+		t.Fatalf("expected 2 responses got %d", len(res))
+	}
+
+	for _, v := range res {
+		if len(v.Errors) != 0 || !strings.Contains(string(v.Data), "__schema") {
+			t.Fatalf("expected introspection data got '%s'", v.Data)
+		}
+	}
+}
+
+func TestBatchEmpty(t *testing.T) {
+	w := httptest.NewRecorder()
+	apiV1Batch(w, httptest.NewRequest("POST", "/api/v1/graphql", nil), []byte(`[]`))
+
+	var res gqlResp
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Errors) != 1 || res.Errors[0].Extensions.Code != errCodeBadInput {
+		t.Fatalf("expected a '%s' error got '%s'", errCodeBadInput, w.Body.String())
+	}
+}
+
+func TestBatchPersistedQueryRateLimit(t *testing.T) {
+	anon := &configRole{Name: "anon"}
+	anon.RateLimit.Queries = []configQueryRateLimit{
+		{Name: "getProducts", Rate: 0.1, Burst: 1},
+	}
+
+	conf = &config{roles: map[string]*configRole{"anon": anon}}
+	rateLimiter = newMemRateStore()
+
+	defer func() {
+		conf = &config{}
+		rateLimiter = newMemRateStore()
+	}()
+
+	hash := apqHash("query getProducts { products { id } }")
+	setPersistedQuery(hash, "query getProducts { products { id } }")
+
+	req := `{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}}`
+
+	w := httptest.NewRecorder()
+	apiV1Batch(w, httptest.NewRequest("POST", "/api/v1/graphql", nil), []byte(`[`+req+`, `+req+`]`))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,400 @@
+package serv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dosco/super-graph/qcode"
+)
+
+const (
+	// cacheChannel is the channel the triggers notify with the name
+	// of the table that changed
+	cacheChannel       = "super_graph_cache"
+	cacheListenRetry   = 5 * time.Second
+	defaultCacheMaxLen = 1000
+)
+
+// respCache holds the responses of anonymous queries, nil when no
+// cache ttl is configured
+var respCache *responseCache
+
+type cacheEntry struct {
+	data    []byte
+	tables  []string
+	expires time.Time
+}
+
+// responseCache is a cache of the responses of queries that is invalidated
+// using the tables each response was fetched from
+type responseCache struct {
+	sync.Mutex
+	entries map[string]*cacheEntry
+	tables  map[string]map[string]struct{}
+	ttl     time.Duration
+	ttls    map[string]time.Duration
+	maxLen  int
+	now     func() time.Time
+
+	// gen is changed on every invalidation so responses fetched before
+	// it are not cached
+	gen uint64
+}
+
+func newResponseCache(c *config) *responseCache {
+	rc := &responseCache{
+		entries: make(map[string]*cacheEntry),
+		tables:  make(map[string]map[string]struct{}),
+		ttl:     c.Cache.TTL,
+		ttls:    make(map[string]time.Duration),
+		maxLen:  c.Cache.MaxEntries,
+		now:     time.Now,
+	}
+
+	if rc.maxLen == 0 {
+		rc.maxLen = defaultCacheMaxLen
+	}
+
+	for _, t := range c.Tables {
+		if t.CacheTTL != 0 {
+			rc.ttls[t.Name] = t.CacheTTL
+		}
+	}
+
+	return rc
+}
+
+func initResponseCache(c *config) *responseCache {
+	rc := newResponseCache(c)
+
+	if rc.ttl == 0 && len(rc.ttls) == 0 {
+		return nil
+	}
+
+	go rc.listen()
+
+	return rc
+}
+
+// cacheKey returns the key of the response to the request, the hash of the
+// query is combined with a hash of the exact query, operation and variables
+// since the values of the variables and the case of strings are not part of it
+func (c *coreContext) cacheKey() string {
+	h := xxhash.New()
+
+	io.WriteString(h, c.req.Query)  //nolint: errcheck
+	io.WriteString(h, c.req.OpName) //nolint: errcheck
+	h.Write(c.req.Vars)             //nolint: errcheck
+
+	return fmt.Sprintf("%s:%x", gqlHash(c.req.Query, c.req.Vars, c.req.role), h.Sum64())
+}
+
+// useCache returns true if the response can be shared, only anonymous
+// queries outside of a batch are cached. Subscriptions poll for changes
+// so they always fetch from the database.
+func (c *coreContext) useCache() bool {
+	if respCache == nil || c.tx != nil || c.req.role != "anon" {
+		return false
+	}
+
+	if qcode.IsSubscription(c.req.operation()) {
+		return false
+	}
+
+	if v := c.Value(userIDKey); v != nil {
+		return false
+	}
+
+	// a role set by the auth handler can change what's returned
+	if v := c.Value(userRoleKey); v != nil {
+		return false
+	}
+
+	return qcode.GetQType(c.req.operation()) == qcode.QTQuery
+}
+
+// cacheTables returns the database tables the selects of the query are
+// fetched from qualified with their schema like the triggers notify with
+func cacheTables(qc *qcode.QCode) []string {
+	tables := make([]string, 0, len(qc.Selects))
+	seen := make(map[string]struct{}, len(qc.Selects))
+
+	for _, sel := range qc.Selects {
+		name := defaultSchema() + "." + sel.Name
+
+		if schema != nil {
+			if ti, err := schema.GetTable(sel.Name); err == nil && len(ti.Table) != 0 {
+				name = ti.Table
+
+				if len(ti.Schema) != 0 {
+					name = ti.Schema + "." + name
+				} else {
+					name = defaultSchema() + "." + name
+				}
+			}
+		}
+
+		if _, ok := seen[name]; !ok {
+			tables = append(tables, name)
+			seen[name] = struct{}{}
+		}
+	}
+
+	return tables
+}
+
+// defaultSchema returns the schema of the tables on the search path
+func defaultSchema() string {
+	if conf != nil {
+		s := strings.TrimSpace(strings.Split(conf.DB.Schema, ",")[0])
+		if s = strings.Trim(s, `"`); len(s) != 0 {
+			return s
+		}
+	}
+	return "public"
+}
+
+// cacheTriggerTable returns the table in the database of a configured table,
+// tables with the prefix of one of the schemas are found in that schema
+func cacheTriggerTable(name string) string {
+	for _, s := range conf.DB.Schemas {
+		p := s.Prefix
+		if len(p) == 0 {
+			p = s.Name + "_"
+		}
+
+		if strings.HasPrefix(name, p) {
+			return s.Name + "." + name[len(p):]
+		}
+	}
+	return name
+}
+
+// entryTTL returns the shortest ttl of the tables selected, responses
+// with a table that has no ttl are not cached
+func (rc *responseCache) entryTTL(qc *qcode.QCode) time.Duration {
+	var ttl time.Duration
+
+	for _, sel := range qc.Selects {
+		t, ok := rc.ttls[sel.Name]
+		if !ok {
+			t = rc.ttl
+		}
+
+		if t == 0 {
+			return 0
+		}
+
+		if ttl == 0 || t < ttl {
+			ttl = t
+		}
+	}
+
+	return ttl
+}
+
+func (rc *responseCache) get(key string) ([]byte, bool) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	e, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !rc.now().Before(e.expires) {
+		rc.remove(key, e)
+		return nil, false
+	}
+
+	return e.data, true
+}
+
+// generation returns the current generation which is to be passed to set
+// along with the response fetched after it
+func (rc *responseCache) generation() uint64 {
+	rc.Lock()
+	defer rc.Unlock()
+
+	return rc.gen
+}
+
+func (rc *responseCache) set(key string, data []byte, qc *qcode.QCode, gen uint64) {
+	ttl := rc.entryTTL(qc)
+	if ttl == 0 {
+		return
+	}
+
+	e := &cacheEntry{data: data, tables: cacheTables(qc)}
+
+	rc.Lock()
+	defer rc.Unlock()
+
+	if gen != rc.gen {
+		return
+	}
+
+	now := rc.now()
+	e.expires = now.Add(ttl)
+
+	if old, ok := rc.entries[key]; ok {
+		rc.remove(key, old)
+	}
+
+	if len(rc.entries) >= rc.maxLen {
+		rc.evict(now)
+	}
+
+	rc.entries[key] = e
+
+	for _, t := range e.tables {
+		keys, ok := rc.tables[t]
+		if !ok {
+			keys = make(map[string]struct{})
+			rc.tables[t] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// invalidate removes the responses fetched from any of the tables
+func (rc *responseCache) invalidate(tables ...string) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	rc.gen++
+
+	for _, t := range tables {
+		for key := range rc.tables[t] {
+			if e, ok := rc.entries[key]; ok {
+				rc.remove(key, e)
+			}
+		}
+		delete(rc.tables, t)
+	}
+}
+
+func (rc *responseCache) clear() {
+	rc.Lock()
+	defer rc.Unlock()
+
+	rc.gen++
+
+	rc.entries = make(map[string]*cacheEntry)
+	rc.tables = make(map[string]map[string]struct{})
+}
+
+// evict removes the expired responses and if the cache is still full
+// then any one of the responses
+func (rc *responseCache) evict(now time.Time) {
+	for key, e := range rc.entries {
+		if !now.Before(e.expires) {
+			rc.remove(key, e)
+		}
+	}
+
+	for key, e := range rc.entries {
+		if len(rc.entries) < rc.maxLen {
+			break
+		}
+		rc.remove(key, e)
+	}
+}
+
+func (rc *responseCache) remove(key string, e *cacheEntry) {
+	delete(rc.entries, key)
+
+	for _, t := range e.tables {
+		if keys, ok := rc.tables[t]; ok {
+			delete(keys, key)
+		}
+	}
+}
+
+// listen invalidates the responses using the names of the tables the
+// triggers send when they change, since notifications can be missed
+// while not listening the cache is cleared before listening again
+func (rc *responseCache) listen() {
+	for {
+		if err := rc.waitForChanges(); err != nil {
+			errlog.Error().Err(err).Msg("failed to listen for changes to invalidate the cache")
+		}
+		rc.clear()
+		time.Sleep(cacheListenRetry)
+	}
+}
+
+func (rc *responseCache) waitForChanges() error {
+	ctx := context.Background()
+
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the connection is closed so it's not returned to the pool
+	// while still listening
+	defer conn.Release()
+	defer conn.Conn().Close(ctx) //nolint: errcheck
+
+	if _, err := conn.Exec(ctx, `LISTEN "`+cacheChannel+`"`); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		rc.invalidate(n.Payload)
+	}
+}
+
+var cacheTriggerFunc = `CREATE OR REPLACE FUNCTION super_graph_cache_notify() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('` + cacheChannel + `', TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+`
+
+// cacheTriggersSQL returns the migration that adds the triggers which
+// notify Super Graph when the tables change
+func cacheTriggersSQL(tables []string) string {
+	var sb strings.Builder
+
+	sb.WriteString(cacheTriggerFunc)
+
+	for _, t := range tables {
+		fmt.Fprintf(&sb, `
+DROP TRIGGER IF EXISTS super_graph_cache ON %[1]s;
+CREATE TRIGGER super_graph_cache AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON %[1]s
+  FOR EACH STATEMENT EXECUTE PROCEDURE super_graph_cache_notify();
+`, quotedIdent(t))
+	}
+
+	sb.WriteString("\n---- create above / drop below ----\n\n")
+
+	for _, t := range tables {
+		fmt.Fprintf(&sb, "DROP TRIGGER IF EXISTS super_graph_cache ON %s;\n", quotedIdent(t))
+	}
+
+	sb.WriteString("DROP FUNCTION IF EXISTS super_graph_cache_notify();\n")
+
+	return sb.String()
+}
+
+// quotedIdent quotes the name of the table and its schema if it has one
+func quotedIdent(name string) string {
+	parts := strings.Split(name, ".")
+
+	for i := range parts {
+		parts[i] = `"` + strings.ReplaceAll(parts[i], `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
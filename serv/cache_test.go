@@ -0,0 +1,174 @@
+package serv
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dosco/super-graph/psql"
+	"github.com/dosco/super-graph/qcode"
+)
+
+func TestResponseCache(t *testing.T) {
+	now := time.Now()
+
+	c := &config{}
+	c.Cache.TTL = time.Minute
+	c.Tables = []configTable{{Name: "products", CacheTTL: time.Second}}
+
+	rc := newResponseCache(c)
+	rc.now = func() time.Time { return now }
+
+	products := &qcode.QCode{Selects: []qcode.Select{{Name: "products"}, {Name: "users"}}}
+	users := &qcode.QCode{Selects: []qcode.Select{{Name: "users"}}}
+
+	rc.set("a", []byte(`{"products": []}`), products, rc.generation())
+	rc.set("b", []byte(`{"users": []}`), users, rc.generation())
+
+	if v, ok := rc.get("a"); !ok || string(v) != `{"products": []}` {
+		t.Fatalf("expected a cached response got '%s'", v)
+	}
+
+	now = now.Add(time.Second)
+
+	if _, ok := rc.get("a"); ok {
+		t.Fatal("expected the shortest ttl of the tables to be used")
+	}
+
+	if _, ok := rc.get("b"); !ok {
+		t.Fatal("expected the default ttl to be used")
+	}
+
+	rc.set("a", []byte(`{"products": []}`), products, rc.generation())
+	rc.invalidate("public.users")
+
+	if _, ok := rc.get("a"); ok {
+		t.Fatal("expected the response to be evicted with its table")
+	}
+
+	if _, ok := rc.get("b"); ok {
+		t.Fatal("expected the response to be evicted with its table")
+	}
+
+	gen := rc.generation()
+	rc.invalidate("public.products")
+	rc.set("b", []byte(`{"users": []}`), users, gen)
+
+	if _, ok := rc.get("b"); ok {
+		t.Fatal("responses fetched before an invalidation should not be cached")
+	}
+}
+
+func TestResponseCacheMaxEntries(t *testing.T) {
+	c := &config{}
+	c.Cache.TTL = time.Minute
+	c.Cache.MaxEntries = 2
+
+	rc := newResponseCache(c)
+	qc := &qcode.QCode{Selects: []qcode.Select{{Name: "products"}}}
+
+	for _, key := range []string{"a", "b", "c"} {
+		rc.set(key, []byte(`{}`), qc, rc.generation())
+	}
+
+	if len(rc.entries) != 2 || len(rc.tables["public.products"]) != 2 {
+		t.Fatalf("expected 2 cached responses found %d", len(rc.entries))
+	}
+
+	if _, ok := rc.get("c"); !ok {
+		t.Fatal("expected the last response to be cached")
+	}
+}
+
+func TestCacheTables(t *testing.T) {
+	di := &psql.DBInfo{
+		Tables: []psql.DBTable{
+			{Name: "invoices", Key: "invoices", Type: "table", Table: "invoices"},
+			{Name: "billing_invoices", Key: "billing_invoices", Type: "table", Schema: "billing", Table: "invoices"},
+		},
+		Columns: [][]psql.DBColumn{
+			{{ID: 1, Name: "id", Key: "id", Type: "bigint", PrimaryKey: true, UniqueKey: true}},
+			{{ID: 1, Name: "id", Key: "id", Type: "bigint", PrimaryKey: true, UniqueKey: true}},
+		},
+	}
+
+	var err error
+
+	if schema, err = psql.NewDBSchema(di, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { schema = nil }()
+
+	conf = &config{}
+	conf.DB.Schemas = []configSchema{{Name: "billing"}}
+	defer func() { conf = &config{} }()
+
+	qc := &qcode.QCode{Selects: []qcode.Select{{Name: "invoices"}, {Name: "billing_invoices"}}}
+	tables := cacheTables(qc)
+
+	if len(tables) != 2 || tables[0] != "public.invoices" || tables[1] != "billing.invoices" {
+		t.Fatalf("expected tables qualified with their schema got %v", tables)
+	}
+
+	if v := cacheTriggerTable("billing_invoices"); v != "billing.invoices" {
+		t.Fatalf("expected 'billing.invoices' got '%s'", v)
+	}
+
+	if v := cacheTriggerTable("invoices"); v != "invoices" {
+		t.Fatalf("expected 'invoices' got '%s'", v)
+	}
+}
+
+func TestUseCache(t *testing.T) {
+	respCache = newResponseCache(&config{})
+	defer func() { respCache = nil }()
+
+	c := &coreContext{Context: context.Background()}
+	c.req.Query = `query { products { id } }`
+	c.req.role = "anon"
+
+	if !c.useCache() {
+		t.Fatal("expected anonymous queries to be cached")
+	}
+
+	c.req.Query = `mutation { products(insert: $data) { id } }`
+
+	if c.useCache() {
+		t.Fatal("expected mutations not to be cached")
+	}
+
+	c.req.Query = `subscription { products { id } }`
+
+	if c.useCache() {
+		t.Fatal("expected subscriptions not to be cached")
+	}
+
+	c.req.Query = `query { products { id } }`
+	c.Context = context.WithValue(context.Background(), userIDKey, "1")
+
+	if c.useCache() {
+		t.Fatal("expected queries with a user not to be cached")
+	}
+
+	c.Context = context.WithValue(context.Background(), userRoleKey, "anon")
+
+	if c.useCache() {
+		t.Fatal("expected queries with a user role not to be cached")
+	}
+}
+
+func TestCacheTriggersSQL(t *testing.T) {
+	sql := cacheTriggersSQL([]string{"products", "billing.invoices"})
+
+	for _, v := range []string{
+		`PERFORM pg_notify('super_graph_cache', TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME);`,
+		`AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON "products"`,
+		`AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON "billing"."invoices"`,
+		`DROP TRIGGER IF EXISTS super_graph_cache ON "billing"."invoices";`,
+	} {
+		if !strings.Contains(sql, v) {
+			t.Fatalf("expected '%s' in:\n%s", v, sql)
+		}
+	}
+}
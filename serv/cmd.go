@@ -115,6 +115,13 @@ e.g. db:migrate -+1
 		Run:   cmdDBNew,
 	})
 
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "db:cache_triggers [TABLES...]",
+		Short: "Generate a migration with the cache invalidation triggers",
+		Long:  "Generate a new migration that adds the triggers used to invalidate the response cache to the tables listed or else the tables with a cache_ttl",
+		Run:   cmdDBCacheTriggers,
+	})
+
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "db:setup",
 		Short: "Setup database",
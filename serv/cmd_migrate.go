@@ -108,7 +108,45 @@ func cmdDBNew(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	mname := fmt.Sprintf("%d_%s.sql", len(m), name)
+	writeMigration(len(m), name, newMigrationText)
+}
+
+// cmdDBCacheTriggers generates a migration with the triggers used to
+// invalidate the response cache for the tables listed or else the
+// tables with a cache_ttl
+func cmdDBCacheTriggers(cmd *cobra.Command, args []string) {
+	initConfOnce()
+	tables := args
+
+	if len(tables) == 0 {
+		for _, t := range conf.Tables {
+			switch {
+			case t.CacheTTL == 0:
+				continue
+			case len(t.Table) != 0:
+				tables = append(tables, cacheTriggerTable(t.Table))
+			default:
+				tables = append(tables, cacheTriggerTable(t.Name))
+			}
+		}
+	}
+
+	if len(tables) == 0 {
+		cmd.Help() //nolint: errcheck
+		os.Exit(1)
+	}
+
+	m, err := migrate.FindMigrations(conf.MigrationsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading migrations:\n  %v\n", err)
+		os.Exit(1)
+	}
+
+	writeMigration(len(m), "cache_triggers", cacheTriggersSQL(tables))
+}
+
+func writeMigration(n int, name, text string) {
+	mname := fmt.Sprintf("%d_%s.sql", n, name)
 
 	// Write new migration
 	mpath := filepath.Join(conf.MigrationsPath, mname)
@@ -119,7 +157,7 @@ func cmdDBNew(cmd *cobra.Command, args []string) {
 	}
 	defer mfile.Close()
 
-	_, err = mfile.WriteString(newMigrationText)
+	_, err = mfile.WriteString(text)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
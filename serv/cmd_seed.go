@@ -85,7 +85,7 @@ func graphQLFunc(query string, data interface{}, opt map[string]string) map[stri
 		role = "user"
 	}
 
-	stmts, err := buildRoleStmt([]byte(query), vars, role)
+	stmts, err := buildRoleStmt([]byte(query), "", vars, role)
 	if err != nil {
 		errlog.Fatal().Err(err).Msg("graphql query failed")
 	}
@@ -94,7 +94,7 @@ func graphQLFunc(query string, data interface{}, opt map[string]string) map[stri
 	buf := &bytes.Buffer{}
 
 	t := fasttemplate.New(st.sql, openVar, closeVar)
-	_, err = t.ExecuteFunc(buf, argMap(c, vars))
+	_, err = t.ExecuteFunc(buf, argMap(c, vars, nil))
 
 	if err != nil {
 		errlog.Fatal().Err(err).Send()
@@ -20,11 +20,14 @@ func cmdServ(cmd *cobra.Command, args []string) {
 	}
 
 	if conf != nil && db != nil {
+		replicas = initReplicas(conf)
+		respCache = initResponseCache(conf)
 		initCrypto()
 		initCompiler()
 		initResolvers()
 		initAllowList(confPath)
 		initPreparedList(confPath)
+		initPersistedQueries()
 	}
 
 	startHTTP()
@@ -12,6 +12,8 @@ import (
 	"github.com/spf13/viper"
 )
 
+const defaultSubsPollDuration = 5 * time.Second
+
 type config struct {
 	*viper.Viper
 
@@ -34,6 +36,9 @@ type config struct {
 	AllowedOrigins []string `mapstructure:"cors_allowed_origins"`
 	DebugCORS      bool     `mapstructure:"cors_debug"`
 
+	SubsPollDuration time.Duration `mapstructure:"subs_poll_duration"`
+	BatchTx          bool          `mapstructure:"batch_transaction"`
+
 	Inflections map[string]string
 
 	Auth  configAuth
@@ -47,11 +52,15 @@ type config struct {
 		User        string
 		Password    string
 		Schema      string
+		Schemas     []configSchema
 		PoolSize    int32         `mapstructure:"pool_size"`
 		MaxRetries  int           `mapstructure:"max_retries"`
 		SetUserID   bool          `mapstructure:"set_user_id"`
 		PingTimeout time.Duration `mapstructure:"ping_timeout"`
 
+		Replicas       []configReplica
+		ReadYourWrites time.Duration `mapstructure:"read_your_writes"`
+
 		Vars      map[string]string `mapstructure:"variables"`
 		Blocklist []string
 
@@ -62,12 +71,30 @@ type config struct {
 
 	Tables []configTable
 
+	// Cache holds the responses of anonymous queries for the ttl or the
+	// cache_ttl of the tables they select from
+	Cache struct {
+		TTL        time.Duration
+		MaxEntries int `mapstructure:"max_entries"`
+	}
+
 	RolesQuery  string `mapstructure:"roles_query"`
 	Roles       []configRole
 	roles       map[string]*configRole
 	abacEnabled bool
 }
 
+// configReplica is a read replica of the database, the connection
+// settings that are not set are the same as the primary database
+type configReplica struct {
+	Host     string
+	Port     uint16
+	DBName   string
+	User     string
+	Password string
+	PoolSize int32 `mapstructure:"pool_size"`
+}
+
 type configAuth struct {
 	Name          string
 	Type          string
@@ -106,12 +133,64 @@ type configColumn struct {
 	ForeignKey string `mapstructure:"related_to"`
 }
 
+// configSchema is a database schema to load tables from in addition
+// to the ones on the search path
+type configSchema struct {
+	Name   string
+	Prefix string
+}
+
 type configTable struct {
-	Name      string
-	Table     string
-	Blocklist []string
-	Remotes   []configRemote
-	Columns   []configColumn
+	Name        string
+	Table       string
+	Blocklist   []string
+	Remotes     []configRemote
+	Columns     []configColumn
+	Computed    []configComputed
+	Polymorphic []configPolymorphic
+	Search      *configSearch
+	CacheTTL    time.Duration `mapstructure:"cache_ttl"`
+}
+
+// configSearch is the full-text search config of a table
+type configSearch struct {
+	Language      string
+	Parser        string
+	Normalization int
+	Weights       []float64
+	Headline      struct {
+		MaxWords          int    `mapstructure:"max_words"`
+		MinWords          int    `mapstructure:"min_words"`
+		ShortWord         int    `mapstructure:"short_word"`
+		MaxFragments      int    `mapstructure:"max_fragments"`
+		StartSel          string `mapstructure:"start_sel"`
+		StopSel           string `mapstructure:"stop_sel"`
+		FragmentDelimiter string `mapstructure:"fragment_delimiter"`
+		HighlightAll      bool   `mapstructure:"highlight_all"`
+	}
+}
+
+// configPolymorphic is a relationship to one of several tables, the
+// value in the type column is mapped to the table to use
+type configPolymorphic struct {
+	Name       string
+	TypeColumn string `mapstructure:"type_column"`
+	IDColumn   string `mapstructure:"id_column"`
+	Types      []configPolyType
+}
+
+type configPolyType struct {
+	Value string
+	Table string
+}
+
+// configComputed is a field that is computed using a Postgres function
+// that takes a row of the table or an sql expression
+type configComputed struct {
+	Name     string
+	Function string
+	SQL      string
+	Type     string
 }
 
 type configRemote struct {
@@ -167,10 +246,33 @@ type configRoleTable struct {
 type configRole struct {
 	Name      string
 	Match     string
+	Limits    configRoleLimits
+	RateLimit configRateLimit `mapstructure:"rate_limit"`
 	Tables    []configRoleTable
 	tablesMap map[string]*configRoleTable
 }
 
+type configRoleLimits struct {
+	MaxDepth      int `mapstructure:"max_depth"`
+	MaxRows       int `mapstructure:"max_rows"`
+	MaxAggregates int `mapstructure:"max_aggregates"`
+}
+
+// configRateLimit sets the requests per second and the burst allowed
+// for each user of the role, it can be lowered further for queries
+// from the allow list or actions using their names
+type configRateLimit struct {
+	Rate    float64
+	Burst   int
+	Queries []configQueryRateLimit
+}
+
+type configQueryRateLimit struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
 type configAction struct {
 	Name     string
 	SQL      string
@@ -197,6 +299,7 @@ func newConfig(name string) *viper.Viper {
 	vi.SetDefault("enable_tracing", false)
 	vi.SetDefault("auth_fail_block", "always")
 	vi.SetDefault("seed_file", "seed.js")
+	vi.SetDefault("subs_poll_duration", defaultSubsPollDuration)
 
 	vi.SetDefault("database.type", "postgres")
 	vi.SetDefault("database.host", "localhost")
@@ -230,6 +333,11 @@ func (c *config) Init(vi *viper.Viper) error {
 		c.Production = true
 	}
 
+	if c.SubsPollDuration <= 0 {
+		logger.Warn().Msgf("invalid subs_poll_duration '%s', using %s", c.SubsPollDuration, defaultSubsPollDuration)
+		c.SubsPollDuration = defaultSubsPollDuration
+	}
+
 	for k, v := range c.Inflections {
 		flect.AddPlural(k, v)
 	}
@@ -57,6 +57,97 @@ func addTable(di *psql.DBInfo, cols []configColumn, t configTable) error {
 	return nil
 }
 
+func addComputedColumns(c *config, schema *psql.DBSchema) error {
+	for _, t := range c.Tables {
+		for _, cc := range t.Computed {
+			if err := addComputedColumn(schema, cc, t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func addComputedColumn(schema *psql.DBSchema, cc configComputed, t configTable) error {
+	col := psql.DBColumn{
+		Name: cc.Name,
+		Key:  strings.ToLower(cc.Name),
+		Type: cc.Type,
+	}
+
+	switch {
+	case len(cc.Function) != 0 && len(cc.SQL) != 0:
+		return fmt.Errorf(
+			"Computed field '%s' on table '%s' can have either a function or sql not both",
+			cc.Name, t.Name)
+
+	case len(cc.Function) != 0:
+		col.Func = cc.Function
+
+	case len(cc.SQL) != 0:
+		col.Expr = cc.SQL
+
+	default:
+		return fmt.Errorf(
+			"Computed field '%s' on table '%s' needs a function or sql",
+			cc.Name, t.Name)
+	}
+
+	if len(col.Type) == 0 {
+		col.Type = "text"
+	}
+
+	return schema.AddComputedColumn(t.Name, col)
+}
+
+func addPolymorphicRels(c *config, schema *psql.DBSchema) error {
+	for _, t := range c.Tables {
+		for _, p := range t.Polymorphic {
+			types := make([]psql.DBPolyType, 0, len(p.Types))
+
+			for _, v := range p.Types {
+				types = append(types, psql.DBPolyType{Value: v.Value, Table: v.Table})
+			}
+
+			err := schema.AddPolymorphicRel(t.Name, p.Name, p.TypeColumn, p.IDColumn, types)
+			if err != nil {
+				return fmt.Errorf("polymorphic relationship '%s': %w", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func addSearchConfigs(c *config, schema *psql.DBSchema) error {
+	for _, t := range c.Tables {
+		if t.Search == nil {
+			continue
+		}
+		h := t.Search.Headline
+
+		err := schema.SetSearch(t.Name, psql.DBSearch{
+			Language:      t.Search.Language,
+			Parser:        t.Search.Parser,
+			Normalization: t.Search.Normalization,
+			Weights:       t.Search.Weights,
+			Headline: psql.DBHeadline{
+				MaxWords:          h.MaxWords,
+				MinWords:          h.MinWords,
+				ShortWord:         h.ShortWord,
+				MaxFragments:      h.MaxFragments,
+				StartSel:          h.StartSel,
+				StopSel:           h.StopSel,
+				FragmentDelimiter: h.FragmentDelimiter,
+				HighlightAll:      h.HighlightAll,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func addForeignKeys(c *config, di *psql.DBInfo) error {
 	for _, t := range c.Tables {
 		for _, c := range t.Columns {
@@ -72,36 +163,64 @@ func addForeignKeys(c *config, di *psql.DBInfo) error {
 }
 
 func addForeignKey(di *psql.DBInfo, c configColumn, t configTable) error {
-	c1, ok := di.GetColumn(t.Name, c.Name)
-	if !ok {
-		return fmt.Errorf(
-			"Invalid table '%s' or column '%s' in config",
-			t.Name, c.Name)
-	}
+	names := strings.Split(c.Name, ",")
+	refs := strings.Split(c.ForeignKey, ",")
 
-	v := strings.SplitN(c.ForeignKey, ".", 2)
-	if len(v) != 2 {
+	if len(names) != len(refs) {
 		return fmt.Errorf(
 			"Invalid foreign_key in config for table '%s' and column '%s",
 			t.Name, c.Name)
 	}
 
-	fkt, fkc := v[0], v[1]
-	c2, ok := di.GetColumn(fkt, fkc)
-	if !ok {
-		return fmt.Errorf(
-			"Invalid foreign_key in config for table '%s' and column '%s",
-			t.Name, c.Name)
+	cols := make([]*psql.DBColumn, len(names))
+	ids := make([]int16, len(names))
+	fkids := make([]int16, len(names))
+
+	var fkt string
+
+	for i := range names {
+		c1, ok := di.GetColumn(t.Name, strings.TrimSpace(names[i]))
+		if !ok {
+			return fmt.Errorf(
+				"Invalid table '%s' or column '%s' in config",
+				t.Name, c.Name)
+		}
+
+		v := strings.SplitN(strings.TrimSpace(refs[i]), ".", 2)
+		if len(v) != 2 || (i != 0 && v[0] != fkt) {
+			return fmt.Errorf(
+				"Invalid foreign_key in config for table '%s' and column '%s",
+				t.Name, c.Name)
+		}
+
+		fkt = v[0]
+		c2, ok := di.GetColumn(fkt, v[1])
+		if !ok {
+			return fmt.Errorf(
+				"Invalid foreign_key in config for table '%s' and column '%s",
+				t.Name, c.Name)
+		}
+
+		cols[i], ids[i], fkids[i] = c1, c1.ID, c2.ID
 	}
 
-	c1.FKeyTable = fkt
-	c1.FKeyColID = []int16{c2.ID}
+	for _, c1 := range cols {
+		c1.FKeyTable = fkt
+		c1.FKeyColID = fkids
+		c1.FKeyCols = ids
+	}
 
 	return nil
 }
 
 func addRoles(c *config, qc *qcode.Compiler) error {
 	for _, r := range c.Roles {
+		qc.SetRoleLimits(r.Name, qcode.RoleLimits{
+			MaxDepth:      r.Limits.MaxDepth,
+			MaxRows:       r.Limits.MaxRows,
+			MaxAggregates: r.Limits.MaxAggregates,
+		})
+
 		for _, t := range r.Tables {
 			if err := addRole(qc, r, t); err != nil {
 				return err
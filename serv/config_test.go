@@ -2,6 +2,8 @@ package serv
 
 import (
 	"testing"
+
+	"github.com/spf13/viper"
 )
 
 func TestInitConf(t *testing.T) {
@@ -11,3 +13,17 @@ func TestInitConf(t *testing.T) {
 		t.Fatal(err.Error())
 	}
 }
+
+func TestSubsPollDuration(t *testing.T) {
+	vi := viper.New()
+	vi.Set("subs_poll_duration", "0s")
+
+	c := &config{}
+	if err := c.Init(vi); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.SubsPollDuration != defaultSubsPollDuration {
+		t.Fatalf("expected the default subs_poll_duration got %s", c.SubsPollDuration)
+	}
+}
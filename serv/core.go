@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/cespare/xxhash/v2"
@@ -19,12 +19,25 @@ import (
 )
 
 type coreContext struct {
-	req gqlReq
-	res gqlResp
+	req   gqlReq
+	res   gqlResp
+	stmts []stmt
+	tx    pgx.Tx
 	context.Context
 }
 
 func (c *coreContext) handleReq(w io.Writer, req *http.Request) error {
+	c.initReq(req)
+
+	b, err := c.execQuery()
+	if err != nil {
+		return err
+	}
+
+	return c.render(w, b)
+}
+
+func (c *coreContext) initReq(req *http.Request) {
 	c.req.ref = req.Referer()
 	c.req.hdr = req.Header
 
@@ -37,13 +50,6 @@ func (c *coreContext) handleReq(w io.Writer, req *http.Request) error {
 	} else {
 		c.req.role = "anon"
 	}
-
-	b, err := c.execQuery()
-	if err != nil {
-		return err
-	}
-
-	return c.render(w, b)
 }
 
 func (c *coreContext) execQuery() ([]byte, error) {
@@ -51,6 +57,27 @@ func (c *coreContext) execQuery() ([]byte, error) {
 	var st *stmt
 	var err error
 
+	if len(c.req.operation()) == 0 {
+		if len(c.req.OpName) == 0 {
+			return nil, validationErr(errOpNameRequired)
+		}
+		return nil, validationErr(fmt.Errorf("operation '%s' not found", c.req.OpName))
+	}
+
+	var key string
+	var gen uint64
+
+	useCache := c.useCache()
+
+	if useCache {
+		key = c.cacheKey()
+
+		if data, ok := respCache.get(key); ok {
+			return data, nil
+		}
+		gen = respCache.generation()
+	}
+
 	if conf.Production {
 		data, st, err = c.resolvePreparedSQL()
 		if err != nil {
@@ -59,7 +86,7 @@ func (c *coreContext) execQuery() ([]byte, error) {
 				Str("default_role", c.req.role).
 				Msg(c.req.Query)
 
-			return nil, errors.New("query failed. check logs for error")
+			return nil, err
 		}
 
 	} else {
@@ -68,6 +95,17 @@ func (c *coreContext) execQuery() ([]byte, error) {
 		}
 	}
 
+	// mutations evict the responses of the tables they returned
+	// without waiting for the triggers
+	if respCache != nil && qcode.GetQType(c.req.operation()) == qcode.QTMutation {
+		respCache.invalidate(cacheTables(st.qc)...)
+	}
+
+	// responses with remote joins depend on the headers of the request
+	if useCache && st.skipped == 0 {
+		respCache.set(key, data, st.qc, gen)
+	}
+
 	return execRemoteJoin(st, data, c.req.hdr)
 }
 
@@ -75,14 +113,15 @@ func (c *coreContext) resolvePreparedSQL() ([]byte, *stmt, error) {
 	var tx pgx.Tx
 	var err error
 
-	qt := qcode.GetQType(c.req.Query)
+	op := c.req.operation()
+	qt := qcode.GetQType(op)
 	mutation := (qt == qcode.QTMutation)
 
 	useRoleQuery := conf.isABACEnabled() && mutation
-	useTx := useRoleQuery || conf.DB.SetUserID
+	useTx := useRoleQuery || conf.DB.SetUserID || c.tx != nil
 
 	if useTx {
-		if tx, err = db.Begin(c.Context); err != nil {
+		if tx, err = c.begin(); err != nil {
 			return nil, nil, err
 		}
 		defer tx.Rollback(c) //nolint: errcheck
@@ -109,7 +148,20 @@ func (c *coreContext) resolvePreparedSQL() ([]byte, *stmt, error) {
 
 	}
 
-	ps, ok := _preparedList[stmtHash(allow.QueryName(c.req.Query), role)]
+	name := allow.QueryName(op)
+
+	if dvars, ok := _preparedVariants[strings.ToLower(name)]; ok {
+		var vm map[string]json.RawMessage
+
+		if len(c.req.Vars) != 0 {
+			if err := json.Unmarshal(c.req.Vars, &vm); err != nil {
+				return nil, nil, badInputErr(err)
+			}
+		}
+		name += variantKey(dvars, vm)
+	}
+
+	ps, ok := _preparedList[stmtHash(name, role)]
 	if !ok {
 		return nil, nil, errUnauthorized
 	}
@@ -117,7 +169,7 @@ func (c *coreContext) resolvePreparedSQL() ([]byte, *stmt, error) {
 	var root []byte
 	var row pgx.Row
 
-	vars, err := argList(c, ps.args)
+	vars, err := argList(c, ps.args, ps.st.qc)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -125,7 +177,7 @@ func (c *coreContext) resolvePreparedSQL() ([]byte, *stmt, error) {
 	if useTx {
 		row = tx.QueryRow(c.Context, ps.sd.SQL, vars...)
 	} else {
-		row = db.QueryRow(c.Context, ps.sd.SQL, vars...)
+		row = c.queryPool(qt).QueryRow(c.Context, ps.sd.SQL, vars...)
 	}
 
 	if ps.roleArg {
@@ -134,6 +186,10 @@ func (c *coreContext) resolvePreparedSQL() ([]byte, *stmt, error) {
 		err = row.Scan(&root)
 	}
 
+	if err != nil {
+		err = execErr(err, ps.st.qc)
+	}
+
 	if len(role) == 0 {
 		logger.Debug().Str("default_role", c.req.role).Msg(c.req.Query)
 	} else {
@@ -151,6 +207,7 @@ func (c *coreContext) resolvePreparedSQL() ([]byte, *stmt, error) {
 			return nil, nil, err
 		}
 	}
+	c.addWrite(qt)
 
 	if root, err = encryptCursor(ps.st.qc, root); err != nil {
 		return nil, nil, err
@@ -163,14 +220,15 @@ func (c *coreContext) resolveSQL() ([]byte, *stmt, error) {
 	var tx pgx.Tx
 	var err error
 
-	qt := qcode.GetQType(c.req.Query)
+	op := c.req.operation()
+	qt := qcode.GetQType(op)
 	mutation := (qt == qcode.QTMutation)
 
 	useRoleQuery := conf.isABACEnabled() && mutation
-	useTx := useRoleQuery || conf.DB.SetUserID
+	useTx := useRoleQuery || conf.DB.SetUserID || c.tx != nil
 
 	if useTx {
-		if tx, err = db.Begin(c.Context); err != nil {
+		if tx, err = c.begin(); err != nil {
 			return nil, nil, err
 		}
 		defer tx.Rollback(c.Context) //nolint: errcheck
@@ -191,10 +249,16 @@ func (c *coreContext) resolveSQL() ([]byte, *stmt, error) {
 		c.req.role = v.(string)
 	}
 
-	stmts, err := buildStmt(qt, []byte(c.req.Query), c.req.Vars, c.req.role)
-	if err != nil {
-		return nil, nil, err
+	// subscriptions re-run the statements compiled on the first run
+	compiled := (c.stmts != nil)
+
+	if !compiled {
+		c.stmts, err = buildStmt(qt, []byte(c.req.Query), c.req.OpName, c.req.Vars, c.req.role)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+	stmts := c.stmts
 	st := &stmts[0]
 
 	//fmt.Println(">", string(st.sql))
@@ -202,7 +266,7 @@ func (c *coreContext) resolveSQL() ([]byte, *stmt, error) {
 	t := fasttemplate.New(st.sql, openVar, closeVar)
 	buf := &bytes.Buffer{}
 
-	_, err = t.ExecuteFunc(buf, argMap(c, c.req.Vars))
+	_, err = t.ExecuteFunc(buf, argMap(c, c.req.Vars, st.qc))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -223,7 +287,7 @@ func (c *coreContext) resolveSQL() ([]byte, *stmt, error) {
 	if useTx {
 		row = tx.QueryRow(c.Context, finalSQL)
 	} else {
-		row = db.QueryRow(c.Context, finalSQL)
+		row = c.queryPool(qt).QueryRow(c.Context, finalSQL)
 	}
 
 	if len(stmts) > 1 {
@@ -232,6 +296,10 @@ func (c *coreContext) resolveSQL() ([]byte, *stmt, error) {
 		err = row.Scan(&root)
 	}
 
+	if err != nil {
+		err = execErr(err, st.qc)
+	}
+
 	if len(role) == 0 {
 		logger.Debug().Str("default_role", defaultRole).Msg(c.req.Query)
 	} else {
@@ -247,13 +315,14 @@ func (c *coreContext) resolveSQL() ([]byte, *stmt, error) {
 			return nil, nil, err
 		}
 	}
+	c.addWrite(qt)
 
 	if root, err = encryptCursor(st.qc, root); err != nil {
 		return nil, nil, err
 	}
 
-	if allowList.IsPersist() {
-		if err := allowList.Set(c.req.Vars, c.req.Query, c.req.ref); err != nil {
+	if allowList.IsPersist() && !compiled {
+		if err := allowList.Set(c.req.Vars, op, apqHash(c.req.Query), c.req.ref); err != nil {
 			return nil, nil, err
 		}
 	}
@@ -273,6 +342,22 @@ func (c *coreContext) resolveSQL() ([]byte, *stmt, error) {
 	return root, st, nil
 }
 
+// begin starts a new transaction, when part of a batch transaction a
+// savepoint is used instead so the operation can commit or roll back on its own
+func (c *coreContext) begin() (pgx.Tx, error) {
+	if c.tx != nil {
+		return c.tx.Begin(c.Context)
+	}
+	return db.Begin(c.Context)
+}
+
+// introspectionRole returns the role used to generate the introspection
+// schema. Roles that are resolved using the roles_query are not available
+// here so authenticated users see the schema of the 'user' role.
+func (c *coreContext) introspectionRole() string {
+	return requestRole(c)
+}
+
 func (c *coreContext) executeRoleQuery(tx pgx.Tx) (string, error) {
 	userID := c.Value(userIDKey)
 
@@ -18,28 +18,28 @@ type stmt struct {
 	sql     string
 }
 
-func buildStmt(qt qcode.QType, gql, vars []byte, role string) ([]stmt, error) {
+func buildStmt(qt qcode.QType, gql []byte, opName string, vars []byte, role string) ([]stmt, error) {
 	switch qt {
 	case qcode.QTMutation:
-		return buildRoleStmt(gql, vars, role)
+		return buildRoleStmt(gql, opName, vars, role)
 
 	case qcode.QTQuery:
 		if role == "anon" {
-			return buildRoleStmt(gql, vars, "anon")
+			return buildRoleStmt(gql, opName, vars, "anon")
 		}
 
 		if conf.isABACEnabled() {
-			return buildMultiStmt(gql, vars)
+			return buildMultiStmt(gql, opName, vars)
 		}
 
-		return buildRoleStmt(gql, vars, "user")
+		return buildRoleStmt(gql, opName, vars, "user")
 
 	default:
 		return nil, fmt.Errorf("unknown query type '%d'", qt)
 	}
 }
 
-func buildRoleStmt(gql, vars []byte, role string) ([]stmt, error) {
+func buildRoleStmt(gql []byte, opName string, vars []byte, role string) ([]stmt, error) {
 	ro, ok := conf.roles[role]
 	if !ok {
 		return nil, fmt.Errorf(`roles '%s' not defined in config`, role)
@@ -50,13 +50,13 @@ func buildRoleStmt(gql, vars []byte, role string) ([]stmt, error) {
 
 	if len(vars) != 0 {
 		if err := json.Unmarshal(vars, &vm); err != nil {
-			return nil, err
+			return nil, badInputErr(err)
 		}
 	}
 
-	qc, err := qcompile.Compile(gql, ro.Name)
+	qc, err := qcompile.CompileOp(gql, opName, vm, ro.Name)
 	if err != nil {
-		return nil, err
+		return nil, validationErr(err)
 	}
 
 	stmts := []stmt{stmt{role: ro, qc: qc}}
@@ -64,7 +64,7 @@ func buildRoleStmt(gql, vars []byte, role string) ([]stmt, error) {
 
 	skipped, err := pcompile.Compile(qc, w, psql.Variables(vm))
 	if err != nil {
-		return nil, err
+		return nil, validationErr(err)
 	}
 
 	stmts[0].skipped = skipped
@@ -73,13 +73,13 @@ func buildRoleStmt(gql, vars []byte, role string) ([]stmt, error) {
 	return stmts, nil
 }
 
-func buildMultiStmt(gql, vars []byte) ([]stmt, error) {
+func buildMultiStmt(gql []byte, opName string, vars []byte) ([]stmt, error) {
 	var vm map[string]json.RawMessage
 	var err error
 
 	if len(vars) != 0 {
 		if err := json.Unmarshal(vars, &vm); err != nil {
-			return nil, err
+			return nil, badInputErr(err)
 		}
 	}
 
@@ -98,16 +98,16 @@ func buildMultiStmt(gql, vars []byte) ([]stmt, error) {
 			continue
 		}
 
-		qc, err := qcompile.Compile(gql, role.Name)
+		qc, err := qcompile.CompileOp(gql, opName, vm, role.Name)
 		if err != nil {
-			return nil, err
+			return nil, validationErr(err)
 		}
 
 		stmts = append(stmts, stmt{role: role, qc: qc})
 
 		skipped, err := pcompile.Compile(qc, w, psql.Variables(vm))
 		if err != nil {
-			return nil, err
+			return nil, validationErr(err)
 		}
 
 		s := &stmts[len(stmts)-1]
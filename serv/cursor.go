@@ -3,16 +3,25 @@ package serv
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 
 	"github.com/dosco/super-graph/crypto"
 	"github.com/dosco/super-graph/jsn"
 	"github.com/dosco/super-graph/qcode"
 )
 
+var errInvalidCursor = badInputErr(errors.New("cursor is not valid for this query, the order or the schema may have changed"))
+
 func encryptCursor(qc *qcode.QCode, data []byte) ([]byte, error) {
 	var keys [][]byte
 
 	for _, s := range qc.Selects {
+		if s.Connection != nil {
+			keys = append(keys, connectionCursorKeys(s.Connection)...)
+			continue
+		}
+
 		if s.Paging.Type != qcode.PtOffset {
 			var buf bytes.Buffer
 
@@ -39,7 +48,13 @@ func encryptCursor(qc *qcode.QCode, data []byte) ([]byte, error) {
 		var buf bytes.Buffer
 
 		if len(f.Value) > 2 {
-			v, err := crypto.Encrypt(f.Value[1:len(f.Value)-1], &internalKey)
+			// cursors hold json values so they are unescaped first
+			var cur string
+			if err := json.Unmarshal(f.Value, &cur); err != nil {
+				return nil, err
+			}
+
+			v, err := crypto.Encrypt([]byte(cur), &internalKey)
 			if err != nil {
 				return nil, err
 			}
@@ -63,6 +78,24 @@ func encryptCursor(qc *qcode.QCode, data []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// connectionCursorKeys returns the keys of the edge cursors and the
+// start and end cursors of the page in a Relay connection
+func connectionCursorKeys(c *qcode.Connection) [][]byte {
+	var keys [][]byte
+
+	if len(c.EdgeCursor) != 0 {
+		keys = append(keys, []byte(c.EdgeCursor))
+	}
+
+	for _, col := range c.PageInfoCols {
+		if col.Name == "startcursor" || col.Name == "endcursor" {
+			keys = append(keys, []byte(col.FieldName))
+		}
+	}
+
+	return keys
+}
+
 func decrypt(data string) ([]byte, error) {
 	v, err := base64.StdEncoding.DecodeString(data)
 	if err != nil {
@@ -70,3 +103,36 @@ func decrypt(data string) ([]byte, error) {
 	}
 	return crypto.Decrypt(v, &internalKey)
 }
+
+// decryptCursor decrypts the cursor and returns its values once it's
+// checked that the cursor was created by a select of the query using the
+// same version of the cursor format, order and schema
+func decryptCursor(qc *qcode.QCode, data string) ([]byte, error) {
+	v, err := decrypt(data)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	if qc == nil {
+		return nil, errInvalidCursor
+	}
+
+	for i := range qc.Selects {
+		s := &qc.Selects[i]
+
+		if !s.Paging.Cursor {
+			continue
+		}
+
+		prefix, err := pcompile.CursorPrefix(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.HasPrefix(v, []byte(prefix)) {
+			return v[len(prefix):], nil
+		}
+	}
+
+	return nil, errInvalidCursor
+}
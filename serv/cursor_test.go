@@ -0,0 +1,103 @@
+package serv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/dosco/super-graph/crypto"
+	"github.com/dosco/super-graph/jsn"
+	"github.com/dosco/super-graph/psql"
+	"github.com/dosco/super-graph/qcode"
+)
+
+func TestEncryptConnectionCursor(t *testing.T) {
+	qcompile, _ := qcode.NewCompiler(qcode.Config{})
+
+	qc, err := qcompile.Compile([]byte(`query {
+		products_connection(first: 1) {
+			pageInfo { endCursor }
+			edges { cursor node { id } }
+		}
+	}`), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`{"products_connection": {"pageInfo": {"endCursor": "1"}, "edges": [{"cursor": "1", "node": {"id": 1}}]}}`)
+
+	res, err := encryptCursor(qc, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := jsn.Get(res, [][]byte{[]byte("endCursor"), []byte("cursor")})
+
+	if len(fields) != 2 {
+		t.Fatalf("expected 2 cursors found %d: %s", len(fields), res)
+	}
+
+	for _, f := range fields {
+		v, err := decrypt(string(bytes.Trim(f.Value, `"`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(v) != "1" {
+			t.Fatalf("unexpected cursor value '%s' for '%s'", v, f.Key)
+		}
+	}
+}
+
+func TestDecryptCursor(t *testing.T) {
+	initIntrospectionTest(t)
+
+	pc := pcompile
+	pcompile = psql.NewCompiler(psql.Config{Schema: schema})
+	defer func() { pcompile = pc }()
+
+	qcompile, _ := qcode.NewCompiler(qcode.Config{})
+
+	qc, err := qcompile.Compile([]byte(`query {
+		products(first: 5, after: $cursor, order_by: { name: desc }) { id }
+	}`), "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := pcompile.CompileEx(qc, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix, err := pcompile.CursorPrefix(&qc.Selects[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`{"products_cursor": "` + prefix + `[\"it's\", 2]"}`)
+
+	res, err := encryptCursor(qc, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cur := jsn.Get(res, [][]byte{[]byte("products_cursor")})[0].Value
+
+	v, err := decryptCursor(qc, string(bytes.Trim(cur, `"`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(v) != `["it's", 2]` {
+		t.Fatalf("unexpected cursor value '%s'", v)
+	}
+
+	old, err := crypto.Encrypt([]byte(`0:00000000:["it's", 2]`), &internalKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := decryptCursor(qc, base64.StdEncoding.EncodeToString(old)); err != errInvalidCursor {
+		t.Fatalf("expected an invalid cursor error got: %v", err)
+	}
+}
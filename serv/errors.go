@@ -0,0 +1,141 @@
+package serv
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dosco/super-graph/qcode"
+	"github.com/jackc/pgconn"
+)
+
+// Error codes returned in the extensions.code field of each error
+const (
+	errCodeParse        = "GRAPHQL_PARSE_FAILED"
+	errCodeValidation   = "GRAPHQL_VALIDATION_FAILED"
+	errCodeBadInput     = "BAD_USER_INPUT"
+	errCodeUnauthorized = "UNAUTHORIZED"
+	errCodeForbidden    = "FORBIDDEN"
+	errCodeConstraint   = "CONSTRAINT_VIOLATION"
+	errCodeConflict     = "CONFLICT"
+	errCodeTimeout      = "TIMEOUT"
+	errCodeRateLimited  = "RATE_LIMITED"
+	errCodeRolledBack   = "BATCH_ROLLED_BACK"
+	errCodeDatabase     = "DATABASE_ERROR"
+	errCodeInternal     = "INTERNAL_SERVER_ERROR"
+)
+
+const errMaskedMsg = "query failed. check logs for error"
+
+type gqlError struct {
+	Message    string         `json:"message"`
+	Locations  []gqlLocation  `json:"locations,omitempty"`
+	Path       []string       `json:"path,omitempty"`
+	Extensions *errExtensions `json:"extensions,omitempty"`
+}
+
+type gqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type errExtensions struct {
+	Code string `json:"code"`
+}
+
+// queryErr attaches an error code and the path of the failing field
+// to an error
+type queryErr struct {
+	code string
+	path []string
+	err  error
+}
+
+func (e *queryErr) Error() string {
+	return e.err.Error()
+}
+
+func (e *queryErr) Unwrap() error {
+	return e.err
+}
+
+func validationErr(err error) error {
+	return &queryErr{code: errCodeValidation, err: err}
+}
+
+func badInputErr(err error) error {
+	return &queryErr{code: errCodeBadInput, err: err}
+}
+
+// execErr adds the path of the root field to errors returned when
+// executing the query. The path is only known when there's a single root
+// field since all root fields are fetched using a single sql statement.
+func execErr(err error, qc *qcode.QCode) error {
+	e := &queryErr{err: err}
+
+	if qc != nil && len(qc.Roots) == 1 {
+		e.path = []string{qc.Selects[qc.Roots[0]].FieldName}
+	}
+
+	return e
+}
+
+func gqlErrors(err error) []gqlError {
+	var qerr *qcode.Error
+	var perr *pgconn.PgError
+	var cerr *queryErr
+
+	e := gqlError{Message: err.Error()}
+	code := errCodeInternal
+
+	if errors.As(err, &cerr) {
+		e.Path = cerr.path
+
+		if len(cerr.code) != 0 {
+			code = cerr.code
+		}
+	}
+
+	switch {
+	case errors.As(err, &qerr):
+		code = errCodeParse
+		e.Locations = []gqlLocation{{Line: qerr.Line, Column: qerr.Column}}
+
+	case errors.Is(err, errUnauthorized):
+		code = errCodeUnauthorized
+
+	case errors.As(err, &perr):
+		code = pgErrorCode(perr)
+	}
+
+	// database and internal errors can leak details of the schema
+	if conf != nil && conf.Production && (perr != nil || code == errCodeInternal) {
+		e.Message = errMaskedMsg
+	}
+
+	e.Extensions = &errExtensions{Code: code}
+
+	return []gqlError{e}
+}
+
+// pgErrorCode maps a Postgres SQLSTATE to an error code
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+func pgErrorCode(e *pgconn.PgError) string {
+	switch {
+	case strings.HasPrefix(e.Code, "23"): // integrity_constraint_violation
+		return errCodeConstraint
+
+	case strings.HasPrefix(e.Code, "22"): // data_exception
+		return errCodeBadInput
+
+	case e.Code == "42501": // insufficient_privilege
+		return errCodeForbidden
+
+	case e.Code == "40001", e.Code == "40P01": // serialization_failure, deadlock_detected
+		return errCodeConflict
+
+	case e.Code == "57014": // query_canceled
+		return errCodeTimeout
+	}
+
+	return errCodeDatabase
+}
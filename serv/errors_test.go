@@ -0,0 +1,72 @@
+package serv
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dosco/super-graph/qcode"
+	"github.com/jackc/pgconn"
+)
+
+func TestErrorCodes(t *testing.T) {
+	conf = &config{}
+
+	qc := &qcode.QCode{
+		Selects: []qcode.Select{{ID: 0, ParentID: -1, Name: "users", FieldName: "me"}},
+		Roots:   []int32{0},
+	}
+
+	_, parseErr := qcode.Parse([]byte("query { users(id: ) }"), "")
+
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{validationErr(parseErr), errCodeParse},
+		{validationErr(errors.New("table not found")), errCodeValidation},
+		{argErr("id"), errCodeBadInput},
+		{errUnauthorized, errCodeUnauthorized},
+		{execErr(&pgconn.PgError{Code: "23505"}, qc), errCodeConstraint},
+		{execErr(&pgconn.PgError{Code: "22P02"}, qc), errCodeBadInput},
+		{execErr(&pgconn.PgError{Code: "42P01"}, qc), errCodeDatabase},
+		{fmt.Errorf("invalid role"), errCodeInternal},
+	}
+
+	for _, v := range tests {
+		e := gqlErrors(v.err)[0]
+
+		if e.Extensions == nil || e.Extensions.Code != v.code {
+			t.Fatalf("expected code '%s' for error '%s' got '%v'", v.code, v.err, e.Extensions)
+		}
+	}
+
+	e := gqlErrors(validationErr(parseErr))[0]
+	if len(e.Locations) != 1 || e.Locations[0].Line != 1 || e.Locations[0].Column != 19 {
+		t.Fatalf("expected location line 1, column 19 got %v", e.Locations)
+	}
+
+	e = gqlErrors(execErr(&pgconn.PgError{Code: "23505"}, qc))[0]
+	if len(e.Path) != 1 || e.Path[0] != "me" {
+		t.Fatalf("expected path [me] got %v", e.Path)
+	}
+}
+
+func TestErrorMaskedInProduction(t *testing.T) {
+	conf = &config{Production: true}
+	defer func() { conf = &config{} }()
+
+	e := gqlErrors(execErr(&pgconn.PgError{Code: "23505", Message: "users_email_key"}, nil))[0]
+
+	if e.Message != errMaskedMsg {
+		t.Fatalf("expected masked message got '%s'", e.Message)
+	}
+
+	if e.Extensions.Code != errCodeConstraint {
+		t.Fatalf("expected code '%s' got '%s'", errCodeConstraint, e.Extensions.Code)
+	}
+
+	if e = gqlErrors(argErr("id"))[0]; e.Message == errMaskedMsg {
+		t.Fatal("user input errors should not be masked")
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/dosco/super-graph/allow"
+	"github.com/dosco/super-graph/qcode"
+	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
 )
 
@@ -20,20 +23,35 @@ const (
 )
 
 var (
-	errUnauthorized = errors.New("not authorized")
+	errUnauthorized   = errors.New("not authorized")
+	errOpNameRequired = errors.New("operationName is required for documents with several operations")
 )
 
 type gqlReq struct {
-	OpName string          `json:"operationName"`
-	Query  string          `json:"query"`
-	Vars   json.RawMessage `json:"variables"`
-	ref    string
-	role   string
-	hdr    http.Header
+	OpName     string            `json:"operationName"`
+	Query      string            `json:"query"`
+	Vars       json.RawMessage   `json:"variables"`
+	Extensions *gqlReqExtensions `json:"extensions"`
+	ref        string
+	role       string
+	hdr        http.Header
+}
+
+// operation returns the operation selected by operationName from the
+// query document along with the fragments it uses or an empty string
+// if it's not found or no operationName is given for a document with
+// several operations
+func (r *gqlReq) operation() string {
+	op := allow.FindOperation(r.Query, r.OpName)
+
+	if len(op) == 0 && len(r.OpName) == 0 && allow.OperationCount(r.Query) < 2 {
+		return r.Query
+	}
+	return op
 }
 
 type gqlResp struct {
-	Error      string          `json:"message,omitempty"`
+	Errors     []gqlError      `json:"errors,omitempty"`
 	Data       json.RawMessage `json:"data,omitempty"`
 	Extensions *extensions     `json:"extensions,omitempty"`
 }
@@ -80,39 +98,75 @@ func apiV1Handler() http.Handler {
 func apiV1(w http.ResponseWriter, r *http.Request) {
 	ctx := &coreContext{Context: r.Context()}
 
+	// websockets are authenticated using the payload of connection_init
+	if websocket.IsWebSocketUpgrade(r) {
+		apiV1Ws(w, r)
+		return
+	}
+
 	//nolint: errcheck
 	if conf.AuthFailBlock && !authCheck(ctx) {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(gqlResp{Error: errUnauthorized.Error()})
+		json.NewEncoder(w).Encode(gqlResp{Errors: gqlErrors(errUnauthorized)})
 		return
 	}
 
-	b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxReadBytes))
-	if err != nil {
-		errlog.Error().Err(err).Msg("failed to read request body")
+	if r.Method == http.MethodGet {
+		if err := parseGetReq(r, &ctx.req); err != nil {
+			errlog.Error().Err(err).Msg("failed to decode request url")
+			errorResp(w, badInputErr(err))
+			return
+		}
+
+	} else {
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxReadBytes))
+		if err != nil {
+			errlog.Error().Err(err).Msg("failed to read request body")
+			errorResp(w, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if isBatch(b) {
+			apiV1Batch(w, r, b)
+			return
+		}
+
+		err = json.Unmarshal(b, &ctx.req)
+		if err != nil {
+			errlog.Error().Err(err).Msg("failed to decode json request body")
+			errorResp(w, badInputErr(err))
+			return
+		}
+	}
+
+	if err := ctx.resolvePersistedQuery(); err != nil {
 		errorResp(w, err)
 		return
 	}
-	defer r.Body.Close()
 
-	err = json.Unmarshal(b, &ctx.req)
-	if err != nil {
-		errlog.Error().Err(err).Msg("failed to decode json request body")
-		errorResp(w, err)
+	if err := checkRateLimit(r, allow.QueryName(ctx.req.operation())); err != nil {
+		rateLimitResp(w, err)
+		return
+	}
+
+	// GET requests can be cached and must not change any data
+	if r.Method == http.MethodGet && qcode.GetQType(ctx.req.operation()) == qcode.QTMutation {
+		errorResp(w, errGetMutation)
 		return
 	}
 
 	if strings.EqualFold(ctx.req.OpName, introspectionQuery) {
-		introspect(w)
+		introspect(w, ctx.introspectionRole())
 		return
 	}
 
-	err = ctx.handleReq(w, r)
+	err := ctx.handleReq(w, r)
 
 	//nolint: errcheck
-	if err == errUnauthorized {
+	if errors.Is(err, errUnauthorized) {
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(gqlResp{Error: err.Error()})
+		json.NewEncoder(w).Encode(gqlResp{Errors: gqlErrors(err)})
 		return
 	}
 
@@ -125,5 +179,5 @@ func apiV1(w http.ResponseWriter, r *http.Request) {
 
 //nolint: errcheck
 func errorResp(w http.ResponseWriter, err error) {
-	json.NewEncoder(w).Encode(gqlResp{Error: err.Error()})
+	json.NewEncoder(w).Encode(gqlResp{Errors: gqlErrors(err)})
 }
@@ -0,0 +1,29 @@
+package serv
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOperationNameRequired(t *testing.T) {
+	c := &coreContext{Context: context.Background()}
+	c.req.Query = "query getMe { me { id } }\nquery getProducts { products { id } }"
+
+	if op := c.req.operation(); len(op) != 0 {
+		t.Fatalf("expected no operation got '%s'", op)
+	}
+
+	_, err := c.execQuery()
+
+	var qerr *queryErr
+	if !errors.As(err, &qerr) || qerr.code != errCodeValidation || !errors.Is(err, errOpNameRequired) {
+		t.Fatalf("expected '%v' got '%v'", errOpNameRequired, err)
+	}
+
+	c.req.OpName = "getProducts"
+
+	if op := c.req.operation(); op != "query getProducts { products { id } }" {
+		t.Fatalf("expected the operation 'getProducts' got '%s'", op)
+	}
+}
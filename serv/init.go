@@ -102,6 +102,12 @@ func initDB(c *config, useDB bool) (*pgx.Conn, error) {
 }
 
 func initDBPool(c *config) (*pgxpool.Pool, error) {
+	return connectDBPool(dbPoolConfig(c))
+}
+
+// dbPoolConfig returns the config of the connection pool to
+// the primary database
+func dbPoolConfig(c *config) *pgxpool.Config {
 	config, _ := pgxpool.ParseConfig("")
 	config.ConnConfig.Host = c.DB.Host
 	config.ConnConfig.Port = c.DB.Port
@@ -136,6 +142,10 @@ func initDBPool(c *config) (*pgxpool.Pool, error) {
 		config.MaxConns = conf.DB.PoolSize
 	}
 
+	return config
+}
+
+func connectDBPool(config *pgxpool.Config) (*pgxpool.Pool, error) {
 	var db *pgxpool.Pool
 	var err error
 
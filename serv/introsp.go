@@ -1,37 +1,752 @@
 package serv
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dosco/super-graph/psql"
+	"github.com/gobuffalo/flect"
+)
+
+type introResp struct {
+	Data struct {
+		Schema *introSchema `json:"__schema"`
+	} `json:"data"`
+}
+
+type introSchema struct {
+	QueryType        *introName       `json:"queryType"`
+	MutationType     *introName       `json:"mutationType"`
+	SubscriptionType *introName       `json:"subscriptionType"`
+	Types            []*introType     `json:"types"`
+	Directives       []introDirective `json:"directives"`
+}
+
+type introName struct {
+	Name string `json:"name"`
+}
+
+type introType struct {
+	Kind          string            `json:"kind"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Fields        []introField      `json:"fields"`
+	InputFields   []introInputValue `json:"inputFields"`
+	Interfaces    []introName       `json:"interfaces"`
+	EnumValues    []introEnumValue  `json:"enumValues"`
+	PossibleTypes []introName       `json:"possibleTypes"`
+}
+
+type introTypeRef struct {
+	Kind   string        `json:"kind"`
+	Name   *string       `json:"name"`
+	OfType *introTypeRef `json:"ofType"`
+}
+
+type introField struct {
+	Name              string            `json:"name"`
+	Description       string            `json:"description"`
+	Args              []introInputValue `json:"args"`
+	Type              *introTypeRef     `json:"type"`
+	IsDeprecated      bool              `json:"isDeprecated"`
+	DeprecationReason *string           `json:"deprecationReason"`
+}
+
+type introInputValue struct {
+	Name         string        `json:"name"`
+	Description  string        `json:"description"`
+	Type         *introTypeRef `json:"type"`
+	DefaultValue *string       `json:"defaultValue"`
+}
+
+type introEnumValue struct {
+	Name              string  `json:"name"`
+	Description       string  `json:"description"`
+	IsDeprecated      bool    `json:"isDeprecated"`
+	DeprecationReason *string `json:"deprecationReason"`
+}
+
+type introDirective struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Locations   []string          `json:"locations"`
+	Args        []introInputValue `json:"args"`
+}
+
+const (
+	kindScalar      = "SCALAR"
+	kindObject      = "OBJECT"
+	kindEnum        = "ENUM"
+	kindInputObject = "INPUT_OBJECT"
+	kindList        = "LIST"
+	kindNonNull     = "NON_NULL"
+)
+
+var (
+	introScalars = []string{"Int", "Float", "String", "Boolean", "ID", "JSON"}
+
+	introOrderValues = []string{
+		"asc", "desc",
+		"asc_nulls_first", "desc_nulls_first",
+		"asc_nulls_last", "desc_nulls_last",
+	}
+
+	introCompareOps  = []string{"eq", "neq", "gt", "gte", "lt", "lte"}
+	introListOps     = []string{"in", "nin"}
+	introTextOps     = []string{"like", "nlike", "ilike", "nilike", "similar", "nsimilar"}
+	introJSONOps     = []string{"contains", "contained_in", "has_key", "has_key_any", "has_key_all"}
+	introJSONPathOps = []string{"path", "path_exists", "path_match"}
+	introAggFuncs    = []string{"sum", "avg", "max", "min", "stddev", "variance"}
+)
+
+// nolint: errcheck
+func introspect(w http.ResponseWriter, role string) {
+	var res introResp
+	res.Data.Schema = buildIntrospection(role)
 
-//nolint: errcheck
-func introspect(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{
-		"data": {
-			"__schema": {
-				"queryType": {
-					"name": "Query"
-				},
-				"mutationType": null,
-				"subscriptionType": null
+	json.NewEncoder(w).Encode(res)
+}
+
+// introBuilder generates the GraphQL introspection schema from the database
+// schema as seen by a single role
+type introBuilder struct {
+	role  *configRole
+	anon  bool
+	types []*introType
+	tm    map[string]*introType
+	bl    map[string]struct{}
+}
+
+func buildIntrospection(role string) *introSchema {
+	b := &introBuilder{
+		role: conf.roles[role],
+		anon: (role == "anon"),
+		tm:   make(map[string]*introType),
+		bl:   make(map[string]struct{}, len(conf.DB.Blocklist)),
+	}
+
+	for _, v := range conf.DB.Blocklist {
+		b.bl[strings.ToLower(v)] = struct{}{}
+	}
+
+	for _, v := range introScalars {
+		b.addType(&introType{Kind: kindScalar, Name: v})
+	}
+
+	ob := &introType{Kind: kindEnum, Name: "OrderDirection"}
+	for _, v := range introOrderValues {
+		ob.EnumValues = append(ob.EnumValues, introEnumValue{Name: v})
+	}
+	b.addType(ob)
+
+	query := &introType{Kind: kindObject, Name: "Query", Interfaces: []introName{}}
+	mutation := &introType{Kind: kindObject, Name: "Mutation", Interfaces: []introName{}}
+
+	for _, name := range schema.GetTableNames() {
+		ti, err := schema.GetTable(name)
+		if err != nil || !b.isTableAllowed(name, ti) {
+			continue
+		}
+
+		// Polymorphic relationships point to other tables
+		// and can't be queried by themselves
+		if ti.Type == "polymorphic" {
+			continue
+		}
+
+		b.addTableTypes(ti)
+
+		// Tables backed by json columns can only be reached
+		// through their parent table
+		if ti.Type == "json" || ti.Type == "jsonb" {
+			continue
+		}
+
+		query.Fields = append(query.Fields, introField{
+			Name: name,
+			Args: b.queryArgs(ti),
+			Type: b.tableRef(ti),
+		})
+
+		// Relay connections use cursor pagination which needs a primary key
+		if !ti.Singular && ti.PrimaryCol != nil {
+			query.Fields = append(query.Fields, introField{
+				Name: name + "_connection",
+				Args: b.queryArgs(ti),
+				Type: nonNull(named(kindObject, b.connectionType(ti))),
+			})
+		}
+
+		// Functions that return a table can only be queried
+		if ti.Type != "table" || ti.Func != nil {
+			continue
+		}
+
+		if args := b.mutationArgs(name, ti); len(args) != 0 {
+			mutation.Fields = append(mutation.Fields, introField{
+				Name: name,
+				Args: args,
+				Type: b.tableRef(ti),
+			})
+		}
+	}
+
+	sc := &introSchema{
+		QueryType:        &introName{Name: "Query"},
+		SubscriptionType: &introName{Name: "Subscription"},
+		Directives:       introDirectives(),
+	}
+	b.addType(query)
+
+	// Subscriptions are live queries and share the fields of the query type
+	b.addType(&introType{
+		Kind:       kindObject,
+		Name:       "Subscription",
+		Fields:     query.Fields,
+		Interfaces: []introName{},
+	})
+
+	if len(mutation.Fields) != 0 {
+		sc.MutationType = &introName{Name: "Mutation"}
+		b.addType(mutation)
+	}
+
+	sc.Types = b.types
+	return sc
+}
+
+func (b *introBuilder) addType(t *introType) {
+	if _, ok := b.tm[t.Name]; ok {
+		return
+	}
+	b.tm[t.Name] = t
+	b.types = append(b.types, t)
+}
+
+func (b *introBuilder) roleTable(name string) *configRoleTable {
+	if b.role == nil {
+		return nil
+	}
+
+	if t, ok := b.role.tablesMap[name]; ok {
+		return t
+	}
+
+	if t, ok := b.role.tablesMap[flect.Pluralize(name)]; ok {
+		return t
+	}
+
+	return nil
+}
+
+func (b *introBuilder) isTableAllowed(name string, ti *psql.DBTableInfo) bool {
+	if _, ok := b.bl[name]; ok {
+		return false
+	}
+
+	t := b.roleTable(name)
+	if t == nil {
+		t = b.roleTable(ti.Name)
+	}
+
+	// Tables not defined under the anon role are never rendered
+	if t == nil {
+		return !b.anon
+	}
+
+	return !t.Query.Block
+}
+
+func (b *introBuilder) isColumnBlocked(ti *psql.DBTableInfo, name string) bool {
+	if _, ok := b.bl[name]; ok {
+		return true
+	}
+
+	for _, t := range conf.Tables {
+		if !strings.EqualFold(t.Name, ti.Name) {
+			continue
+		}
+		for _, v := range t.Blocklist {
+			if strings.EqualFold(v, name) {
+				return true
 			}
-		},
-		"extensions":{  
-			"tracing":{  
-				"version":1,
-				"startTime":"2019-06-04T19:53:31.093Z",
-				"endTime":"2019-06-04T19:53:31.108Z",
-				"duration":15219720,
-				"execution": {
-					"resolvers": [{
-						"path": ["__schema"],
-						"parentType":	"Query",
-						"fieldName": "__schema",
-						"returnType":	"__Schema!",
-						"startOffset": 50950,
-						"duration": 17187
-					}]
-				}
+		}
+	}
+
+	return false
+}
+
+// columns returns the columns of a table visible to the role, an empty
+// list of columns in the role config means all columns are allowed. Computed
+// columns can only be queried so they are only included when computed is set.
+func (b *introBuilder) columns(ti *psql.DBTableInfo, allowed []string, computed bool) []*psql.DBColumn {
+	am := make(map[string]struct{}, len(allowed))
+	for _, v := range allowed {
+		am[strings.ToLower(v)] = struct{}{}
+	}
+
+	all := make([]*psql.DBColumn, 0, len(ti.ColMap))
+
+	for i := range ti.Columns {
+		all = append(all, &ti.Columns[i])
+	}
+
+	if computed {
+		all = append(all, computedColumns(ti)...)
+	}
+
+	cols := make([]*psql.DBColumn, 0, len(all))
+
+	for _, c := range all {
+
+		if b.isColumnBlocked(ti, c.Key) {
+			continue
+		}
+
+		if len(am) != 0 {
+			if _, ok := am[c.Key]; !ok {
+				continue
+			}
+		}
+		cols = append(cols, c)
+	}
+
+	return cols
+}
+
+func (b *introBuilder) queryColumns(ti *psql.DBTableInfo) ([]*psql.DBColumn, bool) {
+	if t := b.roleTable(ti.Name); t != nil {
+		return b.columns(ti, t.Query.Columns, true), !t.Query.DisableFunctions
+	}
+	return b.columns(ti, nil, true), true
+}
+
+// computedColumns returns the columns added to the table with an sql
+// expression or a function, they are only in the column map of the table
+func computedColumns(ti *psql.DBTableInfo) []*psql.DBColumn {
+	var cols []*psql.DBColumn
+
+	for _, c := range ti.ColMap {
+		if c.IsComputed() {
+			cols = append(cols, c)
+		}
+	}
+
+	sort.Slice(cols, func(i, j int) bool { return cols[i].Key < cols[j].Key })
+
+	return cols
+}
+
+func (b *introBuilder) addTableTypes(ti *psql.DBTableInfo) {
+	if _, ok := b.tm[ti.Name]; ok {
+		return
+	}
+
+	ot := &introType{Kind: kindObject, Name: ti.Name, Interfaces: []introName{}}
+	b.addType(ot)
+
+	wt := &introType{Kind: kindInputObject, Name: ti.Name + "Expression"}
+	b.addType(wt)
+
+	obt := &introType{Kind: kindInputObject, Name: ti.Name + "OrderBy"}
+	b.addType(obt)
+
+	for _, v := range []string{"and", "or"} {
+		wt.InputFields = append(wt.InputFields, introInputValue{
+			Name: v,
+			Type: listOf(nonNull(named(kindInputObject, wt.Name))),
+		})
+	}
+	wt.InputFields = append(wt.InputFields, introInputValue{
+		Name: "not",
+		Type: named(kindInputObject, wt.Name),
+	})
+
+	cols, funcs := b.queryColumns(ti)
+
+	for _, c := range cols {
+		ct := columnType(c)
+
+		if c.NotNull {
+			ct = nonNull(ct)
+		}
+		ot.Fields = append(ot.Fields, introField{
+			Name: c.Key,
+			Args: []introInputValue{},
+			Type: ct,
+		})
+
+		wt.InputFields = append(wt.InputFields, introInputValue{
+			Name: c.Key,
+			Type: named(kindInputObject, b.expressionType(c)),
+		})
+
+		obt.InputFields = append(obt.InputFields, introInputValue{
+			Name: c.Key,
+			Type: named(kindEnum, "OrderDirection"),
+		})
+	}
+
+	if funcs {
+		for _, c := range cols {
+			ot.Fields = append(ot.Fields, introField{
+				Name: "count_" + c.Key,
+				Args: []introInputValue{},
+				Type: named(kindScalar, "Int"),
+			})
+
+			if columnScalar(c) != "Int" && columnScalar(c) != "Float" {
+				continue
 			}
+
+			for _, fn := range introAggFuncs {
+				ot.Fields = append(ot.Fields, introField{
+					Name: fn + "_" + c.Key,
+					Args: []introInputValue{},
+					Type: named(kindScalar, "Float"),
+				})
+			}
+		}
+	}
+
+	if ti.TSVCol != nil {
+		ot.Fields = append(ot.Fields, introField{
+			Name: "search_rank",
+			Args: []introInputValue{},
+			Type: named(kindScalar, "Float"),
+		})
+
+		for _, c := range cols {
+			if columnScalar(c) != "String" {
+				continue
+			}
+			ot.Fields = append(ot.Fields, introField{
+				Name: "search_headline_" + c.Key,
+				Args: []introInputValue{},
+				Type: named(kindScalar, "String"),
+			})
+		}
+	}
+
+	// Related tables are exposed as fields on this type
+	for _, name := range schema.GetTableNames() {
+		cti, err := schema.GetTable(name)
+		if err != nil || !b.isTableAllowed(name, cti) {
+			continue
+		}
+
+		if _, err := schema.GetRel(name, ti.Name); err != nil {
+			continue
+		}
+
+		b.addTableTypes(cti)
+
+		ot.Fields = append(ot.Fields, introField{
+			Name: name,
+			Args: b.queryArgs(cti),
+			Type: b.tableRef(cti),
+		})
+
+		wt.InputFields = append(wt.InputFields, introInputValue{
+			Name: name,
+			Type: named(kindInputObject, cti.Name+"Expression"),
+		})
+	}
+
+	// Remote joins are returned as is
+	for _, t := range conf.Tables {
+		if !strings.EqualFold(t.Name, ti.Name) {
+			continue
+		}
+		for _, r := range t.Remotes {
+			ot.Fields = append(ot.Fields, introField{
+				Name: strings.ToLower(r.Name),
+				Args: []introInputValue{},
+				Type: named(kindScalar, "JSON"),
+			})
+		}
+	}
+
+	ot.Fields = append(ot.Fields, introField{
+		Name: "__typename",
+		Args: []introInputValue{},
+		Type: nonNull(named(kindScalar, "String")),
+	})
+}
+
+func (b *introBuilder) expressionType(c *psql.DBColumn) string {
+	sc := columnScalar(c)
+	name := sc + "Expression"
+
+	if _, ok := b.tm[name]; ok {
+		return name
+	}
+
+	et := &introType{Kind: kindInputObject, Name: name}
+
+	for _, op := range introCompareOps {
+		et.InputFields = append(et.InputFields, introInputValue{
+			Name: op, Type: named(kindScalar, sc)})
+	}
+
+	for _, op := range introListOps {
+		et.InputFields = append(et.InputFields, introInputValue{
+			Name: op, Type: listOf(nonNull(named(kindScalar, sc)))})
+	}
+
+	et.InputFields = append(et.InputFields, introInputValue{
+		Name: "is_null", Type: named(kindScalar, "Boolean")})
+
+	for _, op := range []string{"distinct", "not_distinct"} {
+		et.InputFields = append(et.InputFields, introInputValue{
+			Name: op, Type: named(kindScalar, sc)})
+	}
+
+	switch sc {
+	case "String":
+		for _, op := range introTextOps {
+			et.InputFields = append(et.InputFields, introInputValue{
+				Name: op, Type: named(kindScalar, sc)})
+		}
+
+	case "JSON":
+		for _, op := range introJSONOps {
+			et.InputFields = append(et.InputFields, introInputValue{
+				Name: op, Type: named(kindScalar, sc)})
+		}
+
+		for _, op := range introJSONPathOps {
+			et.InputFields = append(et.InputFields, introInputValue{
+				Name: op, Type: named(kindScalar, "String")})
+		}
+	}
+
+	b.addType(et)
+	return name
+}
+
+// connectionType adds the types of the Relay connection on the table
+func (b *introBuilder) connectionType(ti *psql.DBTableInfo) string {
+	name := ti.Name + "Connection"
+
+	if _, ok := b.tm[name]; ok {
+		return name
+	}
+
+	b.addType(&introType{Kind: kindObject, Name: "PageInfo", Interfaces: []introName{}, Fields: []introField{
+		{Name: "hasNextPage", Args: []introInputValue{}, Type: nonNull(named(kindScalar, "Boolean"))},
+		{Name: "hasPreviousPage", Args: []introInputValue{}, Type: nonNull(named(kindScalar, "Boolean"))},
+		{Name: "startCursor", Args: []introInputValue{}, Type: named(kindScalar, "String")},
+		{Name: "endCursor", Args: []introInputValue{}, Type: named(kindScalar, "String")},
+	}})
+
+	b.addType(&introType{Kind: kindObject, Name: ti.Name + "Edge", Interfaces: []introName{}, Fields: []introField{
+		{Name: "cursor", Args: []introInputValue{}, Type: nonNull(named(kindScalar, "String"))},
+		{Name: "node", Args: []introInputValue{}, Type: nonNull(named(kindObject, ti.Name))},
+	}})
+
+	b.addType(&introType{Kind: kindObject, Name: name, Interfaces: []introName{}, Fields: []introField{
+		{Name: "edges", Args: []introInputValue{},
+			Type: nonNull(listOf(nonNull(named(kindObject, ti.Name+"Edge"))))},
+		{Name: "pageInfo", Args: []introInputValue{}, Type: nonNull(named(kindObject, "PageInfo"))},
+		{Name: "totalCount", Args: []introInputValue{}, Type: nonNull(named(kindScalar, "Int"))},
+	}})
+
+	return name
+}
+
+func (b *introBuilder) tableRef(ti *psql.DBTableInfo) *introTypeRef {
+	if ti.Singular {
+		return named(kindObject, ti.Name)
+	}
+	return nonNull(listOf(nonNull(named(kindObject, ti.Name))))
+}
+
+func (b *introBuilder) queryArgs(ti *psql.DBTableInfo) []introInputValue {
+	args := []introInputValue{
+		{Name: "id", Type: named(kindScalar, "ID")},
+		{Name: "where", Type: named(kindInputObject, ti.Name+"Expression")},
+		{Name: "order_by", Type: named(kindInputObject, ti.Name+"OrderBy")},
+		{Name: "distinct_on", Type: listOf(nonNull(named(kindScalar, "String")))},
+	}
+
+	if !ti.Singular {
+		args = append(args, []introInputValue{
+			{Name: "limit", Type: named(kindScalar, "Int")},
+			{Name: "offset", Type: named(kindScalar, "Int")},
+			{Name: "first", Type: named(kindScalar, "Int")},
+			{Name: "last", Type: named(kindScalar, "Int")},
+			{Name: "after", Type: named(kindScalar, "String")},
+			{Name: "before", Type: named(kindScalar, "String")},
+		}...)
+	}
+
+	if ti.TSVCol != nil {
+		args = append(args, introInputValue{
+			Name: "search", Type: named(kindScalar, "String")})
+	}
+
+	if ti.Func != nil {
+		args = append(args, funcArgs(ti.Func, args)...)
+	}
+
+	return args
+}
+
+// funcArgs returns the parameters of the function as arguments, skipping
+// the ones named like the arguments of the table
+func funcArgs(fn *psql.DBFunction, args []introInputValue) []introInputValue {
+	names := make(map[string]struct{}, len(args))
+
+	for _, a := range args {
+		names[a.Name] = struct{}{}
+	}
+
+	var fargs []introInputValue
+
+	for _, p := range fn.Params {
+		if _, ok := names[p.Name]; ok {
+			continue
 		}
-	}`))
+
+		c := &psql.DBColumn{Type: p.Type, Array: strings.HasSuffix(p.Type, "[]")}
+		fargs = append(fargs, introInputValue{Name: p.Name, Type: columnType(c)})
+	}
+
+	return fargs
+}
+
+func (b *introBuilder) mutationArgs(name string, ti *psql.DBTableInfo) []introInputValue {
+	var insCols, updCols []string
+	var insBlock, updBlock, delBlock bool
+
+	t := b.roleTable(name)
+	if t == nil {
+		t = b.roleTable(ti.Name)
+	}
+
+	if t != nil {
+		insCols, insBlock = t.Insert.Columns, t.Insert.Block
+		updCols, updBlock = t.Update.Columns, t.Update.Block
+		delBlock = t.Delete.Block
+	}
+
+	var args []introInputValue
+
+	if !insBlock {
+		it := b.inputType(ti, ti.Name+"InsertInput", insCols)
+		args = append(args,
+			introInputValue{Name: "insert", Type: named(kindInputObject, it)},
+			introInputValue{Name: "upsert", Type: named(kindInputObject, it)})
+	}
+
+	if !updBlock {
+		it := b.inputType(ti, ti.Name+"UpdateInput", updCols)
+		args = append(args,
+			introInputValue{Name: "update", Type: named(kindInputObject, it)})
+	}
+
+	if !delBlock {
+		args = append(args,
+			introInputValue{Name: "delete", Type: named(kindScalar, "Boolean")})
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	return append(args,
+		introInputValue{Name: "id", Type: named(kindScalar, "ID")},
+		introInputValue{Name: "where", Type: named(kindInputObject, ti.Name+"Expression")})
+}
+
+func (b *introBuilder) inputType(ti *psql.DBTableInfo, name string, allowed []string) string {
+	if _, ok := b.tm[name]; ok {
+		return name
+	}
+
+	it := &introType{Kind: kindInputObject, Name: name, InputFields: []introInputValue{}}
+
+	for _, c := range b.columns(ti, allowed, false) {
+		it.InputFields = append(it.InputFields, introInputValue{
+			Name: c.Key,
+			Type: columnType(c),
+		})
+	}
+
+	b.addType(it)
+	return name
+}
+
+func introDirectives() []introDirective {
+	locations := []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"}
+
+	return []introDirective{
+		{
+			Name:        "skip",
+			Description: "Directs the executor to skip this field when the `if` argument is true.",
+			Locations:   locations,
+			Args: []introInputValue{
+				{Name: "if", Type: nonNull(named(kindScalar, "Boolean"))},
+			},
+		},
+		{
+			Name:        "include",
+			Description: "Directs the executor to include this field only when the `if` argument is true.",
+			Locations:   locations,
+			Args: []introInputValue{
+				{Name: "if", Type: nonNull(named(kindScalar, "Boolean"))},
+			},
+		},
+	}
+}
+
+func columnType(c *psql.DBColumn) *introTypeRef {
+	ct := named(kindScalar, columnScalar(c))
+
+	if c.Array {
+		ct = listOf(ct)
+	}
+	return ct
+}
+
+// columnScalar maps a Postgres column type to a GraphQL scalar
+func columnScalar(c *psql.DBColumn) string {
+	t := strings.TrimSuffix(c.Type, "[]")
+
+	if n := strings.IndexByte(t, '('); n != -1 {
+		t = t[:n]
+	}
+
+	switch t {
+	case "smallint", "integer", "bigint", "int", "int2", "int4", "int8",
+		"smallserial", "serial", "bigserial":
+		return "Int"
+
+	case "numeric", "decimal", "real", "double precision", "float4", "float8", "money":
+		return "Float"
+
+	case "boolean", "bool":
+		return "Boolean"
+
+	case "json", "jsonb":
+		return "JSON"
+	}
+
+	return "String"
+}
+
+func named(kind, name string) *introTypeRef {
+	return &introTypeRef{Kind: kind, Name: &name}
+}
+
+func listOf(t *introTypeRef) *introTypeRef {
+	return &introTypeRef{Kind: kindList, OfType: t}
+}
+
+func nonNull(t *introTypeRef) *introTypeRef {
+	return &introTypeRef{Kind: kindNonNull, OfType: t}
 }
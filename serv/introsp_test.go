@@ -0,0 +1,196 @@
+package serv
+
+import (
+	"testing"
+
+	"github.com/dosco/super-graph/psql"
+)
+
+func initIntrospectionTest(t *testing.T) {
+	di := &psql.DBInfo{
+		Tables: []psql.DBTable{
+			{Name: "users", Key: "users", Type: "table"},
+			{Name: "products", Key: "products", Type: "table"},
+		},
+		Columns: [][]psql.DBColumn{
+			{
+				{ID: 1, Name: "id", Key: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+				{ID: 2, Name: "email", Key: "email", Type: "character varying", NotNull: true},
+				{ID: 3, Name: "encrypted_password", Key: "encrypted_password", Type: "character varying"},
+			},
+			{
+				{ID: 1, Name: "id", Key: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+				{ID: 2, Name: "name", Key: "name", Type: "text"},
+				{ID: 3, Name: "price", Key: "price", Type: "numeric(7,2)"},
+				{ID: 4, Name: "user_id", Key: "user_id", Type: "bigint", FKeyTable: "users", FKeyColID: []int16{1}},
+			},
+		},
+		Functions: []psql.DBFunction{
+			{Name: "search_products", Key: "search_products", Table: "products", Params: []psql.DBFuncParam{
+				{Name: "q", Type: "text"},
+				{Name: "max_price", Type: "numeric"},
+				{Name: "limit", Type: "integer"},
+			}},
+		},
+	}
+
+	var err error
+
+	if schema, err = psql.NewDBSchema(di, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	anon := &configRole{Name: "anon", Tables: []configRoleTable{
+		{Name: "products", Query: configQuery{Columns: []string{"id", "name"}, DisableFunctions: true}},
+	}}
+	anon.tablesMap = map[string]*configRoleTable{"products": &anon.Tables[0]}
+
+	user := &configRole{Name: "user", tablesMap: map[string]*configRoleTable{}}
+
+	conf = &config{roles: map[string]*configRole{"anon": anon, "user": user}}
+	conf.DB.Blocklist = []string{"encrypted_password"}
+}
+
+func findIntroType(sc *introSchema, name string) *introType {
+	for _, t := range sc.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func hasIntroField(t *introType, name string) bool {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIntrospectionUser(t *testing.T) {
+	initIntrospectionTest(t)
+
+	sc := buildIntrospection("user")
+
+	if sc.QueryType == nil || sc.MutationType == nil {
+		t.Fatal("query and mutation types expected")
+	}
+
+	query := findIntroType(sc, "Query")
+	for _, v := range []string{"user", "users", "product", "products", "products_connection"} {
+		if !hasIntroField(query, v) {
+			t.Fatalf("field '%s' missing on type Query", v)
+		}
+	}
+
+	users := findIntroType(sc, "users")
+	if users == nil {
+		t.Fatal("type 'users' missing")
+	}
+
+	if hasIntroField(users, "encrypted_password") {
+		t.Fatal("blocked column 'encrypted_password' found")
+	}
+
+	if !hasIntroField(users, "products") {
+		t.Fatal("relationship 'products' missing on type 'users'")
+	}
+
+	for _, f := range users.Fields {
+		if f.Name == "email" && f.Type.Kind != kindNonNull {
+			t.Fatal("column 'email' should be non-null")
+		}
+	}
+
+	if !hasIntroField(findIntroType(sc, "products"), "sum_price") {
+		t.Fatal("aggregate 'sum_price' missing on type 'products'")
+	}
+
+	if !hasIntroField(findIntroType(sc, "productsEdge"), "node") ||
+		!hasIntroField(findIntroType(sc, "PageInfo"), "hasNextPage") {
+		t.Fatal("relay connection types missing for 'products'")
+	}
+}
+
+func TestIntrospectionAnon(t *testing.T) {
+	initIntrospectionTest(t)
+
+	sc := buildIntrospection("anon")
+	query := findIntroType(sc, "Query")
+
+	if hasIntroField(query, "users") {
+		t.Fatal("table 'users' is not defined for role anon")
+	}
+
+	products := findIntroType(sc, "products")
+	if products == nil {
+		t.Fatal("type 'products' missing")
+	}
+
+	if hasIntroField(products, "price") || hasIntroField(products, "count_id") {
+		t.Fatal("only columns 'id' and 'name' allowed for role anon")
+	}
+
+	if !hasIntroField(products, "name") {
+		t.Fatal("column 'name' missing on type 'products'")
+	}
+}
+
+func TestIntrospectionComputedColumn(t *testing.T) {
+	initIntrospectionTest(t)
+
+	col := psql.DBColumn{Name: "full_name", Key: "full_name", Type: "text", Expr: `"users"."email"`}
+	if err := schema.AddComputedColumn("users", col); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := buildIntrospection("user")
+
+	if !hasIntroField(findIntroType(sc, "users"), "full_name") {
+		t.Fatal("computed column 'full_name' missing on type 'users'")
+	}
+
+	for _, f := range findIntroType(sc, "usersInsertInput").InputFields {
+		if f.Name == "full_name" {
+			t.Fatal("computed column 'full_name' cannot be inserted")
+		}
+	}
+}
+
+func TestIntrospectionFunction(t *testing.T) {
+	initIntrospectionTest(t)
+
+	sc := buildIntrospection("user")
+
+	if hasIntroField(findIntroType(sc, "Mutation"), "search_products") {
+		t.Fatal("function 'search_products' cannot be a mutation")
+	}
+
+	var args []introInputValue
+
+	for _, f := range findIntroType(sc, "Query").Fields {
+		if f.Name == "search_products" {
+			args = f.Args
+		}
+	}
+
+	found := make(map[string]string)
+
+	for _, a := range args {
+		if a.Type.Name != nil {
+			found[a.Name] = *a.Type.Name
+		} else {
+			found[a.Name] = ""
+		}
+	}
+
+	if found["q"] != "String" || found["max_price"] != "Float" || found["limit"] != "Int" {
+		t.Fatalf("expected the parameters of 'search_products' as arguments: %v", found)
+	}
+
+	if len(found) != len(args) {
+		t.Fatal("parameters named like an argument should not be repeated")
+	}
+}
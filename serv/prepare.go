@@ -3,8 +3,11 @@ package serv
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/dosco/super-graph/allow"
 	"github.com/dosco/super-graph/psql"
@@ -21,8 +24,14 @@ type preparedItem struct {
 	roleArg bool
 }
 
+const maxDirectiveVars = 5
+
 var (
 	_preparedList map[string]*preparedItem
+
+	// variables used by @skip and @include in each query, keyed
+	// by the lowercase query name
+	_preparedVariants map[string][]string
 )
 
 func initPreparedList(cpath string) {
@@ -30,6 +39,7 @@ func initPreparedList(cpath string) {
 		return
 	}
 	_preparedList = make(map[string]*preparedItem)
+	_preparedVariants = make(map[string][]string)
 
 	tx, err := db.Begin(context.Background())
 	if err != nil {
@@ -77,9 +87,72 @@ func initPreparedList(cpath string) {
 }
 
 func prepareStmt(item allow.Item) error {
-	gql := item.Query
-	vars := item.Vars
+	dvars, err := qcode.DirectiveVars([]byte(item.Query), "")
+	if err != nil {
+		return err
+	}
+
+	if len(dvars) == 0 {
+		return prepareStmtVars(item.Name, item.Query, item.Vars)
+	}
+
+	if len(dvars) > maxDirectiveVars {
+		return fmt.Errorf("too many variables used with @skip and @include (max %d)", maxDirectiveVars)
+	}
+
+	vm := make(map[string]json.RawMessage)
+
+	if len(item.Vars) != 0 {
+		if err := json.Unmarshal(item.Vars, &vm); err != nil {
+			return err
+		}
+	}
+
+	// allow-listed queries are not recompiled per request so a statement
+	// is prepared for every combination of the directive variables
+	for n := 0; n < (1 << uint(len(dvars))); n++ {
+		for i, v := range dvars {
+			if n&(1<<uint(i)) != 0 {
+				vm[v] = json.RawMessage(`true`)
+			} else {
+				vm[v] = json.RawMessage(`false`)
+			}
+		}
+
+		vars, err := json.Marshal(vm)
+		if err != nil {
+			return err
+		}
+
+		err = prepareStmtVars(item.Name+variantKey(dvars, vm), item.Query, vars)
+		if err != nil {
+			return err
+		}
+	}
+
+	_preparedVariants[strings.ToLower(item.Name)] = dvars
+
+	return nil
+}
+
+// variantKey returns the suffix added to the name of the query for the
+// statement prepared for these values of the directive variables
+func variantKey(dvars []string, vars map[string]json.RawMessage) string {
+	b := make([]byte, 0, len(dvars)+1)
+	b = append(b, '@')
+
+	for _, v := range dvars {
+		if qcode.BoolVar(vars, v) {
+			b = append(b, '1')
+		} else {
+			b = append(b, '0')
+		}
+	}
+
+	return string(b)
+}
 
+func prepareStmtVars(name, gql string, vars []byte) error {
 	qt := qcode.GetQType(gql)
 	q := []byte(gql)
 
@@ -101,9 +174,9 @@ func prepareStmt(item allow.Item) error {
 		var err error
 
 		if conf.isABACEnabled() {
-			stmts1, err = buildMultiStmt(q, vars)
+			stmts1, err = buildMultiStmt(q, "", vars)
 		} else {
-			stmts1, err = buildRoleStmt(q, vars, "user")
+			stmts1, err = buildRoleStmt(q, "", vars, "user")
 		}
 
 		if err != nil {
@@ -112,7 +185,7 @@ func prepareStmt(item allow.Item) error {
 
 		logger.Debug().Msg("Prepared statement role: user")
 
-		err = prepare(tx, stmts1, stmtHash(item.Name, "user"))
+		err = prepare(tx, stmts1, stmtHash(name, "user"))
 		if err != nil {
 			return err
 		}
@@ -120,15 +193,15 @@ func prepareStmt(item allow.Item) error {
 		if conf.isAnonRoleDefined() {
 			logger.Debug().Msg("Prepared statement for role: anon")
 
-			stmts2, err := buildRoleStmt(q, vars, "anon")
-			if err == psql.ErrAllTablesSkipped {
+			stmts2, err := buildRoleStmt(q, "", vars, "anon")
+			if errors.Is(err, psql.ErrAllTablesSkipped) {
 				return nil
 			}
 			if err != nil {
 				return err
 			}
 
-			err = prepare(tx, stmts2, stmtHash(item.Name, "anon"))
+			err = prepare(tx, stmts2, stmtHash(name, "anon"))
 			if err != nil {
 				return err
 			}
@@ -138,12 +211,12 @@ func prepareStmt(item allow.Item) error {
 		for _, role := range conf.Roles {
 			logger.Debug().Msgf("Prepared statement for role: %s", role.Name)
 
-			stmts, err := buildRoleStmt(q, vars, role.Name)
+			stmts, err := buildRoleStmt(q, "", vars, role.Name)
 			if err != nil {
 				return err
 			}
 
-			err = prepare(tx, stmts, stmtHash(item.Name, role.Name))
+			err = prepare(tx, stmts, stmtHash(name, role.Name))
 			if err != nil {
 				return err
 			}
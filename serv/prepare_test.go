@@ -0,0 +1,43 @@
+package serv
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dosco/super-graph/psql"
+	"github.com/dosco/super-graph/qcode"
+)
+
+func TestVariantKey(t *testing.T) {
+	dvars := []string{"withemail", "withproducts"}
+
+	vars := map[string]json.RawMessage{
+		"withEmail":    json.RawMessage(`true`),
+		"withProducts": json.RawMessage(`false`),
+	}
+
+	if k := variantKey(dvars, vars); k != "@10" {
+		t.Fatalf("expected variant '@10' got '%s'", k)
+	}
+
+	if k := variantKey(dvars, nil); k != "@00" {
+		t.Fatalf("expected variant '@00' got '%s'", k)
+	}
+}
+
+func TestPrepareAllTablesSkipped(t *testing.T) {
+	initIntrospectionTest(t)
+
+	qc, pc := qcompile, pcompile
+	defer func() { qcompile, pcompile = qc, pc }()
+
+	qcompile, _ = qcode.NewCompiler(qcode.Config{})
+	pcompile = psql.NewCompiler(psql.Config{Schema: schema})
+
+	_, err := buildRoleStmt([]byte(`query { users { id } }`), "", nil, "anon")
+
+	if !errors.Is(err, psql.ErrAllTablesSkipped) {
+		t.Fatalf("expected the query to be skipped for the role got: %v", err)
+	}
+}
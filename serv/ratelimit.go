@@ -0,0 +1,215 @@
+package serv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rateSweepInterval = time.Minute
+
+// rateLimiter holds the token buckets, it can be replaced with a store
+// shared by several instances of the server
+var rateLimiter rateStore = newMemRateStore()
+
+type rateLimit struct {
+	rate  float64 // tokens added per second
+	burst float64 // max tokens in the bucket
+}
+
+// rateStore is a store of token buckets
+type rateStore interface {
+	// Take removes a token from the bucket for key. If the bucket is empty
+	// it returns false and how long till the next token is added.
+	Take(key string, lim rateLimit) (bool, time.Duration)
+}
+
+type rateLimitedErr struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitedErr) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %d seconds", retryAfterSecs(e.retryAfter))
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	lim    rateLimit
+}
+
+type memRateStore struct {
+	sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+func newMemRateStore() *memRateStore {
+	return &memRateStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (s *memRateStore) Take(key string, lim rateLimit) (bool, time.Duration) {
+	now := s.now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if now.Sub(s.swept) > rateSweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if ok {
+		b.lim = lim
+		b.refill(now)
+	} else {
+		b = &bucket{tokens: lim.burst, last: now, lim: lim}
+		s.buckets[key] = b
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / lim.rate * float64(time.Second)
+	return false, time.Duration(wait)
+}
+
+// sweep removes the buckets that have filled up again since they are
+// the same as new ones
+func (s *memRateStore) sweep(now time.Time) {
+	for k, b := range s.buckets {
+		if b.refill(now); b.tokens >= b.lim.burst {
+			delete(s.buckets, k)
+		}
+	}
+	s.swept = now
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.lim.burst, b.tokens+now.Sub(b.last).Seconds()*b.lim.rate)
+	b.last = now
+}
+
+func newRateLimit(rate float64, burst int) (rateLimit, bool) {
+	if rate <= 0 {
+		return rateLimit{}, false
+	}
+
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+
+	return rateLimit{rate: rate, burst: float64(burst)}, true
+}
+
+// checkRateLimit takes a token from the bucket of the role and if one is
+// configured from the bucket for the query or action named name
+func checkRateLimit(r *http.Request, name string) error {
+	role := requestRole(r.Context())
+
+	rc, ok := conf.roles[role]
+	if !ok {
+		return nil
+	}
+
+	from := rateLimitKey(r)
+
+	if lim, ok := newRateLimit(rc.RateLimit.Rate, rc.RateLimit.Burst); ok {
+		if ok, wait := rateLimiter.Take(role+":"+from, lim); !ok {
+			return &queryErr{code: errCodeRateLimited, err: &rateLimitedErr{wait}}
+		}
+	}
+
+	if len(name) == 0 {
+		return nil
+	}
+
+	for _, q := range rc.RateLimit.Queries {
+		if !strings.EqualFold(q.Name, name) {
+			continue
+		}
+
+		if lim, ok := newRateLimit(q.Rate, q.Burst); ok {
+			key := role + ":" + strings.ToLower(name) + ":" + from
+
+			if ok, wait := rateLimiter.Take(key, lim); !ok {
+				return &queryErr{code: errCodeRateLimited, err: &rateLimitedErr{wait}}
+			}
+		}
+		break
+	}
+
+	return nil
+}
+
+// requestRole returns the role of the user making the request. Roles that
+// are resolved using the roles_query need the database so authenticated
+// users get the 'user' role here.
+func requestRole(ctx context.Context) string {
+	if v := ctx.Value(userRoleKey); v != nil {
+		return v.(string)
+	}
+
+	if ctx.Value(userIDKey) != nil {
+		return "user"
+	}
+	return "anon"
+}
+
+// rateLimitKey returns the user id for authenticated users and the
+// client ip for anonymous users
+func rateLimitKey(r *http.Request) string {
+	if v := r.Context().Value(userIDKey); v != nil {
+		return fmt.Sprintf("u:%v", v)
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+// rateLimitResp writes a 429 response with the seconds to wait
+// before retrying in the Retry-After header
+func rateLimitResp(w http.ResponseWriter, err error) {
+	var rerr *rateLimitedErr
+
+	if errors.As(err, &rerr) {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSecs(rerr.retryAfter)))
+	}
+
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(gqlResp{Errors: gqlErrors(err)}) //nolint: errcheck
+}
+
+// rateLimitHandler applies the rate limits of the role to an action
+func rateLimitHandler(h http.Handler, name string) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if err := checkRateLimit(r, name); err != nil {
+			rateLimitResp(w, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func retryAfterSecs(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,98 @@
+package serv
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemRateStore(t *testing.T) {
+	now := time.Now()
+
+	s := newMemRateStore()
+	s.now = func() time.Time { return now }
+
+	lim, _ := newRateLimit(2, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := s.Take("a", lim); !ok {
+			t.Fatalf("request %d should be allowed by the burst", i)
+		}
+	}
+
+	ok, wait := s.Take("a", lim)
+	if ok {
+		t.Fatal("expected the bucket to be empty")
+	}
+
+	if wait != 500*time.Millisecond {
+		t.Fatalf("expected a wait of 500ms got %s", wait)
+	}
+
+	if ok, _ := s.Take("b", lim); !ok {
+		t.Fatal("buckets should be separate for each key")
+	}
+
+	now = now.Add(wait)
+
+	if ok, _ := s.Take("a", lim); !ok {
+		t.Fatal("expected the bucket to be refilled")
+	}
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	anon := &configRole{Name: "anon"}
+	anon.RateLimit.Rate = 1
+	anon.RateLimit.Queries = []configQueryRateLimit{
+		{Name: "getProducts", Rate: 0.1, Burst: 1},
+	}
+
+	conf = &config{roles: map[string]*configRole{"anon": anon}}
+	rateLimiter = newMemRateStore()
+
+	defer func() {
+		conf = &config{}
+		rateLimiter = newMemRateStore()
+	}()
+
+	r := httptest.NewRequest("POST", "/api/v1/graphql", nil)
+
+	if err := checkRateLimit(r, "getProducts"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := checkRateLimit(r, "getProducts")
+	if err == nil {
+		t.Fatal("expected the request to be rate limited")
+	}
+
+	w := httptest.NewRecorder()
+	rateLimitResp(w, err)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 got %d", w.Code)
+	}
+
+	if v := w.Header().Get("Retry-After"); v != "1" {
+		t.Fatalf("expected Retry-After of 1 got '%s'", v)
+	}
+
+	// authenticated users are limited separately from the client ip
+	ctx := context.WithValue(r.Context(), userIDKey, 1)
+	ctx = context.WithValue(ctx, userRoleKey, "anon")
+
+	if err := checkRateLimit(r.WithContext(ctx), "getProducts"); err != nil {
+		t.Fatal(err)
+	}
+
+	// roles without a rate limit are not limited
+	r = r.WithContext(context.WithValue(r.Context(), userIDKey, 1))
+
+	for i := 0; i < 5; i++ {
+		if err := checkRateLimit(r, "getProducts"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
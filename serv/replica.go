@@ -0,0 +1,223 @@
+package serv
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dosco/super-graph/qcode"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	replicaCheckInterval = 10 * time.Second
+	writeSweepInterval   = time.Minute
+)
+
+var (
+	replicas *replicaSet // read replicas, nil when none are configured
+	writes   = newWriteLog()
+)
+
+type replica struct {
+	name    string
+	config  *pgxpool.Config
+	pool    *pgxpool.Pool
+	healthy bool
+}
+
+// replicaSet balances queries across the read replicas that passed
+// the last health check
+type replicaSet struct {
+	sync.RWMutex
+	replicas []*replica
+	next     uint32
+}
+
+func initReplicas(c *config) *replicaSet {
+	if len(c.DB.Replicas) == 0 {
+		return nil
+	}
+
+	rs := &replicaSet{}
+
+	for _, r := range c.DB.Replicas {
+		config := dbPoolConfig(c)
+
+		if len(r.Host) != 0 {
+			config.ConnConfig.Host = r.Host
+		}
+		if r.Port != 0 {
+			config.ConnConfig.Port = r.Port
+		}
+		if len(r.DBName) != 0 {
+			config.ConnConfig.Database = r.DBName
+		}
+		if len(r.User) != 0 {
+			config.ConnConfig.User = r.User
+		}
+		if len(r.Password) != 0 {
+			config.ConnConfig.Password = r.Password
+		}
+		if r.PoolSize != 0 {
+			config.MaxConns = r.PoolSize
+		}
+
+		name := fmt.Sprintf("%s:%d", config.ConnConfig.Host, config.ConnConfig.Port)
+		rs.replicas = append(rs.replicas, &replica{name: name, config: config})
+	}
+
+	rs.check()
+	go rs.watch()
+
+	return rs
+}
+
+// pick returns the pool of the next healthy replica or nil if
+// none of them are healthy
+func (rs *replicaSet) pick() *pgxpool.Pool {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	n := uint32(len(rs.replicas))
+	start := atomic.AddUint32(&rs.next, 1)
+
+	for i := uint32(0); i < n; i++ {
+		if r := rs.replicas[(start+i)%n]; r.healthy {
+			return r.pool
+		}
+	}
+
+	return nil
+}
+
+func (rs *replicaSet) watch() {
+	for range time.Tick(replicaCheckInterval) {
+		rs.check()
+	}
+}
+
+// check connects to the replicas that are not connected yet and pings
+// the rest, replicas are only used for queries while they respond
+func (rs *replicaSet) check() {
+	for _, r := range rs.replicas {
+		pool, err := r.ping()
+
+		rs.Lock()
+		if r.healthy != (err == nil) {
+			if err != nil {
+				errlog.Error().Err(err).Str("replica", r.name).Msg("read replica is down")
+			} else {
+				logger.Info().Str("replica", r.name).Msg("read replica is up")
+			}
+		}
+		r.pool = pool
+		r.healthy = (err == nil)
+		rs.Unlock()
+	}
+}
+
+// ping returns the pool of the replica once it's connected and has
+// responded to a ping
+func (r *replica) ping() (*pgxpool.Pool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaCheckInterval)
+	defer cancel()
+
+	pool := r.pool
+
+	if pool == nil {
+		var err error
+
+		if pool, err = pgxpool.ConnectConfig(ctx, r.config); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return pool, err
+	}
+	defer conn.Release()
+
+	return pool, conn.Conn().Ping(ctx)
+}
+
+// queryPool returns the pool to run the operation on, queries go to a
+// healthy replica unless the user made a mutation recently enough that
+// the replicas might not have it yet
+func (c *coreContext) queryPool(qt qcode.QType) *pgxpool.Pool {
+	if qt != qcode.QTQuery || replicas == nil {
+		return db
+	}
+
+	if conf.DB.ReadYourWrites != 0 {
+		if v := c.Value(userIDKey); v != nil && writes.recent(v.(string), conf.DB.ReadYourWrites) {
+			return db
+		}
+	}
+
+	if pool := replicas.pick(); pool != nil {
+		return pool
+	}
+
+	return db
+}
+
+// addWrite records the time of the mutation made by the user
+func (c *coreContext) addWrite(qt qcode.QType) {
+	if qt != qcode.QTMutation || replicas == nil || conf.DB.ReadYourWrites == 0 {
+		return
+	}
+
+	if v := c.Value(userIDKey); v != nil {
+		writes.add(v.(string), conf.DB.ReadYourWrites)
+	}
+}
+
+// writeLog holds the time of the last mutation of each user
+type writeLog struct {
+	sync.Mutex
+	users map[string]time.Time
+	swept time.Time
+	now   func() time.Time
+}
+
+func newWriteLog() *writeLog {
+	return &writeLog{
+		users: make(map[string]time.Time),
+		now:   time.Now,
+	}
+}
+
+func (l *writeLog) add(user string, window time.Duration) {
+	now := l.now()
+
+	l.Lock()
+	defer l.Unlock()
+
+	if now.Sub(l.swept) > writeSweepInterval {
+		l.sweep(now, window)
+	}
+	l.users[user] = now
+}
+
+// recent returns true if the user made a mutation within the window
+func (l *writeLog) recent(user string, window time.Duration) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	t, ok := l.users[user]
+	return ok && l.now().Sub(t) < window
+}
+
+// sweep removes the mutations that are outside the window
+func (l *writeLog) sweep(now time.Time, window time.Duration) {
+	for k, t := range l.users {
+		if now.Sub(t) >= window {
+			delete(l.users, k)
+		}
+	}
+	l.swept = now
+}
@@ -0,0 +1,99 @@
+package serv
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dosco/super-graph/qcode"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestWriteLog(t *testing.T) {
+	now := time.Now()
+
+	l := newWriteLog()
+	l.now = func() time.Time { return now }
+
+	l.add("1", time.Second)
+
+	if !l.recent("1", time.Second) {
+		t.Fatal("expected a recent write")
+	}
+
+	if l.recent("2", time.Second) {
+		t.Fatal("writes should be separate for each user")
+	}
+
+	now = now.Add(time.Second)
+
+	if l.recent("1", time.Second) {
+		t.Fatal("expected the write to be outside the window")
+	}
+
+	now = now.Add(writeSweepInterval)
+	l.add("2", time.Second)
+
+	if _, ok := l.users["1"]; ok {
+		t.Fatal("expected the old write to be swept")
+	}
+}
+
+func TestReplicaSetPick(t *testing.T) {
+	p1, p2 := &pgxpool.Pool{}, &pgxpool.Pool{}
+
+	rs := &replicaSet{replicas: []*replica{
+		{name: "r1", pool: p1, healthy: true},
+		{name: "r2"},
+		{name: "r3", pool: p2, healthy: true},
+	}}
+
+	seen := map[*pgxpool.Pool]int{}
+
+	for i := 0; i < 6; i++ {
+		seen[rs.pick()]++
+	}
+
+	if len(seen) != 2 || seen[p1] == 0 || seen[p2] == 0 {
+		t.Fatalf("expected queries to be balanced across the healthy replicas: %v", seen)
+	}
+
+	rs.replicas[0].healthy = false
+	rs.replicas[2].healthy = false
+
+	if rs.pick() != nil {
+		t.Fatal("expected no replica when none are healthy")
+	}
+}
+
+func TestQueryPool(t *testing.T) {
+	primary, pool := &pgxpool.Pool{}, &pgxpool.Pool{}
+
+	conf = &config{}
+	conf.DB.ReadYourWrites = time.Minute
+	db = primary
+	replicas = &replicaSet{replicas: []*replica{{name: "r1", pool: pool, healthy: true}}}
+
+	defer func() {
+		conf = &config{}
+		db = nil
+		replicas = nil
+		writes = newWriteLog()
+	}()
+
+	c := &coreContext{Context: context.WithValue(context.Background(), userIDKey, "1")}
+
+	if c.queryPool(qcode.QTQuery) != pool {
+		t.Fatal("expected queries to go to the replica")
+	}
+
+	if c.queryPool(qcode.QTMutation) != primary {
+		t.Fatal("expected mutations to go to the primary")
+	}
+
+	c.addWrite(qcode.QTMutation)
+
+	if c.queryPool(qcode.QTQuery) != primary {
+		t.Fatal("expected queries after a mutation to go to the primary")
+	}
+}
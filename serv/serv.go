@@ -16,7 +16,13 @@ import (
 )
 
 func initCompilers(c *config) (*qcode.Compiler, *psql.Compiler, error) {
-	di, err := psql.GetDBInfo(db)
+	ns := make([]psql.Namespace, 0, len(c.DB.Schemas))
+
+	for _, v := range c.DB.Schemas {
+		ns = append(ns, psql.Namespace{Name: v.Name, Prefix: v.Prefix})
+	}
+
+	di, err := psql.GetDBInfo(db, ns...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -34,6 +40,18 @@ func initCompilers(c *config) (*qcode.Compiler, *psql.Compiler, error) {
 		return nil, nil, err
 	}
 
+	if err = addComputedColumns(c, schema); err != nil {
+		return nil, nil, err
+	}
+
+	if err = addPolymorphicRels(c, schema); err != nil {
+		return nil, nil, err
+	}
+
+	if err = addSearchConfigs(c, schema); err != nil {
+		return nil, nil, err
+	}
+
 	qc, err := qcode.NewCompiler(qcode.Config{
 		Blocklist: c.DB.Blocklist,
 	})
@@ -45,6 +63,25 @@ func initCompilers(c *config) (*qcode.Compiler, *psql.Compiler, error) {
 		return nil, nil, err
 	}
 
+	for _, t := range c.Tables {
+		for _, p := range t.Polymorphic {
+			tables := make([]string, 0, len(p.Types))
+
+			for _, v := range p.Types {
+				tables = append(tables, v.Table)
+			}
+			qc.AddPolymorphic(p.Name, tables)
+		}
+	}
+
+	for _, ti := range schema.GetFunctions() {
+		if len(ti.Func.Table) != 0 {
+			qc.AddFunction(ti.Func.Key, ti.Name)
+		} else {
+			qc.AddFunction(ti.Func.Key, "")
+		}
+	}
+
 	pc := psql.NewCompiler(psql.Config{
 		Schema: schema,
 		Vars:   c.DB.Vars,
@@ -196,6 +233,7 @@ func setActionRoutes(routes map[string]http.Handler) error {
 		}
 
 		p := fmt.Sprintf("/api/v1/actions/%s", strings.ToLower(a.Name))
+		fn = rateLimitHandler(fn, a.Name)
 
 		if authc, ok := findAuth(a.AuthName); ok {
 			routes[p] = withAuth(fn, authc)
@@ -1,33 +1,420 @@
 package serv
 
-/*
-func apiv1Ws(w http.ResponseWriter, r *http.Request) {
-	c, err := upgrader.Upgrade(w, r, nil)
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dosco/super-graph/allow"
+	"github.com/dosco/super-graph/qcode"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+	wsProtoTransport = "graphql-transport-ws"
+
+	// https://github.com/apollographql/subscriptions-transport-ws/blob/master/PROTOCOL.md
+	wsProtoLegacy = "graphql-ws"
+
+	wsKeepAlive = 15 * time.Second
+)
+
+const (
+	wsConnInit      = "connection_init"
+	wsConnAck       = "connection_ack"
+	wsConnError     = "connection_error"
+	wsConnTerminate = "connection_terminate"
+	wsKeepAliveMsg  = "ka"
+	wsPing          = "ping"
+	wsPong          = "pong"
+	wsStart         = "start"
+	wsSubscribe     = "subscribe"
+	wsStop          = "stop"
+	wsData          = "data"
+	wsNext          = "next"
+	wsError         = "error"
+	wsComplete      = "complete"
+)
+
+var (
+	errWsNotInit = errors.New("connection not initialized")
+
+	upgrader = websocket.Upgrader{
+		Subprotocols: []string{wsProtoTransport, wsProtoLegacy},
+		CheckOrigin:  checkOrigin,
+	}
+)
+
+type wsMsg struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type wsConn struct {
+	*websocket.Conn
+	context.Context
+	r      *http.Request
+	legacy bool
+	init   bool
+
+	mu   sync.Mutex // guards writes and subs
+	subs map[string]context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+func apiV1Ws(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		fmt.Println(err)
+		errlog.Error().Err(err).Msg("failed to upgrade to websocket")
 		return
 	}
-	defer c.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+
+	wc := &wsConn{
+		Conn:    conn,
+		Context: ctx,
+		r:       r,
+		legacy:  (conn.Subprotocol() != wsProtoTransport),
+		subs:    make(map[string]context.CancelFunc),
+	}
+
+	defer func() {
+		cancel()
+		wc.wg.Wait()
+		conn.Close()
+	}()
+
+	if err := wc.serve(); err != nil {
+		logger.Debug().Err(err).Msg("websocket closed")
+	}
+}
+
+func (wc *wsConn) serve() error {
 	for {
-		mt, message, err := c.ReadMessage()
-		if err != nil {
-			fmt.Println("read:", err)
+		var msg wsMsg
+
+		if err := wc.ReadJSON(&msg); err != nil {
+			return err
+		}
+
+		switch msg.Type {
+		case wsConnInit:
+			if wc.init {
+				if !wc.legacy {
+					return wc.close(4429, "too many initialisation requests")
+				}
+				if err := wc.send(wsMsg{Type: wsConnAck}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if !wc.auth(msg.Payload) {
+				if wc.legacy {
+					wc.send(wsMsg{Type: wsConnError}) //nolint: errcheck
+					return errUnauthorized
+				}
+				return wc.close(4403, "forbidden")
+			}
+			wc.init = true
+
+			if err := wc.send(wsMsg{Type: wsConnAck}); err != nil {
+				return err
+			}
+			wc.wg.Add(1)
+			go wc.keepAlive()
+
+		case wsPing:
+			if err := wc.send(wsMsg{Type: wsPong}); err != nil {
+				return err
+			}
+
+		case wsPong:
+
+		case wsStart, wsSubscribe:
+			if !wc.init {
+				if wc.legacy {
+					return errWsNotInit
+				}
+				return wc.close(4401, "unauthorized")
+			}
+
+			if err := wc.subscribe(msg); err != nil {
+				return err
+			}
+
+		case wsStop, wsComplete:
+			wc.unsubscribe(msg.ID)
+
+		case wsConnTerminate:
+			return nil
+
+		default:
+			if wc.legacy {
+				err := wc.sendError(msg.ID, errors.New("invalid message type"))
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			return wc.close(4400, "invalid message type")
+		}
+	}
+}
+
+// auth runs the configured auth on the payload of connection_init, since
+// browsers can't set headers on a websocket the string values in the
+// payload are used as the headers of the upgrade request
+func (wc *wsConn) auth(payload json.RawMessage) bool {
+	var hdrs map[string]interface{}
+
+	if len(payload) != 0 {
+		json.Unmarshal(payload, &hdrs) //nolint: errcheck
+	}
+
+	if len(hdrs) != 0 {
+		var ar *http.Request
+
+		r := wc.r.Clone(wc.Context)
+
+		for k, v := range hdrs {
+			if s, ok := v.(string); ok {
+				r.Header.Set(k, s)
+			}
+		}
+
+		h := withAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ar = r
+		}), conf.Auth)
+
+		h.ServeHTTP(discardWriter{}, r)
+
+		if ar == nil {
+			return false
+		}
+
+		wc.r = ar
+		wc.Context = ar.Context()
+	}
+
+	if conf.AuthFailBlock && wc.Value(userIDKey) == nil {
+		return false
+	}
+
+	return true
+}
+
+func (wc *wsConn) subscribe(msg wsMsg) error {
+	var req gqlReq
+
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return wc.sendError(msg.ID, badInputErr(err))
+	}
+
+	if err := checkRateLimit(wc.r, allow.QueryName(req.operation())); err != nil {
+		return wc.sendError(msg.ID, err)
+	}
+
+	ctx, cancel := context.WithCancel(wc.Context)
+
+	wc.mu.Lock()
+	_, exists := wc.subs[msg.ID]
+	if !exists {
+		wc.subs[msg.ID] = cancel
+	}
+	wc.mu.Unlock()
+
+	if exists {
+		cancel()
+		if wc.legacy {
+			return wc.sendError(msg.ID, errors.New("subscription id already in use"))
+		}
+		return wc.close(4409, "Subscriber for "+msg.ID+" already exists")
+	}
+
+	c := &coreContext{Context: ctx, req: req}
+	c.initReq(wc.r)
+
+	wc.wg.Add(1)
+	go wc.run(c, msg.ID, cancel)
+
+	return nil
+}
+
+func (wc *wsConn) unsubscribe(id string) {
+	wc.mu.Lock()
+	if cancel, ok := wc.subs[id]; ok {
+		cancel()
+		delete(wc.subs, id)
+	}
+	wc.mu.Unlock()
+}
+
+// run executes the operation and for subscriptions keeps re-running it
+// sending the result to the client only when it has changed
+func (wc *wsConn) run(c *coreContext, id string, cancel context.CancelFunc) {
+	defer wc.wg.Done()
+	defer wc.unsubscribe(id)
+	defer cancel()
+
+	var lastHash uint64
+	var err error
+
+	live := qcode.IsSubscription(c.req.operation())
+	ticker := time.NewTicker(conf.SubsPollDuration)
+	defer ticker.Stop()
+
+	for n := 0; ; n++ {
+		var data []byte
+		c.res = gqlResp{}
+
+		if data, err = c.execQuery(); err != nil {
 			break
 		}
-		fmt.Printf("recv: %s", message)
-		err = c.WriteMessage(mt, message)
-		if err != nil {
-			fmt.Println("write:", err)
+
+		if h := xxhash.Sum64(data); n == 0 || h != lastHash {
+			lastHash = h
+			c.res.Data = json.RawMessage(data)
+
+			if err = wc.sendData(id, c.res); err != nil {
+				break
+			}
+		}
+
+		if !live {
 			break
 		}
+
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	// the client has unsubscribed or closed the connection
+	if c.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		errlog.Error().Err(err).Msg(c.req.Query)
+		wc.sendError(id, err) //nolint: errcheck
+		return
+	}
+
+	wc.send(wsMsg{ID: id, Type: wsComplete}) //nolint: errcheck
+}
+
+func (wc *wsConn) keepAlive() {
+	defer wc.wg.Done()
+
+	ticker := time.NewTicker(wsKeepAlive)
+	defer ticker.Stop()
+
+	msg := wsMsg{Type: wsPing}
+	if wc.legacy {
+		msg.Type = wsKeepAliveMsg
+	}
+
+	for {
+		select {
+		case <-wc.Done():
+			return
+		case <-ticker.C:
+			if err := wc.send(msg); err != nil {
+				return
+			}
+		}
 	}
 }
 
-func serve(w http.ResponseWriter, r *http.Request) {
-	// if websocket.IsWebSocketUpgrade(r) {
-	// 	apiv1Ws(w, r)
-	// 	return
-	// }
-	apiv1Http(w, r)
+func (wc *wsConn) sendData(id string, res gqlResp) error {
+	payload, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	msg := wsMsg{ID: id, Type: wsNext, Payload: payload}
+	if wc.legacy {
+		msg.Type = wsData
+	}
+
+	return wc.send(msg)
+}
+
+func (wc *wsConn) sendError(id string, err error) error {
+	var payload []byte
+	var err1 error
+
+	errs := gqlErrors(err)
+
+	if wc.legacy {
+		payload, err1 = json.Marshal(errs[0])
+	} else {
+		payload, err1 = json.Marshal(errs)
+	}
+
+	if err1 != nil {
+		return err1
+	}
+
+	return wc.send(wsMsg{ID: id, Type: wsError, Payload: payload})
+}
+
+func (wc *wsConn) send(msg wsMsg) error {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	return wc.WriteJSON(msg)
+}
+
+func (wc *wsConn) close(code int, reason string) error {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	msg := websocket.FormatCloseMessage(code, reason)
+	return wc.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// discardWriter is the response writer for auth run outside of a request
+type discardWriter struct{}
+
+func (discardWriter) Header() http.Header         { return http.Header{} }
+func (discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardWriter) WriteHeader(int)             {}
+
+// checkOrigin allows the same origins as configured for CORS, when none are
+// configured only same origin requests are allowed
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+
+	if len(origin) == 0 {
+		return true
+	}
+
+	if len(conf.AllowedOrigins) == 0 {
+		return strings.HasSuffix(origin, "://"+r.Host)
+	}
+
+	for _, v := range conf.AllowedOrigins {
+		if v == "*" || strings.EqualFold(v, origin) {
+			return true
+		}
+
+		if n := strings.IndexByte(v, '*'); n != -1 &&
+			strings.HasPrefix(origin, v[:n]) && strings.HasSuffix(origin, v[n+1:]) {
+			return true
+		}
+	}
+
+	return false
 }
-*/
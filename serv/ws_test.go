@@ -0,0 +1,144 @@
+package serv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func wsDial(t *testing.T, srv *httptest.Server, proto string) *websocket.Conn {
+
+	d := websocket.Dialer{Subprotocols: []string{proto}}
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	conn, _, err := d.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.Subprotocol() != proto {
+		t.Fatalf("expected subprotocol '%s' got '%s'", proto, conn.Subprotocol())
+	}
+	return conn
+}
+
+func wsExpect(t *testing.T, conn *websocket.Conn, send wsMsg, expected string) {
+	if err := conn.WriteJSON(send); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg wsMsg
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Type != expected {
+		t.Fatalf("expected message '%s' got '%s'", expected, msg.Type)
+	}
+}
+
+func TestWsTransportProtocol(t *testing.T) {
+	conf = &config{}
+
+	srv := httptest.NewServer(http.HandlerFunc(apiV1Ws))
+	defer srv.Close()
+
+	conn := wsDial(t, srv, wsProtoTransport)
+	defer conn.Close()
+
+	wsExpect(t, conn, wsMsg{Type: wsConnInit}, wsConnAck)
+	wsExpect(t, conn, wsMsg{Type: wsPing}, wsPong)
+}
+
+func TestWsTransportUnauthorized(t *testing.T) {
+	conf = &config{}
+
+	srv := httptest.NewServer(http.HandlerFunc(apiV1Ws))
+	defer srv.Close()
+
+	conn := wsDial(t, srv, wsProtoTransport)
+	defer conn.Close()
+
+	err := conn.WriteJSON(wsMsg{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription { users { id } }"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, 4401) {
+		t.Fatalf("expected close error 4401 got '%v'", err)
+	}
+}
+
+func TestWsLegacyProtocol(t *testing.T) {
+	conf = &config{}
+
+	srv := httptest.NewServer(http.HandlerFunc(apiV1Ws))
+	defer srv.Close()
+
+	conn := wsDial(t, srv, wsProtoLegacy)
+	defer conn.Close()
+
+	wsExpect(t, conn, wsMsg{Type: wsConnInit}, wsConnAck)
+	wsExpect(t, conn, wsMsg{Type: "unknown"}, wsError)
+}
+
+func TestWsConnInitAuth(t *testing.T) {
+	conf = &config{}
+	conf.Auth.CredsInHeader = true
+	conf.AuthFailBlock = true
+
+	defer func() { conf = &config{} }()
+
+	srv := httptest.NewServer(http.HandlerFunc(apiV1Ws))
+	defer srv.Close()
+
+	conn := wsDial(t, srv, wsProtoTransport)
+	defer conn.Close()
+
+	wsExpect(t, conn, wsMsg{Type: wsConnInit, Payload: []byte(`{"X-User-ID": "1"}`)}, wsConnAck)
+
+	conn1 := wsDial(t, srv, wsProtoTransport)
+	defer conn1.Close()
+
+	if err := conn1.WriteJSON(wsMsg{Type: wsConnInit}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := conn1.ReadMessage()
+	if !websocket.IsCloseError(err, 4403) {
+		t.Fatalf("expected close error 4403 got '%v'", err)
+	}
+}
+
+type denyRateStore struct{}
+
+func (denyRateStore) Take(key string, lim rateLimit) (bool, time.Duration) {
+	return false, time.Second
+}
+
+func TestWsRateLimit(t *testing.T) {
+	anon := &configRole{Name: "anon"}
+	anon.RateLimit.Rate = 1
+
+	conf = &config{roles: map[string]*configRole{"anon": anon}}
+	rateLimiter = denyRateStore{}
+
+	defer func() {
+		conf = &config{}
+		rateLimiter = newMemRateStore()
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(apiV1Ws))
+	defer srv.Close()
+
+	conn := wsDial(t, srv, wsProtoTransport)
+	defer conn.Close()
+
+	wsExpect(t, conn, wsMsg{Type: wsConnInit}, wsConnAck)
+	wsExpect(t, conn, wsMsg{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription { users { id } }"}`)}, wsError)
+}